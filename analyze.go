@@ -1,6 +1,15 @@
 package main
 
 import (
+    "fmt"
+    "log"
+    "math"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
     "github.com/sdcoffey/big"
     "github.com/sdcoffey/techan"
 )
@@ -8,28 +17,439 @@ import (
 // UseMLAnalyze toggles ML-based analysis when true. Defaults to false.
 var UseMLAnalyze bool
 
-// analyze dispatches to either the classic rule-based analysis or ML-based analysis.
+// MLFallback selects what analyzeML does while no trained model is available:
+// "hold" (default) or "classic" to delegate to analyzeClassic.
+var MLFallback = "hold"
+
+// MinEMAATRMultiple is the minimum EMA gap, as a multiple of ATR, required before an
+// EMA cross is acted on. A cross that starts out narrower stays pending and is acted on at
+// the first later candle where the gap is wide enough, unless the EMAs cross back first.
+// Zero disables the filter.
+var MinEMAATRMultiple float64
+
+// MinVolumeSpike is the minimum ratio of the signal candle's volume to the average volume of
+// the preceding volumeSpikeLookback candles required before a BUY is taken. Zero disables it.
+var MinVolumeSpike float64
+
+// volumeSpikeLookback is the number of candles averaged for the volume spike filter
+const volumeSpikeLookback = 20
+
+// StrategyConfig selects the rule set analyze uses and holds its indicator periods and thresholds
+type StrategyConfig struct {
+    Name            string  // Registered SignalStrategy to use: "classic" (EMA/RSI/MACD, default), "stochastic", "bollinger", "ml" or a custom one
+    EMAShort        int
+    EMALong         int
+    RSIPeriod       int
+    RSIOverbought   float64
+    RSIOversold     float64
+    MACDFast        int
+    MACDSlow        int
+    MACDSignal      int
+    StochK          int     // %K lookback
+    StochSmooth     int     // SMA smoothing of the raw %K
+    StochD          int     // SMA of the smoothed %K
+    StochOverbought float64
+    StochOversold   float64
+    BollingerPeriod int     // SMA window of the bands
+    BollingerStdDev float64 // Band distance from the SMA in standard deviations
+}
+
+// DefaultStrategyConfig returns the original EMA 9/21, RSI 14 (70/30), MACD 12/26/9 setup,
+// with stochastic 14/3/3 (80/20) and Bollinger 20/2 defaults for the alternative strategies
+func DefaultStrategyConfig() StrategyConfig {
+    return StrategyConfig{
+        Name:            "classic",
+        EMAShort:        9,
+        EMALong:         21,
+        RSIPeriod:       14,
+        RSIOverbought:   70,
+        RSIOversold:     30,
+        MACDFast:        12,
+        MACDSlow:        26,
+        MACDSignal:      9,
+        StochK:          14,
+        StochSmooth:     3,
+        StochD:          3,
+        StochOverbought: 80,
+        StochOversold:   20,
+        BollingerPeriod: 20,
+        BollingerStdDev: 2,
+    }
+}
+
+// Strategy is the configuration analyze reads. Defaults to DefaultStrategyConfig.
+var Strategy = DefaultStrategyConfig()
+
+// Warmup returns the number of candles needed before every indicator is meaningful
+func (sc StrategyConfig) Warmup() int {
+    switch sc.Name {
+    case "stochastic":
+        // %D averages StochD smoothed values, each averaging StochSmooth raw %K values
+        return sc.StochK + sc.StochSmooth + sc.StochD - 2
+    case "bollinger":
+        // The previous candle needs complete bands too
+        return sc.BollingerPeriod
+    }
+
+    warmup := sc.EMALong
+    if sc.RSIPeriod > warmup {
+        warmup = sc.RSIPeriod
+    }
+    if sc.MACDSlow > warmup {
+        warmup = sc.MACDSlow
+    }
+    return warmup
+}
+
+// Validate checks that the periods are positive and ordered and the RSI, stochastic and
+// Bollinger settings make sense
+func (sc StrategyConfig) Validate() error {
+    if _, ok := strategyRegistry[sc.Name]; !ok {
+        return fmt.Errorf("unsupported strategy: %s (use %s)", sc.Name, strings.Join(RegisteredStrategies(), ", "))
+    }
+    if sc.BollingerPeriod < 2 || sc.BollingerStdDev <= 0 {
+        return fmt.Errorf("Bollinger period (%d) must be at least 2 and its multiplier (%.2f) positive", sc.BollingerPeriod, sc.BollingerStdDev)
+    }
+    if sc.StochK <= 0 || sc.StochSmooth <= 0 || sc.StochD <= 0 {
+        return fmt.Errorf("stochastic periods must be positive")
+    }
+    if sc.StochOversold < 0 || sc.StochOverbought > 100 || sc.StochOversold >= sc.StochOverbought {
+        return fmt.Errorf("stochastic levels must satisfy 0 <= oversold (%.1f) < overbought (%.1f) <= 100", sc.StochOversold, sc.StochOverbought)
+    }
+    if sc.EMAShort <= 0 || sc.EMALong <= 0 || sc.RSIPeriod <= 0 ||
+        sc.MACDFast <= 0 || sc.MACDSlow <= 0 || sc.MACDSignal <= 0 {
+        return fmt.Errorf("indicator periods must be positive")
+    }
+    if sc.EMAShort >= sc.EMALong {
+        return fmt.Errorf("EMA short period (%d) must be below EMA long period (%d)", sc.EMAShort, sc.EMALong)
+    }
+    if sc.MACDFast >= sc.MACDSlow {
+        return fmt.Errorf("MACD fast period (%d) must be below MACD slow period (%d)", sc.MACDFast, sc.MACDSlow)
+    }
+    if sc.RSIOversold < 0 || sc.RSIOverbought > 100 || sc.RSIOversold >= sc.RSIOverbought {
+        return fmt.Errorf("RSI levels must satisfy 0 <= oversold (%.1f) < overbought (%.1f) <= 100", sc.RSIOversold, sc.RSIOverbought)
+    }
+    return nil
+}
+
+// applyStrategyEnv overrides the config with any STRATEGY_* environment variables that are set
+func applyStrategyEnv(sc *StrategyConfig) error {
+    if s := os.Getenv("STRATEGY_NAME"); s != "" {
+        sc.Name = strings.ToLower(strings.TrimSpace(s))
+    }
+
+    ints := []struct {
+        name string
+        dst  *int
+    }{
+        {"STRATEGY_EMA_SHORT", &sc.EMAShort},
+        {"STRATEGY_EMA_LONG", &sc.EMALong},
+        {"STRATEGY_RSI_PERIOD", &sc.RSIPeriod},
+        {"STRATEGY_MACD_FAST", &sc.MACDFast},
+        {"STRATEGY_MACD_SLOW", &sc.MACDSlow},
+        {"STRATEGY_MACD_SIGNAL", &sc.MACDSignal},
+        {"STRATEGY_STOCH_K", &sc.StochK},
+        {"STRATEGY_STOCH_SMOOTH", &sc.StochSmooth},
+        {"STRATEGY_STOCH_D", &sc.StochD},
+        {"STRATEGY_BB_PERIOD", &sc.BollingerPeriod},
+    }
+    for _, v := range ints {
+        if s := os.Getenv(v.name); s != "" {
+            n, err := strconv.Atoi(strings.TrimSpace(s))
+            if err != nil {
+                return fmt.Errorf("invalid %s %q: %v", v.name, s, err)
+            }
+            *v.dst = n
+        }
+    }
+
+    floats := []struct {
+        name string
+        dst  *float64
+    }{
+        {"STRATEGY_RSI_OVERBOUGHT", &sc.RSIOverbought},
+        {"STRATEGY_RSI_OVERSOLD", &sc.RSIOversold},
+        {"STRATEGY_STOCH_OVERBOUGHT", &sc.StochOverbought},
+        {"STRATEGY_STOCH_OVERSOLD", &sc.StochOversold},
+        {"STRATEGY_BB_STDDEV", &sc.BollingerStdDev},
+    }
+    for _, v := range floats {
+        if s := os.Getenv(v.name); s != "" {
+            f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+            if err != nil {
+                return fmt.Errorf("invalid %s %q: %v", v.name, s, err)
+            }
+            *v.dst = f
+        }
+    }
+    return nil
+}
+
+// applyStrategyFlags overrides the config with the -strategy name and the comma-separated -ema,
+// -rsi, -rsi-levels, -macd, -stoch, -stoch-levels and -bollinger flag values; empty values
+// leave the config untouched
+func applyStrategyFlags(sc *StrategyConfig, name, ema, rsi, rsiLevels, macd, stoch, stochLevels, bollinger string) error {
+    if name != "" {
+        sc.Name = strings.ToLower(strings.TrimSpace(name))
+    }
+    if ema != "" {
+        periods, err := parseIntList(ema, 2)
+        if err != nil {
+            return fmt.Errorf("invalid -ema %q (want short,long): %v", ema, err)
+        }
+        sc.EMAShort, sc.EMALong = periods[0], periods[1]
+    }
+    if rsi != "" {
+        periods, err := parseIntList(rsi, 1)
+        if err != nil {
+            return fmt.Errorf("invalid -rsi %q (want period): %v", rsi, err)
+        }
+        sc.RSIPeriod = periods[0]
+    }
+    if rsiLevels != "" {
+        parts := strings.Split(rsiLevels, ",")
+        if len(parts) != 2 {
+            return fmt.Errorf("invalid -rsi-levels %q (want overbought,oversold)", rsiLevels)
+        }
+        overbought, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+        if err != nil {
+            return fmt.Errorf("invalid -rsi-levels %q: %v", rsiLevels, err)
+        }
+        oversold, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+        if err != nil {
+            return fmt.Errorf("invalid -rsi-levels %q: %v", rsiLevels, err)
+        }
+        sc.RSIOverbought, sc.RSIOversold = overbought, oversold
+    }
+    if macd != "" {
+        periods, err := parseIntList(macd, 3)
+        if err != nil {
+            return fmt.Errorf("invalid -macd %q (want fast,slow,signal): %v", macd, err)
+        }
+        sc.MACDFast, sc.MACDSlow, sc.MACDSignal = periods[0], periods[1], periods[2]
+    }
+    if stoch != "" {
+        periods, err := parseIntList(stoch, 3)
+        if err != nil {
+            return fmt.Errorf("invalid -stoch %q (want k,smooth,d): %v", stoch, err)
+        }
+        sc.StochK, sc.StochSmooth, sc.StochD = periods[0], periods[1], periods[2]
+    }
+    if stochLevels != "" {
+        levels, err := parseFloatList(stochLevels, 2)
+        if err != nil {
+            return fmt.Errorf("invalid -stoch-levels %q (want overbought,oversold): %v", stochLevels, err)
+        }
+        sc.StochOverbought, sc.StochOversold = levels[0], levels[1]
+    }
+    if bollinger != "" {
+        values, err := parseFloatList(bollinger, 2)
+        if err != nil {
+            return fmt.Errorf("invalid -bollinger %q (want period,stddev): %v", bollinger, err)
+        }
+        if values[0] != math.Trunc(values[0]) {
+            return fmt.Errorf("invalid -bollinger %q: period must be a whole number", bollinger)
+        }
+        sc.BollingerPeriod, sc.BollingerStdDev = int(values[0]), values[1]
+    }
+    return nil
+}
+
+// parseIntList parses exactly n comma-separated integers
+func parseIntList(s string, n int) ([]int, error) {
+    parts := strings.Split(s, ",")
+    if len(parts) != n {
+        return nil, fmt.Errorf("expected %d values, got %d", n, len(parts))
+    }
+    values := make([]int, n)
+    for i, part := range parts {
+        v, err := strconv.Atoi(strings.TrimSpace(part))
+        if err != nil {
+            return nil, err
+        }
+        values[i] = v
+    }
+    return values, nil
+}
+
+// parseFloatList parses exactly n comma-separated floats
+func parseFloatList(s string, n int) ([]float64, error) {
+    parts := strings.Split(s, ",")
+    if len(parts) != n {
+        return nil, fmt.Errorf("expected %d values, got %d", n, len(parts))
+    }
+    values := make([]float64, n)
+    for i, part := range parts {
+        v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+        if err != nil {
+            return nil, err
+        }
+        values[i] = v
+    }
+    return values, nil
+}
+
+// analyzeWithConfirmation is analyze with a higher-timeframe trend filter: a BUY only stands
+// when the EMA (EMALong periods) of higher is rising at its last candle, otherwise it becomes
+// HOLD. A nil higher series leaves the signal unchanged.
+func analyzeWithConfirmation(symbol string, ts, higher *techan.TimeSeries) string {
+    signal := analyze(symbol, ts)
+    if signal != "BUY" || higher == nil {
+        return signal
+    }
+    if !higherTrendRising(higher, Strategy.EMALong) {
+        return "HOLD"
+    }
+    return signal
+}
+
+// higherTrendRising reports whether the EMA of ts closes rising at its last candle. It is false
+// until the series is longer than the EMA period.
+func higherTrendRising(ts *techan.TimeSeries, period int) bool {
+    lastIdx := ts.LastIndex()
+    if lastIdx < period {
+        return false
+    }
+    ema := techan.NewEMAIndicator(techan.NewClosePriceIndicator(ts), period)
+    return ema.Calculate(lastIdx).GT(ema.Calculate(lastIdx - 1))
+}
+
+// SignalStrategy is a rule set analyze can dispatch to. Evaluate returns BUY, SELL, HOLD or
+// WAIT for the candle at index, looking only at candles up to and including it.
+type SignalStrategy interface {
+    Name() string
+    Evaluate(symbol string, ts *techan.TimeSeries, index int) string
+}
+
+// strategyRegistry holds every strategy selectable through StrategyConfig.Name
+var strategyRegistry = make(map[string]SignalStrategy)
+
+// RegisterStrategy makes s selectable by its name. It panics if the name is empty or taken,
+// like other registries, since that is a programming error.
+func RegisterStrategy(s SignalStrategy) {
+    name := s.Name()
+    if name == "" {
+        panic("RegisterStrategy: empty strategy name")
+    }
+    if _, exists := strategyRegistry[name]; exists {
+        panic("RegisterStrategy: strategy " + name + " registered twice")
+    }
+    strategyRegistry[name] = s
+}
+
+// RegisteredStrategies returns the names of all registered strategies, sorted
+func RegisteredStrategies() []string {
+    names := make([]string, 0, len(strategyRegistry))
+    for name := range strategyRegistry {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Signal is a strategy decision together with how strongly the indicators back it
+type Signal struct {
+    Action     string  // BUY, SELL, HOLD or WAIT
+    Confidence float64 // Conviction in (0, 1] for BUY/SELL; 0 for HOLD and WAIT
+    Reason     string  // Short human-readable explanation
+}
+
+// SignalEvaluator is implemented by strategies that can explain their decisions. Strategies
+// that only implement SignalStrategy get a full-confidence Signal for BUY/SELL.
+type SignalEvaluator interface {
+    EvaluateSignal(symbol string, ts *techan.TimeSeries, index int) Signal
+}
+
+// actionSignal wraps a bare action for strategies without graded output
+func actionSignal(action, reason string) Signal {
+    confidence := 0.0
+    if action == "BUY" || action == "SELL" {
+        confidence = 1
+    }
+    return Signal{Action: action, Confidence: confidence, Reason: reason}
+}
+
+// funcStrategy adapts an analyze* function, which reads the last candle, to SignalStrategy
+type funcStrategy struct {
+    name   string
+    signal func(symbol string, ts *techan.TimeSeries) Signal
+}
+
+// actionStrategy registers a string-returning analyze* function under name
+func actionStrategy(name string, analyze func(symbol string, ts *techan.TimeSeries) string) funcStrategy {
+    return funcStrategy{name, func(symbol string, ts *techan.TimeSeries) Signal {
+        return actionSignal(analyze(symbol, ts), name+" rules")
+    }}
+}
+
+func (f funcStrategy) Name() string { return f.name }
+
+func (f funcStrategy) Evaluate(symbol string, ts *techan.TimeSeries, index int) string {
+    return f.EvaluateSignal(symbol, ts, index).Action
+}
+
+func (f funcStrategy) EvaluateSignal(symbol string, ts *techan.TimeSeries, index int) Signal {
+    if index < 0 || index >= len(ts.Candles) {
+        return Signal{Action: "WAIT", Reason: "no candle at index"}
+    }
+    if index < ts.LastIndex() {
+        ts = &techan.TimeSeries{Candles: ts.Candles[:index+1]}
+    }
+    return f.signal(symbol, ts)
+}
+
+func init() {
+    RegisterStrategy(funcStrategy{"classic", classicSignal})
+    RegisterStrategy(actionStrategy("stochastic", analyzeStochastic))
+    RegisterStrategy(actionStrategy("bollinger", analyzeBollinger))
+    RegisterStrategy(actionStrategy("ml", analyzeML))
+}
+
+// analyze evaluates the last candle with the strategy named by Strategy.Name, or with the ML
+// strategy when UseMLAnalyze is set. It is analyzeSignal without the confidence and reason.
 func analyze(symbol string, ts *techan.TimeSeries) string {
+    return analyzeSignal(symbol, ts).Action
+}
+
+// analyzeSignal evaluates the last candle like analyze, returning the full Signal
+func analyzeSignal(symbol string, ts *techan.TimeSeries) Signal {
+    name := Strategy.Name
     if UseMLAnalyze {
-        return analyzeML(symbol, ts)
+        name = "ml"
     }
-    return analyzeClassic(symbol, ts)
+    s, ok := strategyRegistry[name]
+    if !ok {
+        log.Printf("Unknown strategy %q; holding", name)
+        return Signal{Action: "HOLD", Reason: "unknown strategy " + name}
+    }
+    if evaluator, ok := s.(SignalEvaluator); ok {
+        return evaluator.EvaluateSignal(symbol, ts, ts.LastIndex())
+    }
+    return actionSignal(s.Evaluate(symbol, ts, ts.LastIndex()), name+" rules")
 }
 
 // analyzeClassic produces a simple BUY/SELL/HOLD signal using EMA cross, RSI, and MACD
+// with the periods and thresholds in Strategy
 func analyzeClassic(symbol string, ts *techan.TimeSeries) string {
+    return classicSignal(symbol, ts).Action
+}
+
+// classicSignal is analyzeClassic with a reason and a confidence: the mean of the EMA gap and
+// MACD spread (each in ATRs, capped at 1) and the RSI's room before the opposite extreme
+func classicSignal(symbol string, ts *techan.TimeSeries) Signal {
+    sc := Strategy
     closePrices := techan.NewClosePriceIndicator(ts)
-    emaShort := techan.NewEMAIndicator(closePrices, 9)
-    emaLong := techan.NewEMAIndicator(closePrices, 21)
+    emaShort := techan.NewEMAIndicator(closePrices, sc.EMAShort)
+    emaLong := techan.NewEMAIndicator(closePrices, sc.EMALong)
 
-    rsi := techan.NewRelativeStrengthIndexIndicator(closePrices, 14)
+    rsi := techan.NewRelativeStrengthIndexIndicator(closePrices, sc.RSIPeriod)
 
-    macd := techan.NewMACDIndicator(closePrices, 12, 26)
-    macdSignal := techan.NewMACDHistogramIndicator(macd, 9)
+    macd := techan.NewMACDIndicator(closePrices, sc.MACDFast, sc.MACDSlow)
+    macdSignal := techan.NewMACDHistogramIndicator(macd, sc.MACDSignal)
 
     lastIdx := ts.LastIndex()
-    if lastIdx < 26 {
-        return "WAIT"
+    if lastIdx < sc.Warmup() {
+        return Signal{Action: "WAIT", Reason: "warming up indicators"}
     }
 
     emaShortNow := emaShort.Calculate(lastIdx)
@@ -41,25 +461,259 @@ func analyzeClassic(symbol string, ts *techan.TimeSeries) string {
     macdVal := macd.Calculate(lastIdx)
     macdSignalVal := macdSignal.Calculate(lastIdx)
 
-    if emaShortNow.GT(emaLongNow) && emaShortPrev.LTE(emaLongPrev) &&
-        rsiVal.LT(big.NewDecimal(70)) &&
+    atr := calculateATR(ts, lastIdx, 14)
+    emaGap := emaShortNow.Sub(emaLongNow).Float()
+    macdGap := macdVal.Sub(macdSignalVal).Float()
+    rsiRange := sc.RSIOverbought - sc.RSIOversold
+
+    crossUp := emaShortNow.GT(emaLongNow) && emaShortPrev.LTE(emaLongPrev)
+    crossDown := emaShortNow.LT(emaLongNow) && emaShortPrev.GTE(emaLongPrev)
+
+    // Wait until a cross's EMAs separate meaningfully relative to volatility
+    if MinEMAATRMultiple > 0 {
+        if math.Abs(emaGap) < MinEMAATRMultiple*atr {
+            return Signal{Action: "HOLD", Reason: fmt.Sprintf("EMA gap below %.2f×ATR", MinEMAATRMultiple)}
+        }
+        direction := separatedCross(ts, emaShort, emaLong, lastIdx, MinEMAATRMultiple)
+        crossUp, crossDown = direction > 0, direction < 0
+    }
+
+    if crossUp &&
+        rsiVal.LT(big.NewDecimal(sc.RSIOverbought)) &&
         macdVal.GT(macdSignalVal) {
-        return "BUY"
+        // Only trust breakouts that come with expanding volume
+        if MinVolumeSpike > 0 {
+            if ratio := volumeRatio(ts, lastIdx, volumeSpikeLookback); ratio < MinVolumeSpike {
+                return Signal{Action: "HOLD", Reason: fmt.Sprintf("EMA cross up without volume (%.2fx < %.2fx)", ratio, MinVolumeSpike)}
+            }
+        }
+        rsiRoom := (sc.RSIOverbought - rsiVal.Float()) / rsiRange
+        return Signal{
+            Action:     "BUY",
+            Confidence: (atrMultiple(emaGap, atr) + atrMultiple(macdGap, atr) + math.Min(rsiRoom, 1)) / 3,
+            Reason: fmt.Sprintf("EMA %d/%d cross up + RSI %.1f < %.0f + MACD confirm",
+                sc.EMAShort, sc.EMALong, rsiVal.Float(), sc.RSIOverbought),
+        }
     }
 
-    if emaShortNow.LT(emaLongNow) && emaShortPrev.GTE(emaLongPrev) &&
-        rsiVal.GT(big.NewDecimal(30)) &&
+    if crossDown &&
+        rsiVal.GT(big.NewDecimal(sc.RSIOversold)) &&
         macdVal.LT(macdSignalVal) {
+        rsiRoom := (rsiVal.Float() - sc.RSIOversold) / rsiRange
+        return Signal{
+            Action:     "SELL",
+            Confidence: (atrMultiple(-emaGap, atr) + atrMultiple(-macdGap, atr) + math.Min(rsiRoom, 1)) / 3,
+            Reason: fmt.Sprintf("EMA %d/%d cross down + RSI %.1f > %.0f + MACD confirm",
+                sc.EMAShort, sc.EMALong, rsiVal.Float(), sc.RSIOversold),
+        }
+    }
+
+    return Signal{Action: "HOLD", Reason: "no confirmed EMA cross"}
+}
+
+// atrMultiple returns a positive spread in ATRs, capped at 1 (also 1 when ATR is unknown)
+func atrMultiple(spread, atr float64) float64 {
+    if atr <= 0 {
+        return 1
+    }
+    return math.Min(spread/atr, 1)
+}
+
+// separatedCross returns the direction of the EMA cross that becomes actionable at index: 1
+// when the short EMA crossed above the long one, -1 when it crossed below, and 0 otherwise.
+// A cross is actionable at the first candle since it where the EMA gap is at least multiple
+// ATRs, provided the EMAs haven't crossed back in between.
+func separatedCross(ts *techan.TimeSeries, emaShort, emaLong techan.Indicator, index int, multiple float64) int {
+    gap := func(i int) float64 { return emaShort.Calculate(i).Sub(emaLong.Calculate(i)).Float() }
+
+    side := 1
+    if gap(index) < 0 {
+        side = -1
+    }
+    for i := index - 1; i >= 0; i-- {
+        g := gap(i)
+        if float64(side)*g <= 0 {
+            return side // The cross happened on the candle after i, and index is its first wide one
+        }
+        if math.Abs(g) >= multiple*calculateATR(ts, i, 14) {
+            return 0 // Already wide enough earlier, so the cross was acted on then
+        }
+    }
+    return 0
+}
+
+// analyzeStochastic produces BUY when the slow %K crosses above %D while %D is oversold and
+// SELL when it crosses below %D while %D is overbought, with the periods in Strategy
+func analyzeStochastic(symbol string, ts *techan.TimeSeries) string {
+    sc := Strategy
+    lastIdx := ts.LastIndex()
+    if lastIdx < sc.Warmup() {
+        return "WAIT"
+    }
+
+    rawK := stochasticKIndicator{
+        close: techan.NewClosePriceIndicator(ts),
+        low:   techan.NewMinimumValueIndicator(techan.NewLowPriceIndicator(ts), sc.StochK),
+        high:  techan.NewMaximumValueIndicator(techan.NewHighPriceIndicator(ts), sc.StochK),
+    }
+    k := techan.NewSimpleMovingAverage(rawK, sc.StochSmooth)
+    d := techan.NewSimpleMovingAverage(k, sc.StochD)
+
+    kNow, dNow := k.Calculate(lastIdx), d.Calculate(lastIdx)
+    kPrev, dPrev := k.Calculate(lastIdx-1), d.Calculate(lastIdx-1)
+
+    if kNow.GT(dNow) && kPrev.LTE(dPrev) && dNow.LT(big.NewDecimal(sc.StochOversold)) {
+        return "BUY"
+    }
+    if kNow.LT(dNow) && kPrev.GTE(dPrev) && dNow.GT(big.NewDecimal(sc.StochOverbought)) {
         return "SELL"
     }
+    return "HOLD"
+}
 
+// analyzeBollinger trades mean reversion with the bands in Strategy: BUY when the previous
+// close pierced the lower band and the current close turns back up, SELL when the close
+// pierces the upper band
+func analyzeBollinger(symbol string, ts *techan.TimeSeries) string {
+    sc := Strategy
+    lastIdx := ts.LastIndex()
+    if lastIdx < sc.Warmup() {
+        return "WAIT"
+    }
+
+    closePrices := techan.NewClosePriceIndicator(ts)
+    upper := techan.NewBollingerUpperBandIndicator(closePrices, sc.BollingerPeriod, sc.BollingerStdDev)
+    lower := techan.NewBollingerLowerBandIndicator(closePrices, sc.BollingerPeriod, sc.BollingerStdDev)
+
+    closeNow, closePrev := closePrices.Calculate(lastIdx), closePrices.Calculate(lastIdx-1)
+
+    if closePrev.LT(lower.Calculate(lastIdx-1)) && closeNow.GT(closePrev) {
+        return "BUY"
+    }
+    if closeNow.GT(upper.Calculate(lastIdx)) && closePrev.LTE(upper.Calculate(lastIdx-1)) {
+        return "SELL"
+    }
     return "HOLD"
 }
 
-// analyzeML is a placeholder for future ML-based analysis. Returns HOLD until wired to a model.
+// stochasticKIndicator is the raw %K: where the close sits within the lookback's low-high
+// range, from 0 to 100. Unlike techan's fast stochastic it returns 50 for a flat range
+// instead of +Inf, so the moving averages built on it stay finite.
+type stochasticKIndicator struct {
+    close techan.Indicator
+    low   techan.Indicator
+    high  techan.Indicator
+}
+
+func (s stochasticKIndicator) Calculate(index int) big.Decimal {
+    low, high := s.low.Calculate(index), s.high.Calculate(index)
+    if high.LTE(low) {
+        return big.NewDecimal(50)
+    }
+    return s.close.Calculate(index).Sub(low).Div(high.Sub(low)).Mul(big.NewDecimal(100))
+}
+
+// calculateATR returns the average true range over the period candles ending at index
+func calculateATR(ts *techan.TimeSeries, index int, period int) float64 {
+    if period <= 0 || index < 0 || index+1 < period || index >= len(ts.Candles) {
+        return 0
+    }
+
+    closePrices := techan.NewClosePriceIndicator(ts)
+    highPrices := techan.NewHighPriceIndicator(ts)
+    lowPrices := techan.NewLowPriceIndicator(ts)
+
+    sum := 0.0
+    for i := index - period + 1; i <= index; i++ {
+        high := highPrices.Calculate(i).Float()
+        low := lowPrices.Calculate(i).Float()
+
+        // The first candle has no previous close, so its true range is just high - low
+        trueRange := high - low
+        if i > 0 {
+            prevClose := closePrices.Calculate(i - 1).Float()
+            trueRange = math.Max(trueRange, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+        }
+        sum += trueRange
+    }
+
+    return sum / float64(period)
+}
+
+// volumeRatio returns the volume at index divided by the average volume of the lookback
+// candles before it, or 0 when there is not enough history or no prior volume
+func volumeRatio(ts *techan.TimeSeries, index int, lookback int) float64 {
+    if lookback <= 0 || index < lookback || index >= len(ts.Candles) {
+        return 0
+    }
+
+    sum := 0.0
+    for i := index - lookback; i < index; i++ {
+        sum += ts.Candles[i].Volume.Float()
+    }
+    if sum == 0 {
+        return 0
+    }
+    return ts.Candles[index].Volume.Float() / (sum / float64(lookback))
+}
+
+// MLPredictor is implemented by trained models usable from analyzeML. Predict returns one of
+// the BUY/SELL/HOLD/WAIT strings for the last candle of ts.
+type MLPredictor interface {
+    Predict(symbol string, ts *techan.TimeSeries) (string, error)
+}
+
+// mlPredictor is the model analyzeML consults; nil until a model is loaded
+var mlPredictor MLPredictor
+
+// mlMissingWarning makes sure the "no model loaded" warning is only logged once
+var mlMissingWarning sync.Once
+
+// SetMLPredictor installs the model used by analyzeML; nil removes it
+func SetMLPredictor(p MLPredictor) {
+    mlPredictor = p
+}
+
+// analyzeML asks the loaded ML model for a signal. Without a model, or when the model fails,
+// it behaves according to MLFallback.
 func analyzeML(symbol string, ts *techan.TimeSeries) string {
-    // TODO: plug in an ML predictor here in the future (e.g., via a global predictor or DI)
-    return "HOLD"
+    if mlPredictor == nil {
+        mlMissingWarning.Do(func() {
+            log.Printf("Warning: ML analysis requested but no model is loaded; using %s fallback", MLFallback)
+        })
+        return mlFallbackSignal(symbol, ts)
+    }
+
+    signal, err := mlPredictor.Predict(symbol, ts)
+    if err != nil {
+        log.Printf("ML prediction failed for %s: %v", symbol, err)
+        return mlFallbackSignal(symbol, ts)
+    }
+
+    switch signal {
+    case "BUY", "SELL", "HOLD", "WAIT":
+        return signal
+    default:
+        log.Printf("ML model returned unknown signal %q for %s", signal, symbol)
+        return "HOLD"
+    }
 }
 
+// mlFallbackSignal is the signal analyzeML uses when the model can't answer
+func mlFallbackSignal(symbol string, ts *techan.TimeSeries) string {
+    if MLFallback == "classic" {
+        return analyzeClassic(symbol, ts)
+    }
+    return "HOLD"
+}
 
+// parseMLFallback validates an ML fallback mode
+func parseMLFallback(mode string) (string, error) {
+    mode = strings.ToLower(strings.TrimSpace(mode))
+    switch mode {
+    case "hold", "classic":
+        return mode, nil
+    default:
+        return "", fmt.Errorf("unsupported ML fallback: %s (use classic or hold)", mode)
+    }
+}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdcoffey/big"
+	"github.com/sdcoffey/techan"
+)
+
+// syntheticSeries builds a 15m series of count synthetic candles in regime
+func syntheticSeries(t *testing.T, regime string, count int, seed int64) *techan.TimeSeries {
+	t.Helper()
+	klines, err := generateSyntheticKlines(regime, count, 15, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return klineSeries(klines)
+}
+
+// seriesPrefix returns the first n candles of ts as their own series
+func seriesPrefix(ts *techan.TimeSeries, n int) *techan.TimeSeries {
+	return &techan.TimeSeries{Candles: ts.Candles[:n]}
+}
+
+// firstSignal returns the shortest prefix of ts whose last candle gives action under the
+// current settings, failing the test when there is none
+func firstSignal(t *testing.T, ts *techan.TimeSeries, action string) *techan.TimeSeries {
+	t.Helper()
+	for n := 2; n <= len(ts.Candles); n++ {
+		prefix := seriesPrefix(ts, n)
+		if analyze("TESTUSDT", prefix) == action {
+			return prefix
+		}
+	}
+	t.Fatalf("no %s signal in %d candles", action, len(ts.Candles))
+	return nil
+}
+
+// withStrategy runs the rest of the test with sc as the strategy, restoring the previous one
+func withStrategy(t *testing.T, sc StrategyConfig) {
+	t.Helper()
+	previous := Strategy
+	Strategy = sc
+	t.Cleanup(func() { Strategy = previous })
+}
+
+// setGlobal sets a package-level setting for the rest of the test
+func setGlobal[T any](t *testing.T, setting *T, value T) {
+	t.Helper()
+	previous := *setting
+	*setting = value
+	t.Cleanup(func() { *setting = previous })
+}
+
+func TestMinEMAATRFilter(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	setGlobal(t, &MinEMAATRMultiple, 0)
+	ts := firstSignal(t, syntheticSeries(t, "chop", 400, 5), "BUY")
+
+	lastIdx := ts.LastIndex()
+	closes := techan.NewClosePriceIndicator(ts)
+	gap := techan.NewEMAIndicator(closes, Strategy.EMAShort).Calculate(lastIdx).
+		Sub(techan.NewEMAIndicator(closes, Strategy.EMALong).Calculate(lastIdx)).Float()
+	gapInATRs := gap / calculateATR(ts, lastIdx, 14)
+
+	tests := []struct {
+		name     string
+		multiple float64
+		want     string
+	}{
+		{"disabled", 0, "BUY"},
+		{"gap above the minimum", gapInATRs / 2, "BUY"},
+		{"gap below the minimum", gapInATRs * 2, "HOLD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setGlobal(t, &MinEMAATRMultiple, tt.multiple)
+			signal := analyzeSignal("TESTUSDT", ts)
+			if signal.Action != tt.want {
+				t.Errorf("action = %s (%s), want %s", signal.Action, signal.Reason, tt.want)
+			}
+			if tt.want == "HOLD" && !strings.Contains(signal.Reason, "ATR") {
+				t.Errorf("reason = %q, want it to name the ATR filter", signal.Reason)
+			}
+		})
+	}
+}
+
+func TestMinEMAATRPendingCross(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	setGlobal(t, &MinEMAATRMultiple, 0)
+	ts := syntheticSeries(t, "chop", 400, 2)
+	cross := firstSignal(t, ts, "BUY").LastIndex()
+
+	closes := techan.NewClosePriceIndicator(ts)
+	emaShort := techan.NewEMAIndicator(closes, Strategy.EMAShort)
+	emaLong := techan.NewEMAIndicator(closes, Strategy.EMALong)
+	gapInATRs := func(i int) float64 {
+		return emaShort.Calculate(i).Sub(emaLong.Calculate(i)).Float() / calculateATR(ts, i, 14)
+	}
+
+	// Twice the gap at the cross holds it back until the EMAs spread further apart
+	multiple := 2 * gapInATRs(cross)
+	widened := cross + 1
+	for ; gapInATRs(widened) < multiple; widened++ {
+		if gapInATRs(widened) <= 0 {
+			t.Fatalf("EMAs crossed back at candle %d before widening", widened)
+		}
+	}
+	if widened == cross+1 {
+		t.Fatalf("gap widened right after the cross at %d; the test needs a pending candle", cross)
+	}
+
+	tests := []struct {
+		name  string
+		index int
+		want  string
+	}{
+		{"at the cross", cross, "HOLD"},
+		{"still narrow", widened - 1, "HOLD"},
+		{"first wide candle", widened, "BUY"},
+		{"already acted on", widened + 1, "HOLD"},
+	}
+	setGlobal(t, &MinEMAATRMultiple, multiple)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := analyzeSignal("TESTUSDT", seriesPrefix(ts, tt.index+1))
+			if signal.Action != tt.want {
+				t.Errorf("candle %d (cross at %d): action = %s (%s), want %s", tt.index, cross, signal.Action, signal.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestMLFallbackWhileUntrained(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	setGlobal(t, &UseMLAnalyze, true)
+	setGlobal[MLPredictor](t, &mlPredictor, nil)
+	ts := syntheticSeries(t, "chop", 200, 5)
+
+	tests := []struct {
+		fallback string
+		want     func(prefix *techan.TimeSeries) string
+	}{
+		{"classic", func(prefix *techan.TimeSeries) string { return analyzeClassic("TESTUSDT", prefix) }},
+		{"hold", func(*techan.TimeSeries) string { return "HOLD" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fallback, func(t *testing.T) {
+			setGlobal(t, &MLFallback, tt.fallback)
+			actions := map[string]int{}
+			for n := 2; n <= len(ts.Candles); n++ {
+				prefix := seriesPrefix(ts, n)
+				got := analyze("TESTUSDT", prefix)
+				if want := tt.want(prefix); got != want {
+					t.Fatalf("candle %d: got %s, want %s", n-1, got, want)
+				}
+				actions[got]++
+			}
+			if tt.fallback == "classic" && actions["BUY"]+actions["SELL"] == 0 {
+				t.Errorf("classic fallback never traded: %v", actions)
+			}
+		})
+	}
+}
+
+func TestParseMLFallback(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"classic", "classic", false},
+		{" HOLD ", "hold", false},
+		{"ml", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseMLFallback(tt.mode)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseMLFallback(%q) = %q, %v; want %q (error %v)", tt.mode, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// klineSeries builds a 15m series from klines
+func klineSeries(klines []BinanceKline) *techan.TimeSeries {
+	ts := techan.NewTimeSeries()
+	for _, kline := range klines {
+		ts.AddCandle(klineToCandle(kline, 15*time.Minute))
+	}
+	return ts
+}
+
+func TestCalculateATR(t *testing.T) {
+	// True ranges: 0 for the first candle (high - low only), then 2, 1 and 4
+	ts := klineSeries(testKlines([]float64{100, 102, 101, 105}))
+	tests := []struct {
+		name   string
+		index  int
+		period int
+		want   float64
+	}{
+		{"minimal index", 2, 3, 1},
+		{"first candle alone", 0, 1, 0},
+		{"later window", 3, 3, 7.0 / 3},
+		{"single period", 3, 1, 4},
+		{"not enough candles", 1, 3, 0},
+		{"index past the end", 4, 3, 0},
+		{"negative index", -1, 1, 0},
+		{"no period", 3, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := calculateATR(ts, tt.index, tt.period); !approxEqual(got, tt.want, 1e-12) {
+			t.Errorf("%s: calculateATR(%d, %d) = %v, want %v", tt.name, tt.index, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestRSIOverboughtFlipsBorderlineBuy(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	setGlobal(t, &MinEMAATRMultiple, 0)
+	setGlobal(t, &MinVolumeSpike, 0)
+	ts := firstSignal(t, syntheticSeries(t, "chop", 400, 5), "BUY")
+	closes := techan.NewClosePriceIndicator(ts)
+	rsi := techan.NewRelativeStrengthIndexIndicator(closes, Strategy.RSIPeriod).Calculate(ts.LastIndex()).Float()
+
+	tests := []struct {
+		name       string
+		overbought float64
+		want       string
+	}{
+		{"default", 70, "BUY"},
+		{"just above the RSI", rsi + 0.5, "BUY"},
+		{"just below the RSI", rsi - 0.5, "HOLD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := DefaultStrategyConfig()
+			sc.RSIOverbought = tt.overbought
+			withStrategy(t, sc)
+			if got := analyzeClassic("TESTUSDT", ts); got != tt.want {
+				t.Errorf("RSI %.2f with overbought %.2f: got %s, want %s", rsi, tt.overbought, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEMAPeriodsChangeSignals(t *testing.T) {
+	ts := syntheticSeries(t, "chop", 400, 5)
+	buys := func(short, long int) []int {
+		sc := DefaultStrategyConfig()
+		sc.EMAShort, sc.EMALong = short, long
+		withStrategy(t, sc)
+		var indexes []int
+		for n := 2; n <= len(ts.Candles); n++ {
+			if analyzeClassic("TESTUSDT", seriesPrefix(ts, n)) == "BUY" {
+				indexes = append(indexes, n-1)
+			}
+		}
+		return indexes
+	}
+
+	defaults, fast := buys(9, 21), buys(5, 13)
+	if len(defaults) == 0 || len(fast) == 0 {
+		t.Fatalf("expected BUYs with both settings, got %v and %v", defaults, fast)
+	}
+	if reflect.DeepEqual(defaults, fast) {
+		t.Errorf("EMA 5/13 gave the same BUYs as 9/21: %v", defaults)
+	}
+}
+
+func TestStrategyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(sc *StrategyConfig)
+		wantErr bool
+	}{
+		{"defaults", func(sc *StrategyConfig) {}, false},
+		{"unknown strategy", func(sc *StrategyConfig) { sc.Name = "astrology" }, true},
+		{"EMA periods reversed", func(sc *StrategyConfig) { sc.EMAShort, sc.EMALong = 21, 9 }, true},
+		{"zero RSI period", func(sc *StrategyConfig) { sc.RSIPeriod = 0 }, true},
+		{"MACD periods reversed", func(sc *StrategyConfig) { sc.MACDFast, sc.MACDSlow = 26, 12 }, true},
+		{"RSI levels reversed", func(sc *StrategyConfig) { sc.RSIOverbought, sc.RSIOversold = 30, 70 }, true},
+		{"RSI above 100", func(sc *StrategyConfig) { sc.RSIOverbought = 101 }, true},
+		{"tight RSI levels", func(sc *StrategyConfig) { sc.RSIOverbought, sc.RSIOversold = 55, 45 }, false},
+		{"stochastic levels reversed", func(sc *StrategyConfig) { sc.StochOverbought, sc.StochOversold = 20, 80 }, true},
+		{"Bollinger period of one", func(sc *StrategyConfig) { sc.BollingerPeriod = 1 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := DefaultStrategyConfig()
+			tt.modify(&sc)
+			if err := sc.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyStrategyFlags(t *testing.T) {
+	tests := []struct {
+		name                          string
+		ema, rsi, rsiLevels, macd     string
+		stoch, stochLevels, bollinger string
+		modify                        func(sc *StrategyConfig)
+		wantErr                       bool
+	}{
+		{name: "nothing set", modify: func(sc *StrategyConfig) {}},
+		{name: "EMA", ema: "5,13", modify: func(sc *StrategyConfig) { sc.EMAShort, sc.EMALong = 5, 13 }},
+		{name: "RSI", rsi: "7", rsiLevels: "80, 20", modify: func(sc *StrategyConfig) {
+			sc.RSIPeriod, sc.RSIOverbought, sc.RSIOversold = 7, 80, 20
+		}},
+		{name: "MACD", macd: "8,17,9", modify: func(sc *StrategyConfig) { sc.MACDFast, sc.MACDSlow, sc.MACDSignal = 8, 17, 9 }},
+		{name: "stochastic", stoch: "5,3,3", stochLevels: "90,10", modify: func(sc *StrategyConfig) {
+			sc.StochK, sc.StochSmooth, sc.StochD, sc.StochOverbought, sc.StochOversold = 5, 3, 3, 90, 10
+		}},
+		{name: "Bollinger", bollinger: "30,2.5", modify: func(sc *StrategyConfig) { sc.BollingerPeriod, sc.BollingerStdDev = 30, 2.5 }},
+		{name: "one EMA period", ema: "9", wantErr: true},
+		{name: "non-numeric RSI", rsi: "fast", wantErr: true},
+		{name: "one RSI level", rsiLevels: "70", wantErr: true},
+		{name: "fractional Bollinger period", bollinger: "20.5,2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DefaultStrategyConfig()
+			err := applyStrategyFlags(&got, "", tt.ema, tt.rsi, tt.rsiLevels, tt.macd, tt.stoch, tt.stochLevels, tt.bollinger)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := DefaultStrategyConfig()
+			tt.modify(&want)
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyStrategyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		modify  func(sc *StrategyConfig)
+		wantErr bool
+	}{
+		{"nothing set", nil, func(sc *StrategyConfig) {}, false},
+		{"periods and levels", map[string]string{
+			"STRATEGY_EMA_SHORT": "5", "STRATEGY_EMA_LONG": " 13 ", "STRATEGY_RSI_OVERBOUGHT": "75.5",
+		}, func(sc *StrategyConfig) { sc.EMAShort, sc.EMALong, sc.RSIOverbought = 5, 13, 75.5 }, false},
+		{"strategy name", map[string]string{"STRATEGY_NAME": " Bollinger "}, func(sc *StrategyConfig) { sc.Name = "bollinger" }, false},
+		{"bad period", map[string]string{"STRATEGY_RSI_PERIOD": "fourteen"}, nil, true},
+		{"bad level", map[string]string{"STRATEGY_RSI_OVERSOLD": "low"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			got := DefaultStrategyConfig()
+			err := applyStrategyEnv(&got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyStrategyEnv() = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := DefaultStrategyConfig()
+			tt.modify(&want)
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// stubPredictor is an MLPredictor that always answers with signal or err
+type stubPredictor struct {
+	signal string
+	err    error
+}
+
+func (p stubPredictor) Predict(symbol string, ts *techan.TimeSeries) (string, error) {
+	return p.signal, p.err
+}
+
+func TestAnalyzeMLUsesPredictor(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	setGlobal(t, &UseMLAnalyze, true)
+	setGlobal(t, &MLFallback, "hold")
+	ts := syntheticSeries(t, "chop", 100, 5)
+
+	tests := []struct {
+		name      string
+		predictor MLPredictor
+		want      string
+	}{
+		{"buy", stubPredictor{signal: "BUY"}, "BUY"},
+		{"sell", stubPredictor{signal: "SELL"}, "SELL"},
+		{"wait", stubPredictor{signal: "WAIT"}, "WAIT"},
+		{"unknown signal", stubPredictor{signal: "MOON"}, "HOLD"},
+		{"prediction error", stubPredictor{err: errors.New("model not trained")}, "HOLD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setGlobal(t, &mlPredictor, tt.predictor)
+			if got := analyzeML("TESTUSDT", ts); got != tt.want {
+				t.Errorf("analyzeML = %s, want %s", got, tt.want)
+			}
+			if got := analyze("TESTUSDT", ts); got != tt.want {
+				t.Errorf("analyze = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolumeSpikeFilter(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	setGlobal(t, &MinEMAATRMultiple, 0)
+	setGlobal(t, &MinVolumeSpike, 0)
+	ts := firstSignal(t, syntheticSeries(t, "chop", 400, 5), "BUY")
+	lastIdx := ts.LastIndex()
+	for _, candle := range ts.Candles {
+		candle.Volume = big.NewDecimal(1000) // Flat average volume before the breakout
+	}
+
+	tests := []struct {
+		name        string
+		minSpike    float64
+		breakoutVol float64
+		want        string
+	}{
+		{"filter off", 0, 1000, "BUY"},
+		{"breakout on average volume", 1.5, 1000, "HOLD"},
+		{"breakout just under the spike", 1.5, 1490, "HOLD"},
+		{"breakout on twice the volume", 1.5, 2000, "BUY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setGlobal(t, &MinVolumeSpike, tt.minSpike)
+			ts.Candles[lastIdx].Volume = big.NewDecimal(tt.breakoutVol)
+			signal := analyzeSignal("TESTUSDT", ts)
+			if signal.Action != tt.want {
+				t.Errorf("action = %s (%s), want %s", signal.Action, signal.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolumeRatio(t *testing.T) {
+	ts := klineSeries(testKlines(flatCloses(6, 100)))
+	for i, volume := range []float64{100, 200, 300, 0, 0, 400} {
+		ts.Candles[i].Volume = big.NewDecimal(volume)
+	}
+	tests := []struct {
+		name     string
+		index    int
+		lookback int
+		want     float64
+	}{
+		{"against the average of the previous candles", 2, 2, 2},
+		{"spike after quiet candles", 5, 3, 4},
+		{"no prior volume", 5, 2, 0},
+		{"not enough history", 1, 2, 0},
+		{"index out of range", 6, 2, 0},
+		{"no lookback", 2, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := volumeRatio(ts, tt.index, tt.lookback); !approxEqual(got, tt.want, 1e-12) {
+				t.Errorf("volumeRatio(%d, %d) = %v, want %v", tt.index, tt.lookback, got, tt.want)
+			}
+		})
+	}
+}
+
+// sineCloses returns count closes oscillating around 100 with the given amplitude and period
+func sineCloses(count int, amplitude float64, period int) []float64 {
+	closes := make([]float64, count)
+	for i := range closes {
+		closes[i] = 100 + amplitude*math.Sin(2*math.Pi*float64(i)/float64(period))
+	}
+	return closes
+}
+
+func TestAnalyzeStochasticOnOscillation(t *testing.T) {
+	sc := DefaultStrategyConfig()
+	sc.Name = "stochastic"
+	withStrategy(t, sc)
+	closes := sineCloses(200, 10, 40)
+	ts := klineSeries(testKlines(closes))
+
+	signals := make(map[string]int)
+	for n := 2; n <= len(ts.Candles); n++ {
+		action := analyzeStochastic("TESTUSDT", seriesPrefix(ts, n))
+		if n-1 < sc.Warmup() && action != "WAIT" {
+			t.Fatalf("candle %d: got %s during the %d-candle warmup", n-1, action, sc.Warmup())
+		}
+		signals[action]++
+		// Crosses come right after a turn: buys below the midline, sells above it
+		if close := closes[n-1]; (action == "BUY" && close >= 100) || (action == "SELL" && close <= 100) {
+			t.Errorf("candle %d: %s at close %.2f", n-1, action, close)
+		}
+		if got := analyze("TESTUSDT", seriesPrefix(ts, n)); got != action {
+			t.Errorf("candle %d: analyze gave %s, analyzeStochastic %s", n-1, got, action)
+		}
+	}
+	if signals["BUY"] == 0 || signals["SELL"] == 0 {
+		t.Errorf("expected both crosses on an oscillating series, got %v", signals)
+	}
+
+	// A strict oversold level leaves no room for the buy cross
+	sc.StochOversold = 0.001
+	withStrategy(t, sc)
+	for n := sc.Warmup() + 1; n <= len(ts.Candles); n++ {
+		if action := analyzeStochastic("TESTUSDT", seriesPrefix(ts, n)); action == "BUY" {
+			t.Fatalf("candle %d: BUY with oversold at %.3f", n-1, sc.StochOversold)
+		}
+	}
+}
+
+func TestStochasticKIndicator(t *testing.T) {
+	tests := []struct {
+		name             string
+		close, low, high float64
+		want             float64
+	}{
+		{"at the low", 90, 90, 110, 0},
+		{"at the high", 110, 90, 110, 100},
+		{"a quarter up", 95, 90, 110, 25},
+		{"flat range", 100, 100, 100, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := stochasticKIndicator{
+				close: techan.NewConstantIndicator(tt.close),
+				low:   techan.NewConstantIndicator(tt.low),
+				high:  techan.NewConstantIndicator(tt.high),
+			}
+			if got := k.Calculate(0).Float(); !approxEqual(got, tt.want, 1e-9) {
+				t.Errorf("got %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeBollinger(t *testing.T) {
+	sc := DefaultStrategyConfig()
+	sc.Name = "bollinger"
+	withStrategy(t, sc)
+	// A quiet market alternating between 100 and 101 keeps the bands about a dollar wide
+	base := make([]float64, sc.Warmup()+5)
+	for i := range base {
+		base[i] = 100 + float64(i%2)
+	}
+	extend := func(closes ...float64) []float64 {
+		return append(append([]float64(nil), base...), closes...)
+	}
+
+	tests := []struct {
+		name   string
+		closes []float64
+		want   string // Signal on the last candle
+	}{
+		{"quiet market", base, "HOLD"},
+		{"pierces the lower band", extend(90), "HOLD"},
+		{"reverts after piercing", extend(90, 92), "BUY"},
+		{"keeps falling", extend(90, 88), "HOLD"},
+		{"pierces the upper band", extend(112), "SELL"},
+		{"stays above the upper band", extend(112, 115), "HOLD"},
+		{"warmup", base[:sc.Warmup()-1], "WAIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := klineSeries(testKlines(tt.closes))
+			if got := analyzeBollinger("TESTUSDT", ts); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if got := analyze("TESTUSDT", ts); got != tt.want {
+				t.Errorf("analyze dispatched to %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// A wide enough multiplier keeps the same drop inside the bands
+	sc.BollingerStdDev = 50
+	withStrategy(t, sc)
+	if got := analyzeBollinger("TESTUSDT", klineSeries(testKlines(extend(90, 92)))); got != "HOLD" {
+		t.Errorf("with %.0f standard deviations got %s, want HOLD", sc.BollingerStdDev, got)
+	}
+}
+
+// alwaysStrategy is a SignalStrategy that gives the same action on every candle
+type alwaysStrategy struct {
+	name   string
+	action string
+}
+
+func (s alwaysStrategy) Name() string { return s.name }
+
+func (s alwaysStrategy) Evaluate(symbol string, ts *techan.TimeSeries, index int) string {
+	return s.action
+}
+
+// registerForTest registers s for the rest of the test
+func registerForTest(t *testing.T, s SignalStrategy) {
+	t.Helper()
+	RegisterStrategy(s)
+	t.Cleanup(func() { delete(strategyRegistry, s.Name()) })
+}
+
+func TestStrategyRegistry(t *testing.T) {
+	registerForTest(t, alwaysStrategy{"always-buy", "BUY"})
+	registerForTest(t, alwaysStrategy{"always-sell", "SELL"})
+	ts := syntheticSeries(t, "chop", 100, 5)
+
+	tests := []struct {
+		name     string
+		strategy string
+		useML    bool
+		want     Signal
+	}{
+		{"custom buy", "always-buy", false, Signal{Action: "BUY", Confidence: 1, Reason: "always-buy rules"}},
+		{"custom sell", "always-sell", false, Signal{Action: "SELL", Confidence: 1, Reason: "always-sell rules"}},
+		{"unknown strategy holds", "nope", false, Signal{Action: "HOLD", Reason: "unknown strategy nope"}},
+		{"UseMLAnalyze overrides the name", "always-buy", true, Signal{Action: "SELL", Confidence: 1, Reason: "ml rules"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := DefaultStrategyConfig()
+			sc.Name = tt.strategy
+			withStrategy(t, sc)
+			setGlobal(t, &UseMLAnalyze, tt.useML)
+			setGlobal[MLPredictor](t, &mlPredictor, stubPredictor{signal: "SELL"})
+			logs := captureLog(t)
+
+			if got := analyzeSignal("TESTUSDT", ts); got != tt.want {
+				t.Errorf("analyzeSignal = %+v, want %+v", got, tt.want)
+			}
+			if got := analyze("TESTUSDT", ts); got != tt.want.Action {
+				t.Errorf("analyze = %s, want %s", got, tt.want.Action)
+			}
+			if unknown := strings.Contains(logs.String(), "Unknown strategy"); unknown != (tt.strategy == "nope") {
+				t.Errorf("unknown-strategy log = %v, want %v: %q", unknown, tt.strategy == "nope", logs.String())
+			}
+		})
+	}
+
+	names := RegisteredStrategies()
+	registered := make(map[string]bool, len(names))
+	for _, name := range names {
+		registered[name] = true
+	}
+	for _, want := range []string{"always-buy", "always-sell", "bollinger", "classic", "ml", "stochastic"} {
+		if !registered[want] {
+			t.Errorf("registered strategies %v are missing %s", names, want)
+		}
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("registered strategies %v aren't sorted", names)
+	}
+
+	sc := DefaultStrategyConfig()
+	sc.Name = "always-buy"
+	if err := sc.Validate(); err != nil {
+		t.Errorf("a registered custom strategy should validate: %v", err)
+	}
+}
+
+func TestRegisterStrategyPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy SignalStrategy
+	}{
+		{"empty name", alwaysStrategy{"", "BUY"}},
+		{"duplicate name", alwaysStrategy{"classic", "BUY"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q didn't panic", tt.strategy.Name())
+				}
+			}()
+			RegisterStrategy(tt.strategy)
+		})
+	}
+	if _, ok := strategyRegistry["classic"].(funcStrategy); !ok {
+		t.Error("the built-in classic strategy was replaced")
+	}
+}
+
+// trendCloses returns count closes moving step per candle from 100
+func trendCloses(count int, step float64) []float64 {
+	closes := make([]float64, count)
+	for i := range closes {
+		closes[i] = 100 + step*float64(i)
+	}
+	return closes
+}
+
+func TestAnalyzeWithConfirmation(t *testing.T) {
+	registerForTest(t, alwaysStrategy{"always-buy", "BUY"})
+	registerForTest(t, alwaysStrategy{"always-sell", "SELL"})
+	ts := klineSeries(testKlines(flatCloses(50, 100)))
+	rising := klineSeries(testKlines(trendCloses(40, 1)))
+	falling := klineSeries(testKlines(trendCloses(40, -1)))
+
+	tests := []struct {
+		name     string
+		strategy string
+		higher   *techan.TimeSeries
+		want     string
+	}{
+		{"no higher timeframe", "always-buy", nil, "BUY"},
+		{"higher trend rising", "always-buy", rising, "BUY"},
+		{"higher trend falling", "always-buy", falling, "HOLD"},
+		{"higher series shorter than the EMA", "always-buy", seriesPrefix(rising, 10), "HOLD"},
+		{"sells aren't filtered", "always-sell", falling, "SELL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := DefaultStrategyConfig()
+			sc.Name = tt.strategy
+			withStrategy(t, sc)
+			if got := analyzeWithConfirmation("TESTUSDT", ts, tt.higher); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassicSignalReasonAndConfidence(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	ts := syntheticSeries(t, "chop", 400, 5)
+
+	tests := []struct {
+		action     string
+		wantReason string
+	}{
+		{"BUY", "EMA 9/21 cross up + RSI "},
+		{"SELL", "EMA 9/21 cross down + RSI "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			signal := analyzeSignal("TESTUSDT", firstSignal(t, ts, tt.action))
+			if signal.Action != tt.action {
+				t.Fatalf("action = %s, want %s", signal.Action, tt.action)
+			}
+			if !strings.HasPrefix(signal.Reason, tt.wantReason) || !strings.HasSuffix(signal.Reason, "+ MACD confirm") {
+				t.Errorf("reason = %q, want %q... + MACD confirm", signal.Reason, tt.wantReason)
+			}
+			if signal.Confidence <= 0 || signal.Confidence > 1 {
+				t.Errorf("confidence = %.3f, want it in (0, 1]", signal.Confidence)
+			}
+		})
+	}
+
+	wait := analyzeSignal("TESTUSDT", seriesPrefix(ts, 10))
+	if wait != (Signal{Action: "WAIT", Reason: "warming up indicators"}) {
+		t.Errorf("during warmup got %+v", wait)
+	}
+	for n := DefaultStrategyConfig().Warmup() + 1; n <= len(ts.Candles); n++ {
+		if signal := analyzeSignal("TESTUSDT", seriesPrefix(ts, n)); signal.Action == "HOLD" {
+			if signal.Confidence != 0 || signal.Reason == "" {
+				t.Errorf("HOLD at candle %d: %+v, want zero confidence and a reason", n-1, signal)
+			}
+			break
+		}
+	}
+}
+
+func TestAtrMultiple(t *testing.T) {
+	tests := []struct {
+		name        string
+		spread, atr float64
+		want        float64
+	}{
+		{"half an ATR", 0.5, 2, 0.25},
+		{"capped at one", 10, 2, 1},
+		{"unknown ATR", 0.5, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := atrMultiple(tt.spread, tt.atr); got != tt.want {
+				t.Errorf("atrMultiple(%v, %v) = %v, want %v", tt.spread, tt.atr, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,15 +16,58 @@ import (
 
 // BacktestConfig holds configuration for backtesting
 type BacktestConfig struct {
-	Symbol           string
-	InitialBalance   float64
-	TransactionFee   float64 // Fee percentage (e.g., 0.001 for 0.1%)
-	StartDate        time.Time
-	EndDate          time.Time
-	Interval         string
-	DataLimit        int // Number of candles to fetch
+	Symbol                 string
+	InitialBalance         float64
+	TransactionFee         float64            // Fee percentage (e.g., 0.001 for 0.1%) for whichever of MakerFee/TakerFee is unset
+	MakerFee               *float64           // Fee percentage for limit fills; nil falls back to TransactionFee
+	TakerFee               *float64           // Fee percentage for market fills; nil falls back to TransactionFee
+	FeeOverrides           map[string]float64 // Per-symbol fee percentages overriding TransactionFee
+	StartDate              time.Time
+	EndDate                time.Time
+	Interval               string
+	DataLimit              int            // Number of candles to fetch
+	VaRConfidence          float64        // Confidence level for VaR/CVaR (e.g., 0.95); defaults to 0.95
+	MaxTradesPerDay        int            // Maximum new entries per day (0 = unlimited)
+	Timezone               *time.Location // Timezone for day boundaries; defaults to UTC
+	TradingCalendar        string         // Calendar for annualization: "24x7" (default) or "weekdays"
+	EntryTiming            string         // Fill at the signal candle's "close" (default) or the "next_open"
+	ParticipationRate      float64        // Max fraction of a candle's traded volume per fill for capacity; defaults to 0.01
+	ZeroFee                bool           // Ignore all fees for an idealized, signal-only upper bound
+	WinRateWindow          int            // Round trips per rolling win-rate window; defaults to 10
+	TradeDecay             float64        // Weight decay per older round trip in the weighted win rate/expectancy; defaults to 0.9
+	StrictData             bool           // Abort when the data-quality check finds serious issues
+	StopLossPct            float64        // Stop-loss distance below entry as a fraction (e.g., 0.02); 0 disables
+	TrailingStopPct        float64        // Trailing stop distance below the highest close since entry as a fraction (e.g., 0.03); 0 disables
+	TakeProfitPct          float64        // Take-profit distance above entry as a fraction (e.g., 0.04); 0 disables
+	BracketTieBreak        string         // Exit when one candle spans both levels: "stop" (default) or "target"
+	QuietSkips             bool           // Only count skipped trades instead of logging each one
+	PositionSizePct        float64        // Fraction of cash each BUY deploys (e.g., 0.25); 0 or 1 = all-in
+	ScaleOutPct            float64        // Fraction of the holding each SELL signal closes; 0 or 1 = full exit
+	AllowShorting          bool           // Let a SELL with no long position open a short, closed by the next BUY
+	SlippagePct            float64        // Adverse fill slippage as a fraction of price (e.g., 0.0005); ignored with ZeroFee
+	MaxCurvePoints         int            // Cap on stored equity-curve/return points, downsampled evenly; 0 keeps all
+	RiskFreeRate           float64        // Annual risk-free rate subtracted in the Sharpe ratio (e.g., 0.04)
+	ConfirmInterval        string         // Higher interval whose rising EMA must confirm each BUY (e.g., "1h"); empty disables
+	MinHoldPeriods         int            // Candles after an entry before a signal may close the position; 0 disables
+	CooldownPeriods        int            // Candles after an exit before a signal may open a new position; 0 disables
+	StopLossLockoutPeriods int            // Candles after a stop-loss or trailing-stop exit before a signal may open a new position; 0 disables
+	BenchmarkSymbol        string         // Symbol the per-period returns are regressed on for Alpha/Beta (e.g., "BTCUSDT"); empty disables
 }
 
+
+// Order types passed to ExecuteTrade: market orders pay TakerFee, limit orders MakerFee
+const (
+	OrderTypeMarket = "MARKET"
+	OrderTypeLimit  = "LIMIT"
+)
+
+// minTradeNotional is the smallest order value (USD) executed, so rounding dust left in cash
+// after an all-in fill isn't traded
+const minTradeNotional = 0.01
+
+// minTradesForVaR is the number of round trips below which VaR/CVaR are flagged as low confidence
+const minTradesForVaR = 20
+
 // Trade represents a single trade execution
 type Trade struct {
 	Symbol      string
@@ -35,36 +80,85 @@ type Trade struct {
 	TotalValue  float64
 }
 
+// RoundTrip represents a completed entry/exit pair
+type RoundTrip struct {
+	Symbol     string
+	EntryTime  time.Time
+	ExitTime   time.Time
+	EntryPrice float64
+	ExitPrice  float64
+	Quantity   float64
+	PnL        float64
+	Return     float64 // PnL as a fraction of the entry cost
+	ExitType   string  // Trade type that closed the position: SELL, COVER, STOP, TARGET or TRAIL
+	Short      bool    // Position was a short sale
+	RMultiple  float64 // PnL in units of the initial stop-loss risk (0 without a stop)
+}
+
 // BacktestResult holds the results of a backtest
 type BacktestResult struct {
-	Symbol            string
-	InitialBalance    float64
-	FinalBalance      float64
-	FinalValue        float64
-	TotalReturn       float64
-	TotalReturnPct    float64
-	MaxDrawdown       float64
-	MaxDrawdownPct    float64
-	WinRate           float64
-	TotalTrades       int
-	WinningTrades     int
-	LosingTrades      int
-	AverageWin        float64
-	AverageLoss       float64
-	SharpeRatio       float64
-	Trades            []Trade
-	DailyReturns      []float64
-	EquityCurve       []float64
-	Duration          time.Duration
-	BuyAndHoldReturn  float64
+	Symbol              string
+	InitialBalance      float64
+	FinalBalance        float64
+	FinalValue          float64
+	TotalReturn         float64
+	TotalReturnPct      float64
+	MaxDrawdown         float64
+	MaxDrawdownPct      float64
+	WinRate             float64
+	TotalTrades         int
+	WinningTrades       int
+	LosingTrades        int
+	AverageWin          float64
+	AverageLoss         float64
+	SharpeRatio         float64
+	Trades              []Trade
+	DailyReturns        []float64
+	EquityCurve         []float64
+	Duration            time.Duration
+	BuyAndHoldReturn    float64
 	BuyAndHoldReturnPct float64
+	RoundTrips          []RoundTrip
+	VaRConfidence       float64
+	VaRPct              float64 // Historical VaR of per-trade returns, as a positive loss percentage
+	CVaRPct             float64 // Expected shortfall beyond VaR, as a positive loss percentage
+	ExposurePct         float64 // Percentage of simulated candles with an open position
+	KellyFraction       float64 // Kelly criterion fraction of capital per trade (0 when there is no edge)
+	CapacityUSD         float64 // Max capital per trade without exceeding ParticipationRate of entry candle volume
+	ParticipationRate   float64
+	ZeroFee             bool
+	RollingWinRate      []float64 // Win rate (%) over each window of consecutive round trips
+	WinRateWindow       int
+	ReturnAutocorr      float64        // Lag-1 autocorrelation of per-trade returns (>0 streaky, <0 alternating)
+	SkippedTrades       map[string]int // Signals that didn't execute, by reason
+	ExpectancyPct       float64        // Mean return per completed round trip, in percent
+	WeightedWinRate     float64        // Win rate (%) with each older round trip weighted by TradeDecay
+	WeightedExpectancy  float64        // Expectancy (%) with each older round trip weighted by TradeDecay
+	TradeDecay          float64
+	Strategy            StrategyConfig // Indicator settings the run used
+	AvgRMultiple        float64        // Expectancy in R: mean RMultiple over round trips (0 without a stop)
+	BestTrade           *RoundTrip     // Round trip with the highest P&L; nil without round trips
+	WorstTrade          *RoundTrip     // Round trip with the lowest P&L; nil without round trips
+	BenchmarkSymbol     string         // Benchmark Alpha and Beta were measured against; empty when not computed
+	Alpha               float64        // Annualized OLS intercept of per-period returns on the benchmark's, in percent
+	Beta                float64        // OLS slope of per-period returns on the benchmark's
+}
+
+// Bracket is a one-cancels-other pair of exit levels armed on entry; a zero level is disabled
+type Bracket struct {
+	Stop   float64
+	Target float64
+	Short  bool    // Levels protect a short: stop above entry, target below
+	Peak   float64 // Best close since entry (highest long, lowest short) the trailing stop follows
+	Trail  float64 // Trailing stop level TrailingStopPct behind Peak
 }
 
 // Portfolio represents the current portfolio state
 type Portfolio struct {
-	Cash       float64
-	Holdings   map[string]float64 // symbol -> quantity
-	LastPrices map[string]float64 // symbol -> last price
+	Cash          float64
+	Holdings      map[string]float64 // symbol -> quantity (negative when short)
+	LastPrices    map[string]float64 // symbol -> last price
+	AvgEntryPrice map[string]float64 // symbol -> quantity-weighted average entry price
 }
 
 // BacktestEngine performs backtesting operations
@@ -72,8 +166,22 @@ type BacktestEngine struct {
 	config    BacktestConfig
 	portfolio Portfolio
 	trades    []Trade
+	bracket   *Bracket // Exit levels for the open position, nil when flat or disabled
+	skipped   map[string]int
 	startTime time.Time
 	endTime   time.Time
+	
+	bar          int            // Index of the candle being simulated
+	lastEntryBar map[string]int // Candle of each symbol's last entry, for MinHoldPeriods
+	lastExitBar  map[string]int // Candle of each symbol's last exit, for CooldownPeriods
+	lastStopBar  map[string]int // Candle of each symbol's last stop exit, for StopLossLockoutPeriods
+	
+	confirmKlines   []BinanceKline // ConfirmInterval klines; aggregated from the main klines when nil
+	benchmarkKlines []BinanceKline // BenchmarkSymbol klines over the same span; Alpha/Beta are skipped when nil
+	
+	// ProgressFunc, when set, is called after each simulated candle with the number of
+	// candles processed so far and the total to process (candles after the warmup)
+	ProgressFunc func(done, total int)
 }
 
 // NewBacktestEngine creates a new backtesting engine
@@ -81,11 +189,35 @@ func NewBacktestEngine(config BacktestConfig) *BacktestEngine {
 	return &BacktestEngine{
 		config: config,
 		portfolio: Portfolio{
-			Cash:       config.InitialBalance,
-			Holdings:   make(map[string]float64),
-			LastPrices: make(map[string]float64),
+			Cash:          config.InitialBalance,
+			Holdings:      make(map[string]float64),
+			LastPrices:    make(map[string]float64),
+			AvgEntryPrice: make(map[string]float64),
 		},
-		trades: make([]Trade, 0),
+		trades:       make([]Trade, 0),
+		skipped:      make(map[string]int),
+		lastEntryBar: make(map[string]int),
+		lastExitBar:  make(map[string]int),
+		lastStopBar:  make(map[string]int),
+	}
+}
+
+// Skipped-trade reasons counted in BacktestResult.SkippedTrades
+const (
+	skipInsufficientFunds = "insufficient funds"
+	skipNoHoldings        = "no holdings to sell"
+	skipDailyLimit        = "daily trade limit"
+	skipAlreadyShort      = "already short"
+	skipMinHold           = "minimum hold"
+	skipCooldown          = "cooldown"
+	skipStopLockout       = "stop-loss lockout"
+)
+
+// skipTrade counts a trade that couldn't execute and logs it unless QuietSkips is set
+func (be *BacktestEngine) skipTrade(reason string, format string, args ...interface{}) {
+	be.skipped[reason]++
+	if !be.config.QuietSkips {
+		log.Printf(format, args...)
 	}
 }
 
@@ -102,26 +234,75 @@ func (be *BacktestEngine) GetPortfolioValue() float64 {
 	return totalValue
 }
 
-// ExecuteTrade executes a buy or sell trade
-func (be *BacktestEngine) ExecuteTrade(symbol, tradeType string, price float64, timestamp time.Time) bool {
-	fee := price * be.config.TransactionFee
+// feeRate returns the fee percentage for a symbol and order type, honoring per-symbol
+// overrides, which apply to both maker and taker fills
+func (be *BacktestEngine) feeRate(symbol, orderType string) float64 {
+	if be.config.ZeroFee {
+		return 0
+	}
+	if fee, exists := be.config.FeeOverrides[symbol]; exists {
+		return fee
+	}
+	// TransactionFee covers whichever of the maker/taker rates is not given
+	if orderType == OrderTypeLimit {
+		if be.config.MakerFee != nil {
+			return *be.config.MakerFee
+		}
+	} else if be.config.TakerFee != nil {
+		return *be.config.TakerFee
+	}
+	return be.config.TransactionFee
+}
+
+// slippedPrice moves price against the trade by SlippagePct: buys fill higher, sells lower
+func (be *BacktestEngine) slippedPrice(price float64, buying bool) float64 {
+	if be.config.ZeroFee || be.config.SlippagePct <= 0 {
+		return price
+	}
+	if buying {
+		return price * (1 + be.config.SlippagePct)
+	}
+	return price * (1 - be.config.SlippagePct)
+}
+
+// ExecuteTrade executes a buy or sell trade at price adjusted for slippage, paying the taker
+// fee for OrderTypeMarket and the maker fee for OrderTypeLimit. With AllowShorting, a SELL
+// while flat opens a short and a BUY (or bracket exit) while short covers it.
+func (be *BacktestEngine) ExecuteTrade(symbol, tradeType, orderType string, price float64, timestamp time.Time) bool {
+	defer be.recordTradeBar(symbol, len(be.trades))
+	
+	held := be.portfolio.Holdings[symbol]
+	buying := tradeType == "BUY" || (held < 0 && tradeType != "SELL")
+	price = be.slippedPrice(price, buying)
+	fee := price * be.feeRate(symbol, orderType)
+	
+	if held < 0 && tradeType != "SELL" {
+		return be.coverShort(symbol, tradeType, price, fee, timestamp)
+	} else if held <= 0 && tradeType == "SELL" && be.config.AllowShorting {
+		return be.openShort(symbol, price, fee, timestamp)
+	}
 	
 	switch tradeType {
 	case "BUY":
-		// Calculate maximum quantity we can buy
+		// Calculate maximum quantity we can buy with this trade's share of cash
 		availableCash := be.portfolio.Cash
+		if pct := be.config.PositionSizePct; pct > 0 && pct < 1 {
+			availableCash *= pct
+		}
 		costPerUnit := price + fee
 		maxQuantity := availableCash / costPerUnit
 		
-		if maxQuantity <= 0 {
-			log.Printf("Insufficient funds to buy %s at $%.2f", symbol, price)
+		if maxQuantity*price < minTradeNotional {
+			be.skipTrade(skipInsufficientFunds, "Insufficient funds to buy %s at $%.2f", symbol, price)
 			return false
 		}
 		
 		totalCost := maxQuantity * price
 		totalFee := maxQuantity * fee
 		
-		// Update portfolio
+		// Update portfolio, averaging the entry price across adds
+		held := be.portfolio.Holdings[symbol]
+		be.portfolio.AvgEntryPrice[symbol] = (held*be.portfolio.AvgEntryPrice[symbol] + totalCost) / (held + maxQuantity)
 		be.portfolio.Cash -= (totalCost + totalFee)
 		be.portfolio.Holdings[symbol] += maxQuantity
 		be.portfolio.LastPrices[symbol] = price
@@ -141,24 +322,37 @@ func (be *BacktestEngine) ExecuteTrade(symbol, tradeType string, price float64,
 		
 		log.Printf("BUY: %.6f %s at $%.2f (Fee: $%.2f, Cash: $%.2f)", 
 			maxQuantity, symbol, price, totalFee, be.portfolio.Cash)
+		be.armBracket(be.portfolio.AvgEntryPrice[symbol])
 		return true
 		
-	case "SELL":
+	case "SELL", "STOP", "TARGET", "TRAIL":
 		// Check if we have holdings to sell
-		quantity, exists := be.portfolio.Holdings[symbol]
-		if !exists || quantity <= 0 {
-			log.Printf("No holdings to sell for %s", symbol)
+		held, exists := be.portfolio.Holdings[symbol]
+		if !exists || held <= 0 {
+			be.skipTrade(skipNoHoldings, "No holdings to sell for %s", symbol)
 			return false
 		}
 		
+		// Signal exits may scale out; stop and target exits always close everything
+		quantity := held
+		if pct := be.config.ScaleOutPct; tradeType == "SELL" && pct > 0 && pct < 1 {
+			quantity = held * pct
+		}
+		
 		totalRevenue := quantity * price
 		totalFee := quantity * fee
 		netRevenue := totalRevenue - totalFee
 		
 		// Update portfolio
 		be.portfolio.Cash += netRevenue
-		delete(be.portfolio.Holdings, symbol)
 		be.portfolio.LastPrices[symbol] = price
+		closed := quantity == held
+		if closed {
+			delete(be.portfolio.Holdings, symbol)
+			delete(be.portfolio.AvgEntryPrice, symbol)
+		} else {
+			be.portfolio.Holdings[symbol] = held - quantity
+		}
 		
 		// Record trade
 		trade := Trade{
@@ -173,20 +367,298 @@ func (be *BacktestEngine) ExecuteTrade(symbol, tradeType string, price float64,
 		}
 		be.trades = append(be.trades, trade)
 		
-		log.Printf("SELL: %.6f %s at $%.2f (Fee: $%.2f, Cash: $%.2f)", 
-			quantity, symbol, price, totalFee, be.portfolio.Cash)
+		log.Printf("%s: %.6f %s at $%.2f (Fee: $%.2f, Cash: $%.2f)", 
+			tradeType, quantity, symbol, price, totalFee, be.portfolio.Cash)
+		if closed {
+			be.bracket = nil // One exit cancels the other
+		}
 		return true
 	}
 	
 	return false
 }
 
+// openShort sells borrowed units with this trade's share of cash as collateral, recording a
+// SHORT trade. The sale proceeds are credited to cash and the holding goes negative.
+func (be *BacktestEngine) openShort(symbol string, price, fee float64, timestamp time.Time) bool {
+	if be.portfolio.Holdings[symbol] < 0 {
+		be.skipTrade(skipAlreadyShort, "Already short %s, ignoring SELL", symbol)
+		return false
+	}
+	
+	availableCash := be.portfolio.Cash
+	if pct := be.config.PositionSizePct; pct > 0 && pct < 1 {
+		availableCash *= pct
+	}
+	quantity := availableCash / (price + fee)
+	if quantity*price < minTradeNotional {
+		be.skipTrade(skipInsufficientFunds, "Insufficient funds to short %s at $%.2f", symbol, price)
+		return false
+	}
+	
+	totalFee := quantity * fee
+	be.portfolio.Cash += quantity*price - totalFee
+	be.portfolio.Holdings[symbol] = -quantity
+	be.portfolio.AvgEntryPrice[symbol] = price
+	be.portfolio.LastPrices[symbol] = price
+	
+	be.trades = append(be.trades, Trade{
+		Symbol:     symbol,
+		Type:       "SHORT",
+		Price:      price,
+		Quantity:   quantity,
+		Timestamp:  timestamp,
+		Fee:        totalFee,
+		Balance:    be.portfolio.Cash,
+		TotalValue: be.GetPortfolioValue(),
+	})
+	
+	log.Printf("SHORT: %.6f %s at $%.2f (Fee: $%.2f, Cash: $%.2f)",
+		quantity, symbol, price, totalFee, be.portfolio.Cash)
+	be.armBracket(price)
+	return true
+}
+
+// coverShort buys back the whole short position, recording a COVER trade, or a STOP/TARGET/TRAIL
+// trade when the bracket triggered it
+func (be *BacktestEngine) coverShort(symbol, tradeType string, price, fee float64, timestamp time.Time) bool {
+	quantity := -be.portfolio.Holdings[symbol]
+	if tradeType == "BUY" {
+		tradeType = "COVER"
+	}
+	
+	totalFee := quantity * fee
+	be.portfolio.Cash -= quantity*price + totalFee
+	delete(be.portfolio.Holdings, symbol)
+	delete(be.portfolio.AvgEntryPrice, symbol)
+	be.portfolio.LastPrices[symbol] = price
+	
+	be.trades = append(be.trades, Trade{
+		Symbol:     symbol,
+		Type:       tradeType,
+		Price:      price,
+		Quantity:   quantity,
+		Timestamp:  timestamp,
+		Fee:        totalFee,
+		Balance:    be.portfolio.Cash,
+		TotalValue: be.GetPortfolioValue(),
+	})
+	
+	log.Printf("%s: %.6f %s at $%.2f (Fee: $%.2f, Cash: $%.2f)",
+		tradeType, quantity, symbol, price, totalFee, be.portfolio.Cash)
+	be.bracket = nil
+	return true
+}
+
+// recordTradeBar notes the current candle as symbol's last entry or exit when a trade was
+// appended after the first tradesBefore
+func (be *BacktestEngine) recordTradeBar(symbol string, tradesBefore int) {
+	if len(be.trades) == tradesBefore {
+		return
+	}
+	
+	tradeType := be.trades[len(be.trades)-1].Type
+	if isEntryTrade(tradeType) {
+		be.lastEntryBar[symbol] = be.bar
+	} else if isExitTrade(tradeType) {
+		be.lastExitBar[symbol] = be.bar
+	}
+	if tradeType == "STOP" || tradeType == "TRAIL" {
+		be.lastStopBar[symbol] = be.bar
+	}
+}
+
+// isEntryTrade reports whether a trade type opens or adds to a position
+func isEntryTrade(tradeType string) bool {
+	return tradeType == "BUY" || tradeType == "SHORT"
+}
+
+// isExitTrade reports whether a trade type closes a position
+func isExitTrade(tradeType string) bool {
+	return tradeType == "SELL" || tradeType == "COVER" || tradeType == "STOP" || tradeType == "TARGET" || tradeType == "TRAIL"
+}
+
+// armBracket sets the stop-loss, take-profit and trailing stop levels for a position entered
+// at price, mirrored around the entry when the position is short. Adding to an open position
+// re-arms the stop and target at the new average entry but keeps the trailing stop's peak.
+func (be *BacktestEngine) armBracket(price float64) {
+	if be.config.StopLossPct <= 0 && be.config.TakeProfitPct <= 0 && be.config.TrailingStopPct <= 0 {
+		return
+	}
+	
+	bracket := &Bracket{Short: be.portfolio.Holdings[be.config.Symbol] < 0}
+	if previous := be.bracket; previous != nil && previous.Short == bracket.Short {
+		bracket.Peak, bracket.Trail = previous.Peak, previous.Trail
+	}
+	side := 1.0
+	if bracket.Short {
+		side = -1
+	}
+	if be.config.StopLossPct > 0 {
+		bracket.Stop = price * (1 - side*be.config.StopLossPct)
+	}
+	if be.config.TakeProfitPct > 0 {
+		bracket.Target = price * (1 + side*be.config.TakeProfitPct)
+	}
+	be.bracket = bracket
+	be.updateTrailingStop(price)
+}
+
+// updateTrailingStop moves the trailing stop behind a new best close. It only ever tightens:
+// up for a long, down for a short.
+func (be *BacktestEngine) updateTrailingStop(close float64) {
+	if be.bracket == nil || be.config.TrailingStopPct <= 0 {
+		return
+	}
+	
+	if be.bracket.Short {
+		if be.bracket.Peak == 0 || close < be.bracket.Peak {
+			be.bracket.Peak = close
+			be.bracket.Trail = close * (1 + be.config.TrailingStopPct)
+		}
+	} else if close > be.bracket.Peak {
+		be.bracket.Peak = close
+		be.bracket.Trail = close * (1 - be.config.TrailingStopPct)
+	}
+}
+
+// checkBracket closes the open position if the candle reached its stop or target. A candle
+// that opens beyond a level fills at the open; one whose range spans both levels can't tell
+// which came first, so BracketTieBreak decides (stop by default, the conservative choice).
+// The trailing stop replaces the fixed stop once it is tighter, exiting as TRAIL. Stops fill
+// as market orders and targets as resting limit orders.
+func (be *BacktestEngine) checkBracket(open, high, low float64, timestamp time.Time) {
+	if be.bracket == nil || be.portfolio.Holdings[be.config.Symbol] == 0 {
+		return
+	}
+	
+	stop, target := be.bracket.Stop, be.bracket.Target
+	stopType := "STOP"
+	if trail := be.bracket.Trail; trail > 0 &&
+		(stop == 0 || (be.bracket.Short && trail < stop) || (!be.bracket.Short && trail > stop)) {
+		stop, stopType = trail, "TRAIL"
+	}
+	var stopHit, targetHit, stopGapped, targetGapped bool
+	if be.bracket.Short {
+		stopHit = stop > 0 && high >= stop
+		targetHit = target > 0 && low <= target
+		stopGapped = stopHit && open >= stop
+		targetGapped = targetHit && open <= target
+	} else {
+		stopHit = stop > 0 && low <= stop
+		targetHit = target > 0 && high >= target
+		stopGapped = stopHit && open <= stop
+		targetGapped = targetHit && open >= target
+	}
+	
+	switch {
+	case stopGapped:
+		be.ExecuteTrade(be.config.Symbol, stopType, OrderTypeMarket, open, timestamp)
+	case targetGapped:
+		be.ExecuteTrade(be.config.Symbol, "TARGET", OrderTypeLimit, open, timestamp)
+	case stopHit && targetHit:
+		if be.config.BracketTieBreak == "target" {
+			be.ExecuteTrade(be.config.Symbol, "TARGET", OrderTypeLimit, target, timestamp)
+		} else {
+			be.ExecuteTrade(be.config.Symbol, stopType, OrderTypeMarket, stop, timestamp)
+		}
+	case stopHit:
+		be.ExecuteTrade(be.config.Symbol, stopType, OrderTypeMarket, stop, timestamp)
+	case targetHit:
+		be.ExecuteTrade(be.config.Symbol, "TARGET", OrderTypeLimit, target, timestamp)
+	}
+}
+
+// dailyEntryLimitReached reports whether MaxTradesPerDay entries were already made on the
+// day of timestamp, in the configured timezone
+func (be *BacktestEngine) dailyEntryLimitReached(timestamp time.Time) bool {
+	if be.config.MaxTradesPerDay <= 0 {
+		return false
+	}
+	
+	loc := be.config.Timezone
+	if loc == nil {
+		loc = time.UTC
+	}
+	
+	year, month, day := timestamp.In(loc).Date()
+	entries := 0
+	for _, trade := range be.trades {
+		if !isEntryTrade(trade.Type) {
+			continue
+		}
+		y, m, d := trade.Timestamp.In(loc).Date()
+		if y == year && m == month && d == day {
+			entries++
+		}
+	}
+	
+	return entries >= be.config.MaxTradesPerDay
+}
+
+// executeSignal acts on a BUY/SELL signal, filling at the given price. Covering a short is
+// an exit, so only BUYs from flat/long and short-opening SELLs count toward the daily limit.
+func (be *BacktestEngine) executeSignal(signal string, price float64, timestamp time.Time) {
+	if signal != "BUY" && signal != "SELL" {
+		return
+	}
+	
+	holding := be.portfolio.Holdings[be.config.Symbol]
+	isEntry := (signal == "BUY" && holding >= 0) ||
+		(signal == "SELL" && be.config.AllowShorting && holding <= 0)
+	if isEntry && be.dailyEntryLimitReached(timestamp) {
+		be.skipTrade(skipDailyLimit, "Daily trade limit reached, skipping %s for %s at %s",
+			signal, be.config.Symbol, timestamp.Format("2006-01-02 15:04"))
+		return
+	}
+	
+	// Space trades out so a signal flipping every candle doesn't churn fees
+	isExit := (signal == "SELL" && holding > 0) || (signal == "BUY" && holding < 0)
+	if entryBar, exists := be.lastEntryBar[be.config.Symbol]; isExit && exists &&
+		be.bar-entryBar < be.config.MinHoldPeriods {
+		be.skipTrade(skipMinHold, "Minimum hold not reached, skipping %s for %s at %s",
+			signal, be.config.Symbol, timestamp.Format("2006-01-02 15:04"))
+		return
+	}
+	if exitBar, exists := be.lastExitBar[be.config.Symbol]; isEntry && holding == 0 && exists &&
+		be.bar-exitBar < be.config.CooldownPeriods {
+		be.skipTrade(skipCooldown, "Cooldown after exit, skipping %s for %s at %s",
+			signal, be.config.Symbol, timestamp.Format("2006-01-02 15:04"))
+		return
+	}
+	
+	// Don't catch a falling knife right after being stopped out
+	if stopBar, exists := be.lastStopBar[be.config.Symbol]; isEntry && holding == 0 && exists &&
+		be.bar-stopBar < be.config.StopLossLockoutPeriods {
+		be.skipTrade(skipStopLockout, "Stop-loss lockout, skipping %s for %s at %s",
+			signal, be.config.Symbol, timestamp.Format("2006-01-02 15:04"))
+		return
+	}
+	
+	be.ExecuteTrade(be.config.Symbol, signal, OrderTypeMarket, price, timestamp)
+}
+
+// fetchBenchmark loads the latest count candles of BenchmarkSymbol on the backtest interval
+// for the alpha/beta regression; it does nothing without a benchmark
+func (be *BacktestEngine) fetchBenchmark(ctx context.Context, count int) error {
+	if be.config.BenchmarkSymbol == "" {
+		return nil
+	}
+	var err error
+	be.benchmarkKlines, err = marketData.fetchKlines(ctx, be.config.BenchmarkSymbol, be.config.Interval, count)
+	if err != nil {
+		return fmt.Errorf("error fetching %s benchmark data: %v", be.config.BenchmarkSymbol, err)
+	}
+	log.Printf("Loaded %d %s candles as benchmark", len(be.benchmarkKlines), be.config.BenchmarkSymbol)
+	return nil
+}
+
 // RunBacktest executes the backtest for a given symbol
-func (be *BacktestEngine) RunBacktest() (*BacktestResult, error) {
+func (be *BacktestEngine) RunBacktest(ctx context.Context) (*BacktestResult, error) {
 	log.Printf("Starting backtest for %s...", be.config.Symbol)
 	
 	// Fetch historical data
-	klines, err := binanceClient.fetchKlines(be.config.Symbol, be.config.Interval, be.config.DataLimit)
+	klines, err := marketData.fetchKlines(ctx, be.config.Symbol, be.config.Interval, be.config.DataLimit)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching historical data: %v", err)
 	}
@@ -197,72 +669,199 @@ func (be *BacktestEngine) RunBacktest() (*BacktestResult, error) {
 	
 	log.Printf("Loaded %d candles for backtesting", len(klines))
 	
+	// Report data health before trusting it
+	intervalMinutes, _ := parseInterval(be.config.Interval)
+	quality := checkDataQuality(klines, intervalMinutes)
+	printDataQualityReport(quality)
+	if issues := quality.SeriousIssues(); be.config.StrictData && len(issues) > 0 {
+		return nil, fmt.Errorf("data quality check failed for %s: %s", be.config.Symbol, strings.Join(issues, "; "))
+	}
+	
+	// Fetch the higher timeframe over the same span, plus enough history for its EMA
+	if be.config.ConfirmInterval != "" {
+		confirmMinutes, err := parseInterval(be.config.ConfirmInterval)
+		if err != nil {
+			return nil, err
+		}
+		limit := len(klines)*intervalMinutes/confirmMinutes + Strategy.EMALong + 2
+		be.confirmKlines, err = marketData.fetchKlines(ctx, be.config.Symbol, be.config.ConfirmInterval, limit)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching %s confirmation data: %v", be.config.ConfirmInterval, err)
+		}
+		log.Printf("Loaded %d %s candles for trend confirmation", len(be.confirmKlines), be.config.ConfirmInterval)
+	}
+	
+	// Fetch the benchmark over the same candles for the alpha/beta regression
+	if err := be.fetchBenchmark(ctx, len(klines)); err != nil {
+		return nil, err
+	}
+	
+	return be.RunBacktestOnKlines(klines)
+}
+
+// confirmationKlines returns the ConfirmInterval klines and their duration, or nil when
+// confirmation is disabled. Without fetched klines they are aggregated from klines.
+func (be *BacktestEngine) confirmationKlines(klines []BinanceKline) ([]BinanceKline, time.Duration, error) {
+	if be.config.ConfirmInterval == "" {
+		return nil, 0, nil
+	}
+	confirmMinutes, err := parseInterval(be.config.ConfirmInterval)
+	if err != nil {
+		return nil, 0, err
+	}
+	period := time.Duration(confirmMinutes) * time.Minute
+	if be.confirmKlines != nil {
+		return be.confirmKlines, period, nil
+	}
+	
+	baseMinutes, err := parseInterval(be.config.Interval)
+	if err != nil {
+		return nil, 0, err
+	}
+	aggregated := aggregateKlines(klines, baseMinutes, confirmMinutes)
+	if aggregated == nil {
+		return nil, 0, fmt.Errorf("cannot build %s confirmation candles from %s data", be.config.ConfirmInterval, be.config.Interval)
+	}
+	return aggregated, period, nil
+}
+
+// RunBacktestOnKlines executes the backtest over already-loaded klines
+func (be *BacktestEngine) RunBacktestOnKlines(klines []BinanceKline) (*BacktestResult, error) {
+	warmup := Strategy.Warmup() // Candles needed before the indicators produce signals
+	if len(klines) <= warmup {
+		return nil, fmt.Errorf("not enough candles for %s: got %d, need more than %d",
+			be.config.Symbol, len(klines), warmup)
+	}
+	
+	// Candles span the configured interval, not a fixed 15m
+	candlePeriod, err := intervalDuration(be.config.Interval)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Create time series
 	ts := techan.NewTimeSeries()
 	prices := make([]float64, 0, len(klines))
+	opens := make([]float64, 0, len(klines))
+	highs := make([]float64, 0, len(klines))
+	lows := make([]float64, 0, len(klines))
+	volumes := make([]float64, 0, len(klines))
 	
 	for _, kline := range klines {
 		open, _ := strconv.ParseFloat(kline.Open, 64)
 		high, _ := strconv.ParseFloat(kline.High, 64)
 		low, _ := strconv.ParseFloat(kline.Low, 64)
 		close, _ := strconv.ParseFloat(kline.Close, 64)
+		volume, _ := strconv.ParseFloat(kline.Volume, 64)
 		
-		period := techan.NewTimePeriod(time.UnixMilli(kline.OpenTime), time.Minute*15)
+		period := techan.NewTimePeriod(time.UnixMilli(kline.OpenTime), candlePeriod)
 		c := techan.NewCandle(period)
 		c.OpenPrice = big.NewDecimal(open)
 		c.MaxPrice = big.NewDecimal(high)
 		c.MinPrice = big.NewDecimal(low)
 		c.ClosePrice = big.NewDecimal(close)
-		c.Volume = big.NewDecimal(0)
-		ts.AddCandle(c)
+		c.Volume = big.NewDecimal(volume)
+		if !ts.AddCandle(c) {
+			return nil, fmt.Errorf("kline at %s overlaps the previous one: klines are not %s candles",
+				period.Start.UTC().Format("2006-01-02 15:04"), be.config.Interval)
+		}
 		
 		prices = append(prices, close)
+		opens = append(opens, open)
+		highs = append(highs, high)
+		lows = append(lows, low)
+		volumes = append(volumes, volume)
 	}
 	
 	be.startTime = time.UnixMilli(klines[0].OpenTime)
 	be.endTime = time.UnixMilli(klines[len(klines)-1].CloseTime)
 	
-	// Run strategy simulation
-	equityCurve := make([]float64, 0, len(klines))
-	dailyReturns := make([]float64, 0)
+	// Run strategy simulation. Stored curves may be downsampled, so the Sharpe inputs are
+	// accumulated incrementally over every period.
+	equityCurve := newDownsampledSeries(be.config.MaxCurvePoints)
+	dailyReturns := newDownsampledSeries(be.config.MaxCurvePoints)
+	var returnStats runningStats
+	prevValue := 0.0
 	maxValue := be.config.InitialBalance
 	maxDrawdown := 0.0
+	barsInMarket := 0
+	pendingSignal := "" // Signal awaiting a next-open fill
+	var periodReturns map[int64]float64 // Per-period returns by candle open time, kept for the benchmark regression
+	if be.benchmarkKlines != nil {
+		periodReturns = make(map[int64]float64, len(klines)-warmup)
+	}
+	
+	// Grow a single series one candle per iteration so analyze only ever sees the past
+	subSeries := techan.NewTimeSeries()
+	for j := 0; j < warmup; j++ {
+		subSeries.AddCandle(ts.Candles[j])
+	}
+	
+	// The confirmation series likewise only grows by higher-timeframe candles already closed
+	var confirmSeries *techan.TimeSeries
+	confirmCandles, confirmPeriod, err := be.confirmationKlines(klines)
+	if err != nil {
+		return nil, err
+	}
+	if confirmCandles != nil {
+		confirmSeries = techan.NewTimeSeries()
+	}
+	nextConfirm := 0
 	
-	for i := 26; i < len(klines); i++ { // Start after enough data for indicators
-		// Update current price
+	for i := warmup; i < len(klines); i++ { // Start after enough data for indicators
+		timestamp := time.UnixMilli(klines[i].OpenTime)
+		be.bar = i
+		
+		// Fill the previous candle's signal at this candle's open
+		if pendingSignal != "" {
+			be.executeSignal(pendingSignal, opens[i], timestamp)
+			pendingSignal = ""
+		}
+		
+		// Exit intrabar if the candle touched the position's stop or target
+		be.checkBracket(opens[i], highs[i], lows[i], timestamp)
+		
+		// Update current price, and trail the stop behind it before the signal is checked
 		currentPrice := prices[i]
 		be.portfolio.LastPrices[be.config.Symbol] = currentPrice
+		be.updateTrailingStop(currentPrice)
 		
-		// Create a sub-series up to current point
-		subSeries := techan.NewTimeSeries()
-		for j := 0; j <= i; j++ {
-			candle := ts.Candles[j]
-			subSeries.AddCandle(candle)
+		subSeries.AddCandle(ts.Candles[i])
+		for confirmSeries != nil && nextConfirm < len(confirmCandles) &&
+			confirmCandles[nextConfirm].CloseTime <= klines[i].CloseTime {
+			confirmSeries.AddCandle(klineToCandle(confirmCandles[nextConfirm], confirmPeriod))
+			nextConfirm++
 		}
 		
 		// Get trading signal
-		signal := analyze(be.config.Symbol, subSeries)
-		timestamp := time.UnixMilli(klines[i].OpenTime)
+		signal := analyzeWithConfirmation(be.config.Symbol, subSeries, confirmSeries)
 		
 		// Execute trade based on signal
-		if signal == "BUY" {
-			be.ExecuteTrade(be.config.Symbol, "BUY", currentPrice, timestamp)
-		} else if signal == "SELL" {
-			be.ExecuteTrade(be.config.Symbol, "SELL", currentPrice, timestamp)
+		if be.config.EntryTiming == "next_open" {
+			pendingSignal = signal
+		} else {
+			be.executeSignal(signal, currentPrice, timestamp)
+		}
+		
+		// Track market exposure
+		if be.portfolio.Holdings[be.config.Symbol] != 0 {
+			barsInMarket++
 		}
 		
 		// Track portfolio value
 		currentValue := be.GetPortfolioValue()
-		equityCurve = append(equityCurve, currentValue)
+		equityCurve.Add(currentValue)
 		
 		// Calculate daily return
-		if len(equityCurve) > 1 {
-			prevValue := equityCurve[len(equityCurve)-2]
-			if prevValue > 0 {
-				dailyReturn := (currentValue - prevValue) / prevValue
-				dailyReturns = append(dailyReturns, dailyReturn)
+		if prevValue > 0 {
+			dailyReturn := (currentValue - prevValue) / prevValue
+			dailyReturns.Add(dailyReturn)
+			returnStats.Add(dailyReturn)
+			if periodReturns != nil {
+				periodReturns[klines[i].OpenTime] = dailyReturn
 			}
 		}
+		prevValue = currentValue
 		
 		// Track maximum drawdown
 		if currentValue > maxValue {
@@ -273,6 +872,10 @@ func (be *BacktestEngine) RunBacktest() (*BacktestResult, error) {
 		if drawdown > maxDrawdown {
 			maxDrawdown = drawdown
 		}
+		
+		if be.ProgressFunc != nil {
+			be.ProgressFunc(i-warmup+1, len(klines)-warmup)
+		}
 	}
 	
 	// Calculate final results
@@ -280,6 +883,7 @@ func (be *BacktestEngine) RunBacktest() (*BacktestResult, error) {
 	totalReturn := finalValue - be.config.InitialBalance
 	totalReturnPct := (totalReturn / be.config.InitialBalance) * 100
 	maxDrawdownPct := (maxDrawdown / maxValue) * 100
+	exposurePct := (float64(barsInMarket) / float64(len(klines)-warmup)) * 100
 	
 	// Calculate buy and hold return
 	firstPrice := prices[0]
@@ -293,24 +897,26 @@ func (be *BacktestEngine) RunBacktest() (*BacktestResult, error) {
 	totalWins := 0.0
 	totalLosses := 0.0
 	
-	// Pair buy and sell trades to calculate P&L
-	buyTrades := make([]Trade, 0)
-	for _, trade := range be.trades {
-		if trade.Type == "BUY" {
-			buyTrades = append(buyTrades, trade)
-		} else if trade.Type == "SELL" && len(buyTrades) > 0 {
-			// Match with most recent buy
-			buyTrade := buyTrades[len(buyTrades)-1]
-			buyTrades = buyTrades[:len(buyTrades)-1]
-			
-			pnl := (trade.Price - buyTrade.Price) * trade.Quantity - trade.Fee - buyTrade.Fee
-			if pnl > 0 {
-				winningTrades++
-				totalWins += pnl
-			} else {
-				losingTrades++
-				totalLosses += math.Abs(pnl)
-			}
+	// Match exits against entry lots FIFO to calculate P&L
+	roundTrips := pairTradesFIFO(be.trades)
+	
+	// Express each trade's result in R, the risk taken to the stop-loss
+	avgRMultiple := 0.0
+	if be.config.StopLossPct > 0 && len(roundTrips) > 0 {
+		for i := range roundTrips {
+			roundTrips[i].RMultiple = roundTrips[i].Return / be.config.StopLossPct
+			avgRMultiple += roundTrips[i].RMultiple
+		}
+		avgRMultiple /= float64(len(roundTrips))
+	}
+	
+	for _, rt := range roundTrips {
+		if rt.PnL > 0 {
+			winningTrades++
+			totalWins += rt.PnL
+		} else {
+			losingTrades++
+			totalLosses += math.Abs(rt.PnL)
 		}
 	}
 	
@@ -326,13 +932,78 @@ func (be *BacktestEngine) RunBacktest() (*BacktestResult, error) {
 		avgLoss = totalLosses / float64(losingTrades)
 	}
 	
+	kelly := kellyFraction(winRate/100, avgWin, avgLoss)
+	
+	winRateWindow := be.config.WinRateWindow
+	if winRateWindow <= 0 {
+		winRateWindow = 10
+	}
+	rollingWinRate := calculateRollingWinRate(roundTrips, winRateWindow)
+	
+	// Estimate capacity from the traded volume of each entry candle
+	participationRate := be.config.ParticipationRate
+	if participationRate <= 0 {
+		participationRate = 0.01
+	}
+	candleIndex := make(map[int64]int, len(klines))
+	for i, kline := range klines {
+		candleIndex[kline.OpenTime] = i
+	}
+	entryNotionals := make([]float64, 0)
+	for _, trade := range be.trades {
+		if !isEntryTrade(trade.Type) {
+			continue
+		}
+		if i, exists := candleIndex[trade.Timestamp.UnixMilli()]; exists {
+			entryNotionals = append(entryNotionals, volumes[i]*trade.Price)
+		}
+	}
+	capacity := estimateCapacity(entryNotionals, participationRate)
+	
 	// Calculate Sharpe ratio
 	var sharpeRatio float64
-	if len(dailyReturns) > 1 {
-		mean := calculateMean(dailyReturns)
-		stdDev := calculateStdDev(dailyReturns, mean)
+	if returnStats.Count() > 1 {
+		periodsPerYear := be.periodsPerYear()
+		excessMean := returnStats.Mean() - be.config.RiskFreeRate/periodsPerYear // Per-period risk-free return
+		stdDev := returnStats.StdDev()
 		if stdDev > 0 {
-			sharpeRatio = (mean * math.Sqrt(252)) / (stdDev * math.Sqrt(252)) // Annualized
+			sharpeRatio = (excessMean / stdDev) * math.Sqrt(periodsPerYear) // Annualized
+		}
+	}
+	
+	// Calculate VaR and expected shortfall over per-trade returns
+	varConfidence := be.config.VaRConfidence
+	if varConfidence <= 0 || varConfidence >= 1 {
+		varConfidence = 0.95
+	}
+	tradeReturns := make([]float64, 0, len(roundTrips))
+	for _, rt := range roundTrips {
+		tradeReturns = append(tradeReturns, rt.Return)
+	}
+	valueAtRisk, expectedShortfall := calculateVaR(tradeReturns, varConfidence)
+	returnAutocorr := calculateAutocorrelation(tradeReturns, 1)
+	expectancyPct := calculateMean(tradeReturns) * 100
+	
+	// Emphasize recent trades so a fading edge isn't masked by early wins
+	tradeDecay := be.config.TradeDecay
+	if tradeDecay <= 0 || tradeDecay > 1 {
+		tradeDecay = 0.9
+	}
+	weightedWinRate, weightedExpectancyPct := calculateWeightedTradeStats(roundTrips, tradeDecay)
+	bestTrade, worstTrade := bestAndWorstTrades(roundTrips)
+	
+	// Regress the strategy's per-period returns on the benchmark's over the candles both have
+	var alpha, beta float64
+	benchmarkSymbol := ""
+	if periodReturns != nil {
+		strategyReturns, benchmarkReturns := alignBenchmarkReturns(periodReturns, be.benchmarkKlines)
+		if len(strategyReturns) > 1 {
+			var periodAlpha float64
+			periodAlpha, beta = regressOLS(benchmarkReturns, strategyReturns)
+			alpha = periodAlpha * be.periodsPerYear() * 100
+			benchmarkSymbol = be.config.BenchmarkSymbol
+		} else {
+			log.Printf("Warning: no candles shared with benchmark %s, skipping alpha/beta", be.config.BenchmarkSymbol)
 		}
 	}
 	
@@ -353,17 +1024,132 @@ func (be *BacktestEngine) RunBacktest() (*BacktestResult, error) {
 		AverageLoss:         avgLoss,
 		SharpeRatio:         sharpeRatio,
 		Trades:              be.trades,
-		DailyReturns:        dailyReturns,
-		EquityCurve:         equityCurve,
+		DailyReturns:        dailyReturns.Values(),
+		EquityCurve:         equityCurve.Values(),
 		Duration:            be.endTime.Sub(be.startTime),
 		BuyAndHoldReturn:    buyAndHoldReturn,
 		BuyAndHoldReturnPct: buyAndHoldReturnPct,
+		RoundTrips:          roundTrips,
+		VaRConfidence:       varConfidence,
+		VaRPct:              valueAtRisk * 100,
+		CVaRPct:             expectedShortfall * 100,
+		ReturnAutocorr:      returnAutocorr,
+		ExposurePct:         exposurePct,
+		KellyFraction:       kelly,
+		CapacityUSD:         capacity,
+		ParticipationRate:   participationRate,
+		ZeroFee:             be.config.ZeroFee,
+		RollingWinRate:      rollingWinRate,
+		WinRateWindow:       winRateWindow,
+		SkippedTrades:       be.skipped,
+		ExpectancyPct:       expectancyPct,
+		WeightedWinRate:     weightedWinRate,
+		WeightedExpectancy:  weightedExpectancyPct,
+		TradeDecay:          tradeDecay,
+		Strategy:            Strategy,
+		AvgRMultiple:        avgRMultiple,
+		BestTrade:           bestTrade,
+		WorstTrade:          worstTrade,
+		BenchmarkSymbol:     benchmarkSymbol,
+		Alpha:               alpha,
+		Beta:                beta,
 	}
 	
 	log.Printf("Backtest completed for %s", be.config.Symbol)
 	return result, nil
 }
 
+// entryLot is the unmatched remainder of an entry trade
+type entryLot struct {
+	trade    Trade
+	quantity float64 // Quantity not yet matched by an exit
+}
+
+// pairTradesFIFO matches exit quantities against the oldest open entry lots first, splitting
+// an exit across several lots (and a lot across several exits) when quantities differ. Each
+// matched portion becomes a round trip carrying its pro-rata share of entry and exit fees.
+func pairTradesFIFO(trades []Trade) []RoundTrip {
+	roundTrips := make([]RoundTrip, 0)
+	lots := make([]entryLot, 0)
+	
+	for _, trade := range trades {
+		if isEntryTrade(trade.Type) {
+			lots = append(lots, entryLot{trade: trade, quantity: trade.Quantity})
+			continue
+		}
+		if !isExitTrade(trade.Type) {
+			continue
+		}
+		
+		remaining := trade.Quantity
+		for remaining > 0 && len(lots) > 0 {
+			lot := &lots[0]
+			matched := math.Min(remaining, lot.quantity)
+			entry := lot.trade
+			
+			priceMove := trade.Price - entry.Price
+			if entry.Type == "SHORT" {
+				priceMove = -priceMove
+			}
+			entryFee := entry.Fee * matched / entry.Quantity
+			exitFee := trade.Fee * matched / trade.Quantity
+			pnl := priceMove*matched - entryFee - exitFee
+			
+			roundTrips = append(roundTrips, RoundTrip{
+				Symbol:     trade.Symbol,
+				EntryTime:  entry.Timestamp,
+				ExitTime:   trade.Timestamp,
+				EntryPrice: entry.Price,
+				ExitPrice:  trade.Price,
+				Quantity:   matched,
+				PnL:        pnl,
+				Return:     pnl / (entry.Price * matched),
+				ExitType:   trade.Type,
+				Short:      entry.Type == "SHORT",
+			})
+			
+			remaining -= matched
+			lot.quantity -= matched
+			// Drop exhausted lots, tolerating float dust from partial matches
+			if lot.quantity <= entry.Quantity*1e-9 {
+				lots = lots[1:]
+			}
+		}
+	}
+	
+	return roundTrips
+}
+
+// EdgeGate is the minimum backtested edge required before trusting a strategy. A nil
+// threshold is not checked.
+type EdgeGate struct {
+	MinSharpe     *float64
+	MinExpectancy *float64 // Percent per trade
+}
+
+// Enabled reports whether any threshold is set
+func (g EdgeGate) Enabled() bool {
+	return g.MinSharpe != nil || g.MinExpectancy != nil
+}
+
+// Check returns the reasons result fails the gate, or nil if it passes
+func (g EdgeGate) Check(result *BacktestResult) []string {
+	var reasons []string
+	if g.MinSharpe != nil && result.SharpeRatio < *g.MinSharpe {
+		reasons = append(reasons, fmt.Sprintf("%s: Sharpe %.3f is below the minimum %.3f",
+			result.Symbol, result.SharpeRatio, *g.MinSharpe))
+	}
+	if g.MinExpectancy != nil {
+		if len(result.RoundTrips) == 0 {
+			reasons = append(reasons, fmt.Sprintf("%s: no completed trades to measure expectancy", result.Symbol))
+		} else if result.ExpectancyPct < *g.MinExpectancy {
+			reasons = append(reasons, fmt.Sprintf("%s: expectancy %.2f%% per trade is below the minimum %.2f%%",
+				result.Symbol, result.ExpectancyPct, *g.MinExpectancy))
+		}
+	}
+	return reasons
+}
+
 // Helper functions for statistical calculations
 func calculateMean(values []float64) float64 {
 	if len(values) == 0 {
@@ -390,20 +1176,403 @@ func calculateStdDev(values []float64, mean float64) float64 {
 	return math.Sqrt(sumSquares / float64(len(values)-1))
 }
 
+// runningStats accumulates mean and sample standard deviation in one pass (Welford)
+type runningStats struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// Add includes a value in the statistics
+func (rs *runningStats) Add(v float64) {
+	rs.count++
+	delta := v - rs.mean
+	rs.mean += delta / float64(rs.count)
+	rs.m2 += delta * (v - rs.mean)
+}
+
+// Count returns the number of values added
+func (rs *runningStats) Count() int {
+	return rs.count
+}
+
+// Mean returns the mean of the values added
+func (rs *runningStats) Mean() float64 {
+	return rs.mean
+}
+
+// StdDev returns the sample standard deviation of the values added
+func (rs *runningStats) StdDev() float64 {
+	if rs.count <= 1 {
+		return 0
+	}
+	return math.Sqrt(rs.m2 / float64(rs.count-1))
+}
+
+// downsampledSeries stores a series in at most maxPoints values by keeping every stride-th
+// point, doubling the stride and dropping every other stored point whenever the cap is hit
+type downsampledSeries struct {
+	maxPoints int
+	stride    int
+	seen      int
+	values    []float64
+}
+
+// newDownsampledSeries creates a series capped at maxPoints values; 0 or less keeps all
+func newDownsampledSeries(maxPoints int) *downsampledSeries {
+	if maxPoints == 1 {
+		maxPoints = 2 // Decimation needs room for at least two points
+	}
+	return &downsampledSeries{maxPoints: maxPoints, stride: 1}
+}
+
+// Add offers the next point of the series
+func (ds *downsampledSeries) Add(v float64) {
+	keep := ds.seen%ds.stride == 0
+	ds.seen++
+	if !keep {
+		return
+	}
+	
+	ds.values = append(ds.values, v)
+	if ds.maxPoints > 0 && len(ds.values) > ds.maxPoints {
+		kept := ds.values[:0]
+		for i := 0; i < len(ds.values); i += 2 {
+			kept = append(kept, ds.values[i])
+		}
+		ds.values = kept
+		ds.stride *= 2
+	}
+}
+
+// Values returns the stored points
+func (ds *downsampledSeries) Values() []float64 {
+	return ds.values
+}
+
+// periodsPerYear returns the number of candles per year for the configured interval and
+// trading calendar. Falls back to one period per trading day if the interval is unknown.
+func (be *BacktestEngine) periodsPerYear() float64 {
+	tradingDays := tradingDaysPerYear(be.config.TradingCalendar)
+	
+	minutes, err := parseInterval(be.config.Interval)
+	if err != nil {
+		log.Printf("Warning: %v, annualizing with one period per trading day", err)
+		return tradingDays
+	}
+	
+	return tradingDays * 24 * 60 / float64(minutes)
+}
+
+// tradingDaysPerYear returns the active days per year for a trading calendar
+func tradingDaysPerYear(calendar string) float64 {
+	if calendar == "weekdays" {
+		return 252
+	}
+	return 365
+}
+
+// calculateRollingWinRate returns the win rate (%) over each window of consecutive round
+// trips, so a decaying edge shows up as a falling series
+func calculateRollingWinRate(roundTrips []RoundTrip, window int) []float64 {
+	if window <= 0 || len(roundTrips) < window {
+		return nil
+	}
+	
+	rolling := make([]float64, 0, len(roundTrips)-window+1)
+	wins := 0
+	for i, rt := range roundTrips {
+		if rt.PnL > 0 {
+			wins++
+		}
+		if i >= window && roundTrips[i-window].PnL > 0 {
+			wins--
+		}
+		if i >= window-1 {
+			rolling = append(rolling, float64(wins)/float64(window)*100)
+		}
+	}
+	return rolling
+}
+
+// bestAndWorstTrades returns the round trips with the highest and lowest P&L, the earliest
+// one on ties, or nils when there are none
+func bestAndWorstTrades(roundTrips []RoundTrip) (best, worst *RoundTrip) {
+	for i := range roundTrips {
+		rt := roundTrips[i]
+		if best == nil || rt.PnL > best.PnL {
+			best = &rt
+		}
+		if worst == nil || rt.PnL < worst.PnL {
+			worst = &rt
+		}
+	}
+	return best, worst
+}
+
+// formatRoundTrip summarizes a round trip on one line: P&L, return, prices, dates and duration
+func formatRoundTrip(rt RoundTrip) string {
+	side := "long"
+	if rt.Short {
+		side = "short"
+	}
+	sign := "+"
+	if rt.PnL < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s %s %s$%.2f (%+.2f%%), $%.2f → $%.2f, %s → %s (%s)",
+		rt.Symbol, side, sign, math.Abs(rt.PnL), rt.Return*100, rt.EntryPrice, rt.ExitPrice,
+		rt.EntryTime.UTC().Format("2006-01-02 15:04"), rt.ExitTime.UTC().Format("2006-01-02 15:04"),
+		rt.ExitTime.Sub(rt.EntryTime))
+}
+
+// calculateWeightedTradeStats returns the win rate and expectancy (both in percent) with the
+// most recent round trip weighted 1, the one before it decay, then decay², and so on
+func calculateWeightedTradeStats(roundTrips []RoundTrip, decay float64) (winRate, expectancyPct float64) {
+	if len(roundTrips) == 0 {
+		return 0, 0
+	}
+	
+	weight, totalWeight := 1.0, 0.0
+	wins, returns := 0.0, 0.0
+	for i := len(roundTrips) - 1; i >= 0; i-- {
+		if roundTrips[i].PnL > 0 {
+			wins += weight
+		}
+		returns += weight * roundTrips[i].Return
+		totalWeight += weight
+		weight *= decay
+	}
+	return wins / totalWeight * 100, returns / totalWeight * 100
+}
+
+// calculateAutocorrelation returns the autocorrelation of values at the given lag. Positive
+// values mean results cluster in streaks, negative values mean they alternate. Returns 0 when
+// there are too few values or no variance.
+func calculateAutocorrelation(values []float64, lag int) float64 {
+	if lag <= 0 || len(values) <= lag {
+		return 0
+	}
+	
+	mean := calculateMean(values)
+	var numerator, denominator float64
+	for i, v := range values {
+		denominator += (v - mean) * (v - mean)
+		if i >= lag {
+			numerator += (v - mean) * (values[i-lag] - mean)
+		}
+	}
+	if denominator == 0 {
+		return 0
+	}
+	return numerator / denominator
+}
+
+// estimateCapacity returns the maximum capital per trade that stays within the participation
+// rate of every entry candle's traded notional (volume × price)
+func estimateCapacity(entryNotionals []float64, participationRate float64) float64 {
+	if len(entryNotionals) == 0 {
+		return 0
+	}
+	
+	capacity := math.Inf(1)
+	for _, notional := range entryNotionals {
+		capacity = math.Min(capacity, notional*participationRate)
+	}
+	return capacity
+}
+
+// kellyFraction returns the Kelly criterion fraction f = p - (1-p)/b, where p is the win
+// rate (0-1) and b the payoff ratio avgWin/avgLoss. With no losses the full fraction (1)
+// is returned; with no wins or a negative edge it returns 0.
+func kellyFraction(winRate, avgWin, avgLoss float64) float64 {
+	if winRate <= 0 || avgWin <= 0 {
+		return 0
+	}
+	if avgLoss <= 0 || winRate >= 1 {
+		return 1
+	}
+	
+	payoff := avgWin / avgLoss
+	kelly := winRate - (1-winRate)/payoff
+	if kelly < 0 {
+		return 0
+	}
+	return math.Min(kelly, 1)
+}
+
+// alignBenchmarkReturns pairs each strategy period return with the benchmark's close-to-close
+// return over the same candle, matched by open time. Periods missing from either side are dropped.
+func alignBenchmarkReturns(periodReturns map[int64]float64, benchmark []BinanceKline) ([]float64, []float64) {
+	strategyReturns := make([]float64, 0, len(periodReturns))
+	benchmarkReturns := make([]float64, 0, len(periodReturns))
+	
+	for i := 1; i < len(benchmark); i++ {
+		strategyReturn, exists := periodReturns[benchmark[i].OpenTime]
+		if !exists {
+			continue
+		}
+		prevClose, _ := strconv.ParseFloat(benchmark[i-1].Close, 64)
+		close, _ := strconv.ParseFloat(benchmark[i].Close, 64)
+		if prevClose <= 0 {
+			continue
+		}
+		strategyReturns = append(strategyReturns, strategyReturn)
+		benchmarkReturns = append(benchmarkReturns, (close-prevClose)/prevClose)
+	}
+	
+	return strategyReturns, benchmarkReturns
+}
+
+// regressOLS fits y = alpha + beta*x by ordinary least squares. Beta is 0 when x doesn't vary.
+func regressOLS(x, y []float64) (alpha, beta float64) {
+	meanX := calculateMean(x)
+	meanY := calculateMean(y)
+	
+	var covariance, variance float64
+	for i := range x {
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+		variance += (x[i] - meanX) * (x[i] - meanX)
+	}
+	if variance > 0 {
+		beta = covariance / variance
+	}
+	
+	return meanY - beta*meanX, beta
+}
+
+// calculateVaR returns the historical Value-at-Risk and Conditional VaR (expected shortfall)
+// of the given returns at the given confidence, both expressed as positive losses.
+func calculateVaR(returns []float64, confidence float64) (float64, float64) {
+	if len(returns) == 0 {
+		return 0, 0
+	}
+	
+	sorted := make([]float64, len(returns))
+	copy(sorted, returns)
+	sort.Float64s(sorted)
+	
+	// Number of observations in the tail beyond the confidence level. The epsilon keeps
+	// rounding error in 1 - confidence (0.050000000000000044 for 0.95) from adding one.
+	tailCount := int(math.Ceil((1-confidence)*float64(len(sorted)) - 1e-9))
+	if tailCount < 1 {
+		tailCount = 1
+	}
+	
+	valueAtRisk := -sorted[tailCount-1]
+	expectedShortfall := -calculateMean(sorted[:tailCount])
+	
+	return valueAtRisk, expectedShortfall
+}
+
+// klineBucket returns the open time and the exclusive end, in milliseconds, of the
+// targetMinutes candle containing openTime. Buckets are aligned like Binance's: weeks start
+// on Monday, 1M is the calendar month and other intervals count from the Unix epoch (UTC).
+func klineBucket(openTime int64, targetMinutes int) (start, end int64) {
+	var offset int64
+	switch targetMinutes {
+	case binanceIntervals["1M"]:
+		t := time.UnixMilli(openTime).UTC()
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return month.UnixMilli(), month.AddDate(0, 1, 0).UnixMilli()
+	case binanceIntervals["1w"]:
+		offset = int64(4 * 24 * time.Hour / time.Millisecond) // The epoch was a Thursday
+	}
+	
+	bucketMs := int64(targetMinutes) * int64(time.Minute/time.Millisecond)
+	sinceAligned := (openTime - offset) % bucketMs
+	if sinceAligned < 0 {
+		sinceAligned += bucketMs
+	}
+	start = openTime - sinceAligned
+	return start, start + bucketMs
+}
+
+// aggregateKlines combines consecutive base klines into klines of targetMinutes length.
+// Buckets are aligned as in klineBucket; incomplete buckets are dropped.
+func aggregateKlines(klines []BinanceKline, baseMinutes, targetMinutes int) []BinanceKline {
+	if baseMinutes <= 0 || targetMinutes%baseMinutes != 0 {
+		return nil
+	}
+	// Months only split into candles that divide a day
+	if targetMinutes == binanceIntervals["1M"] && (24*60)%baseMinutes != 0 {
+		return nil
+	}
+	if targetMinutes == baseMinutes {
+		return klines
+	}
+	
+	baseMs := int64(baseMinutes) * int64(time.Minute/time.Millisecond)
+	
+	aggregated := make([]BinanceKline, 0, len(klines)*baseMinutes/targetMinutes+1)
+	var current BinanceKline
+	var bucketEnd int64
+	var high, low, volume float64
+	count := 0
+	
+	flush := func() {
+		if int64(count) == (bucketEnd-current.OpenTime)/baseMs {
+			current.High = strconv.FormatFloat(high, 'f', -1, 64)
+			current.Low = strconv.FormatFloat(low, 'f', -1, 64)
+			current.Volume = strconv.FormatFloat(volume, 'f', -1, 64)
+			aggregated = append(aggregated, current)
+		}
+		count = 0
+	}
+	
+	for _, kline := range klines {
+		bucketStart, end := klineBucket(kline.OpenTime, targetMinutes)
+		if count > 0 && bucketStart != current.OpenTime {
+			flush()
+		}
+		
+		klineHigh, _ := strconv.ParseFloat(kline.High, 64)
+		klineLow, _ := strconv.ParseFloat(kline.Low, 64)
+		klineVolume, _ := strconv.ParseFloat(kline.Volume, 64)
+		
+		if count == 0 {
+			current = BinanceKline{
+				OpenTime: bucketStart,
+				Open:     kline.Open,
+			}
+			bucketEnd = end
+			high, low, volume = klineHigh, klineLow, 0
+		}
+		high = math.Max(high, klineHigh)
+		low = math.Min(low, klineLow)
+		volume += klineVolume
+		current.Close = kline.Close
+		current.CloseTime = kline.CloseTime
+		count++
+	}
+	flush()
+	
+	return aggregated
+}
+
 // PrintBacktestResults prints a detailed report of backtest results
 func PrintBacktestResults(result *BacktestResult) {
 	fmt.Println("\n" + strings.Repeat("=", 80))
 	fmt.Printf("                    BACKTEST RESULTS - %s\n", result.Symbol)
 	fmt.Println(strings.Repeat("=", 80))
 	
+	if result.ZeroFee {
+		fmt.Printf("⚠️  ZERO-FEE MODE: idealized upper bound, no fees or slippage applied\n\n")
+	}
+	
 	fmt.Printf("📊 PERFORMANCE OVERVIEW\n")
 	fmt.Printf("   Initial Balance:      $%.2f\n", result.InitialBalance)
 	fmt.Printf("   Final Value:          $%.2f\n", result.FinalValue)
 	fmt.Printf("   Total Return:         $%.2f (%.2f%%)\n", result.TotalReturn, result.TotalReturnPct)
 	fmt.Printf("   Buy & Hold Return:    $%.2f (%.2f%%)\n", result.BuyAndHoldReturn, result.BuyAndHoldReturnPct)
 	fmt.Printf("   Alpha vs Buy & Hold:  %.2f%%\n", result.TotalReturnPct - result.BuyAndHoldReturnPct)
+	if result.BenchmarkSymbol != "" {
+		fmt.Printf("   Alpha vs %-11s  %.2f%% annualized\n", result.BenchmarkSymbol+":", result.Alpha)
+		fmt.Printf("   Beta vs %-12s  %.3f\n", result.BenchmarkSymbol+":", result.Beta)
+	}
 	fmt.Printf("   Max Drawdown:         $%.2f (%.2f%%)\n", result.MaxDrawdown, result.MaxDrawdownPct)
 	fmt.Printf("   Sharpe Ratio:         %.3f\n", result.SharpeRatio)
+	fmt.Printf("   Market Exposure:      %.1f%% of candles\n", result.ExposurePct)
 	fmt.Printf("   Duration:             %v\n", result.Duration.Round(24*time.Hour))
 	
 	fmt.Printf("\n📈 TRADE STATISTICS\n")
@@ -411,6 +1580,30 @@ func PrintBacktestResults(result *BacktestResult) {
 	fmt.Printf("   Winning Trades:       %d\n", result.WinningTrades)
 	fmt.Printf("   Losing Trades:        %d\n", result.LosingTrades)
 	fmt.Printf("   Win Rate:             %.1f%%\n", result.WinRate)
+	if len(result.RollingWinRate) > 0 {
+		first := result.RollingWinRate[0]
+		last := result.RollingWinRate[len(result.RollingWinRate)-1]
+		minRate := first
+		for _, rate := range result.RollingWinRate {
+			minRate = math.Min(minRate, rate)
+		}
+		fmt.Printf("   Rolling Win Rate:     %.1f%% → %.1f%% (window %d, min %.1f%%)\n",
+			first, last, result.WinRateWindow, minRate)
+	}
+	if len(result.RoundTrips) > 0 {
+		fmt.Printf("   Expectancy:           %.2f%% per trade\n", result.ExpectancyPct)
+		fmt.Printf("   Recency-Weighted:     %.1f%% win rate, %.2f%% expectancy (decay %.2f)\n",
+			result.WeightedWinRate, result.WeightedExpectancy, result.TradeDecay)
+		if result.AvgRMultiple != 0 {
+			best, worst := math.Inf(-1), math.Inf(1)
+			for _, rt := range result.RoundTrips {
+				best = math.Max(best, rt.RMultiple)
+				worst = math.Min(worst, rt.RMultiple)
+			}
+			fmt.Printf("   Expectancy (R):       %.2fR per trade (best %.2fR, worst %.2fR)\n",
+				result.AvgRMultiple, best, worst)
+		}
+	}
 	fmt.Printf("   Average Win:          $%.2f\n", result.AverageWin)
 	fmt.Printf("   Average Loss:         $%.2f\n", result.AverageLoss)
 	
@@ -419,6 +1612,64 @@ func PrintBacktestResults(result *BacktestResult) {
 		fmt.Printf("   Profit Factor:        %.2f\n", profitFactor)
 	}
 	
+	if len(result.RoundTrips) > 0 {
+		exits := make(map[string]int)
+		for _, rt := range result.RoundTrips {
+			exits[rt.ExitType]++
+		}
+		fmt.Printf("   Exits:                %d signal, %d stop-loss, %d take-profit, %d trailing stop\n",
+			exits["SELL"]+exits["COVER"], exits["STOP"], exits["TARGET"], exits["TRAIL"])
+	}
+	if result.BestTrade != nil && result.WorstTrade != nil {
+		fmt.Printf("   Best Trade:           %s\n", formatRoundTrip(*result.BestTrade))
+		fmt.Printf("   Worst Trade:          %s\n", formatRoundTrip(*result.WorstTrade))
+	}
+	
+	if result.WinningTrades+result.LosingTrades > 0 {
+		if result.KellyFraction > 0 {
+			fmt.Printf("   Kelly Fraction:       %.1f%% (half-Kelly suggestion: %.1f%%)\n",
+				result.KellyFraction*100, result.KellyFraction*50)
+		} else {
+			fmt.Printf("   Kelly Fraction:       0.0%% (no positive edge)\n")
+		}
+	}
+	
+	if result.CapacityUSD > 0 {
+		fmt.Printf("   Capacity:             $%.2f per trade (at %.1f%% of candle volume)\n",
+			result.CapacityUSD, result.ParticipationRate*100)
+	}
+	
+	if len(result.RoundTrips) > 0 {
+		fmt.Printf("\n⚠️  TRADE RISK (%.0f%% confidence)\n", result.VaRConfidence*100)
+		fmt.Printf("   Value at Risk:        %.2f%% per trade\n", result.VaRPct)
+		fmt.Printf("   Expected Shortfall:   %.2f%% per trade\n", result.CVaRPct)
+		if len(result.RoundTrips) > 1 {
+			clustering := "none"
+			if result.ReturnAutocorr > 0.1 {
+				clustering = "wins/losses come in streaks"
+			} else if result.ReturnAutocorr < -0.1 {
+				clustering = "wins/losses alternate"
+			}
+			fmt.Printf("   Return Autocorr:      %.2f (%s)\n", result.ReturnAutocorr, clustering)
+		}
+		if len(result.RoundTrips) < minTradesForVaR {
+			fmt.Printf("   (low confidence: only %d completed trades)\n", len(result.RoundTrips))
+		}
+	}
+	
+	if len(result.SkippedTrades) > 0 {
+		reasons := make([]string, 0, len(result.SkippedTrades))
+		for reason := range result.SkippedTrades {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		
+		fmt.Printf("\n⏭️  SKIPPED SIGNALS\n")
+		for _, reason := range reasons {
+			fmt.Printf("   %-22s %d\n", reason+":", result.SkippedTrades[reason])
+		}
+	}
+	
 	// Show recent trades
 	fmt.Printf("\n📋 RECENT TRADES (Last 10)\n")
 	recentTrades := result.Trades
@@ -428,8 +1679,19 @@ func PrintBacktestResults(result *BacktestResult) {
 	
 	for _, trade := range recentTrades {
 		emoji := "🟢"
-		if trade.Type == "SELL" {
+		switch trade.Type {
+		case "SELL":
 			emoji = "🔴"
+		case "STOP":
+			emoji = "🛑"
+		case "TARGET":
+			emoji = "🎯"
+		case "TRAIL":
+			emoji = "🔻"
+		case "SHORT":
+			emoji = "🟠"
+		case "COVER":
+			emoji = "🔵"
 		}
 		fmt.Printf("   %s %s %.6f %s at $%.2f (%s)\n", 
 			emoji, trade.Type, trade.Quantity, trade.Symbol, 
@@ -459,3 +1721,91 @@ func PrintBacktestResults(result *BacktestResult) {
 	fmt.Printf("%s STRATEGY RATING: %s\n", ratingEmoji, rating)
 	fmt.Println(strings.Repeat("=", 80))
 }
+
+// MetricDelta is the change in one metric between two backtest results
+type MetricDelta struct {
+	Name  string
+	A     float64
+	B     float64
+	Delta float64
+}
+
+// resultDeltas computes B - A for the key metrics of two backtest results
+func resultDeltas(a, b *BacktestResult) []MetricDelta {
+	metrics := []struct {
+		name string
+		a, b float64
+	}{
+		{"Final Value", a.FinalValue, b.FinalValue},
+		{"Total Return %", a.TotalReturnPct, b.TotalReturnPct},
+		{"Alpha %", a.TotalReturnPct - a.BuyAndHoldReturnPct, b.TotalReturnPct - b.BuyAndHoldReturnPct},
+		{"Max Drawdown %", a.MaxDrawdownPct, b.MaxDrawdownPct},
+		{"Sharpe Ratio", a.SharpeRatio, b.SharpeRatio},
+		{"Total Trades", float64(a.TotalTrades), float64(b.TotalTrades)},
+		{"Win Rate %", a.WinRate, b.WinRate},
+		{"Average Win", a.AverageWin, b.AverageWin},
+		{"Average Loss", a.AverageLoss, b.AverageLoss},
+		{"Exposure %", a.ExposurePct, b.ExposurePct},
+	}
+	
+	deltas := make([]MetricDelta, 0, len(metrics))
+	for _, m := range metrics {
+		deltas = append(deltas, MetricDelta{Name: m.name, A: m.a, B: m.b, Delta: m.b - m.a})
+	}
+	return deltas
+}
+
+// tradeKey identifies a trade by timestamp and type for diffing
+func tradeKey(trade Trade) string {
+	return trade.Timestamp.UTC().Format(time.RFC3339) + " " + trade.Type
+}
+
+// DiffResults prints a side-by-side comparison of two backtest results and the trades
+// that appear in only one of them
+func DiffResults(a, b *BacktestResult) {
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Printf("                 BACKTEST DIFF - %s vs %s\n", a.Symbol, b.Symbol)
+	fmt.Println(strings.Repeat("=", 80))
+	
+	fmt.Printf("%-20s %15s %15s %15s\n", "Metric", "A", "B", "Delta")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, d := range resultDeltas(a, b) {
+		marker := ""
+		if d.Delta != 0 {
+			marker = " *"
+		}
+		fmt.Printf("%-20s %15.2f %15.2f %+15.2f%s\n", d.Name, d.A, d.B, d.Delta, marker)
+	}
+	
+	// Find trades present in only one of the runs
+	inA := make(map[string]bool)
+	for _, trade := range a.Trades {
+		inA[tradeKey(trade)] = true
+	}
+	inB := make(map[string]bool)
+	for _, trade := range b.Trades {
+		inB[tradeKey(trade)] = true
+	}
+	
+	fmt.Printf("\n📋 DIFFERING TRADES\n")
+	differences := 0
+	for _, trade := range a.Trades {
+		if !inB[tradeKey(trade)] {
+			fmt.Printf("   - A only: %s %s at $%.2f (%s)\n", trade.Type, trade.Symbol,
+				trade.Price, trade.Timestamp.Format("2006-01-02 15:04"))
+			differences++
+		}
+	}
+	for _, trade := range b.Trades {
+		if !inA[tradeKey(trade)] {
+			fmt.Printf("   + B only: %s %s at $%.2f (%s)\n", trade.Type, trade.Symbol,
+				trade.Price, trade.Timestamp.Format("2006-01-02 15:04"))
+			differences++
+		}
+	}
+	if differences == 0 {
+		fmt.Println("   Trades are identical")
+	}
+	
+	fmt.Println(strings.Repeat("=", 80))
+}
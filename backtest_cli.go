@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -18,75 +27,178 @@ func RunBacktestCLI() {
 		log.Printf("Warning: Could not load .env file: %v", err)
 	}
 
-	// Check for help flag first
-	for _, arg := range os.Args {
-		if arg == "-help" || arg == "--help" {
-			printBacktestHelp()
-			return
-		}
+	// The config file and env provide the defaults; flags below override them
+	cfg, err := LoadConfig(configPathFromArgs(os.Args[1:]))
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
 	}
 
 	// Parse simple flags from command line
-	symbol := "BTCUSDT"
-	initialBalance := 10000.0
-	fee := 0.001
-	interval := "15m"
-	dataLimit := 500
+	symbol := cfg.Backtest.Symbol
+	initialBalance := cfg.Backtest.InitialBalance
+	fee := cfg.Backtest.Fee
+	makerFee := 0.0 // maker/taker rates; an unset one falls back to -fee
+	takerFee := 0.0
+	var makerFeeRate, takerFeeRate *float64 // Set only when given, so an explicit 0 isn't taken as unset
+	slippagePct := 0.0
+	feeOverrides := ""
+	interval := cfg.Backtest.Interval
+	confirmInterval := ""
+	benchmarkSymbol := ""
+	replayDir := ""
+	minHoldPeriods := cfg.MinHoldPeriods
+	cooldownPeriods := cfg.CooldownPeriods
+	stopLockoutPeriods := 0
+	dataLimit := cfg.Backtest.Limit
+	maxCurvePoints := 0
+	limitMode := "paged"
+	varConfidence := 0.95
+	riskFreeRate := 0.0
+	participation := 0.01
+	winRateWindow := 10
+	tradeDecay := 0.9
+	compareIntervals := ""
+	walkForward := false
+	batch := false
+	symbolsFlag := ""
+	wfWindows := 4
+	wfTrainRatio := 0.7
+	diffFiles := ""
+	stressTest := false
+	zeroFee := false
+	strictData := false
+	quietSkips := false
+	allowShorting := false
+	stopLossPct := 0.0
+	trailingStopPct := 0.0
+	takeProfitPct := 0.0
+	bracketTieBreak := "stop"
+	positionSizePct := 0.0
+	scaleOutPct := 0.0
+	stressSeed := int64(42)
+	monteCarloRuns := 0
+	maxTradesPerDay := 0
+	timezone := "UTC"
+	calendar := "24x7"
+	entryTiming := "close"
+	rankMetric := "" // defaults to return for -compare-intervals and sharpe for -walkforward
+	scoreWeightsFlag := ""
+	var gate EdgeGate // minimum Sharpe/expectancy, unchecked unless set
 	// analysis mode toggle (classic vs ML)
 	useML := false
+	mlFallback := "hold"
+	// strategy overrides (empty keeps env/default values)
+	strategyFlag := ""
+	emaFlag := ""
+	rsiFlag := ""
+	rsiLevelsFlag := ""
+	macdFlag := ""
+	stochFlag := ""
+	stochLevelsFlag := ""
+	bollingerFlag := ""
 
-	// Simple argument parsing
-	args := os.Args[1:] // Skip program name
-	for i, arg := range args {
-		if arg == "-backtest" {
-			continue
-		}
-		if arg == "-useml" {
-			useML = true
-			continue
-		}
-		if strings.HasPrefix(arg, "-useml=") {
-			v := strings.TrimPrefix(arg, "-useml=")
-			v = strings.ToLower(strings.TrimSpace(v))
-			useML = (v == "true" || v == "1" || v == "yes")
-			continue
+	// Parse flags; unknown flags and bad values are errors, -help prints printBacktestHelp
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	fs.Usage = printBacktestHelp
+	fs.Bool("backtest", true, "Run the backtest CLI")
+	fs.String("config", "", "JSON config file supplying defaults (read before flags are parsed)")
+	showVersion := fs.Bool("version", false, "Print the version and build metadata, then exit")
+	fs.BoolVar(&useML, "useml", useML, "Use ML-based analyze() instead of classic rules")
+	fs.BoolVar(&zeroFee, "zerofee", zeroFee, "Ignore all fees to measure pure signal quality")
+	fs.BoolVar(&strictData, "strict-data", strictData, "Abort when the data-quality check finds serious issues")
+	fs.BoolVar(&quietSkips, "quiet-skips", quietSkips, "Only print the per-reason summary of skipped signals")
+	fs.BoolVar(&walkForward, "walkforward", walkForward, "Optimize EMA periods on rolling train segments and test each out-of-sample")
+	fs.BoolVar(&batch, "batch", batch, "Backtest every symbol in -symbols with the same settings")
+	fs.BoolVar(&allowShorting, "allow-short", allowShorting, "Let a SELL signal while flat open a short")
+	fs.BoolVar(&stressTest, "stress", stressTest, "Run the strategy on synthetic regimes")
+	fs.StringVar(&symbol, "symbol", symbol, "Trading pair to test")
+	fs.Float64Var(&initialBalance, "balance", initialBalance, "Initial balance in USD")
+	fs.Float64Var(&fee, "fee", fee, "Transaction fee percentage")
+	fs.StringVar(&interval, "interval", interval, "Candle interval: 1m, 3m, 5m, 15m, 30m, 1h, 2h, 4h, 6h, 8h, 12h, 1d, 3d, 1w, 1M")
+	fs.IntVar(&dataLimit, "limit", dataLimit, "Number of historical candles, paged above 1000")
+	fs.Float64Var(&MinEMAATRMultiple, "min-ema-atr", MinEMAATRMultiple, "Minimum EMA gap as a multiple of ATR to act on a cross")
+	fs.Float64Var(&varConfidence, "var-confidence", varConfidence, "Confidence level for per-trade VaR/CVaR")
+	fs.StringVar(&compareIntervals, "compare-intervals", compareIntervals, "Backtest the symbol on several intervals and rank them (e.g. 15m,1h,4h)")
+	fs.StringVar(&rankMetric, "rank", rankMetric, "Metric used to rank -compare-intervals and pick -walkforward parameters: return, alpha, sharpe, winrate, drawdown or composite")
+	fs.IntVar(&maxTradesPerDay, "max-trades-per-day", maxTradesPerDay, "Maximum new entries per day; exits are always allowed")
+	fs.StringVar(&timezone, "timezone", timezone, "Timezone for daily limits, e.g. America/Argentina/Buenos_Aires")
+	fs.StringVar(&mlFallback, "ml-fallback", mlFallback, "What -useml does while the ML model is untrained: classic or hold")
+	fs.StringVar(&feeOverrides, "fee-overrides", feeOverrides, "Per-symbol fees overriding -fee, e.g. BTCUSDT:0.00075,ETHUSDT:0.001")
+	fs.StringVar(&calendar, "calendar", calendar, "Trading calendar for annualizing Sharpe: 24x7 or weekdays")
+	fs.StringVar(&entryTiming, "entry", entryTiming, "Fill signals at the signal candle's close or the next candle's open: close, next_open")
+	fs.StringVar(&diffFiles, "diff", diffFiles, "Compare two saved JSON results, e.g. -diff=run1.json,run2.json")
+	fs.Float64Var(&participation, "participation", participation, "Max fraction of a candle's volume per fill, used for the capacity estimate")
+	fs.Int64Var(&stressSeed, "seed", stressSeed, "Random seed for -stress price series and -montecarlo resampling")
+	fs.IntVar(&monteCarloRuns, "montecarlo", monteCarloRuns, "Resample the trade order this many times and report return/drawdown percentiles")
+	fs.IntVar(&winRateWindow, "winrate-window", winRateWindow, "Round trips per rolling win-rate window")
+	fs.StringVar(&emaFlag, "ema", emaFlag, "EMA short,long periods")
+	fs.StringVar(&rsiFlag, "rsi", rsiFlag, "RSI period")
+	fs.StringVar(&rsiLevelsFlag, "rsi-levels", rsiLevelsFlag, "RSI overbought,oversold levels")
+	fs.StringVar(&macdFlag, "macd", macdFlag, "MACD fast,slow,signal periods")
+	fs.Float64Var(&stopLossPct, "stop-loss", stopLossPct, "Stop-loss below entry as a fraction, checked against each candle's low")
+	fs.Float64Var(&trailingStopPct, "trailing-stop", trailingStopPct, "Trailing stop below the highest close since entry as a fraction")
+	fs.Float64Var(&takeProfitPct, "take-profit", takeProfitPct, "Take-profit above entry as a fraction, checked against each candle's high")
+	fs.StringVar(&bracketTieBreak, "bracket-tiebreak", bracketTieBreak, "Exit used when one candle spans both stop and target: stop or target")
+	fs.StringVar(&limitMode, "limit-mode", limitMode, "Above 1000 candles: paged fetches all pages, warn caps at 1000 with a warning, error fails")
+	fs.Float64Var(&positionSizePct, "position-size", positionSizePct, "Fraction of cash each BUY deploys, allowing pyramiding")
+	fs.Float64Var(&scaleOutPct, "scale-out", scaleOutPct, "Fraction of the holding each SELL signal closes")
+	fs.Float64Var(&MinVolumeSpike, "volume-spike", MinVolumeSpike, "Only BUY when the candle's volume is at least this multiple of the 20-candle average")
+	fs.Float64Var(&slippagePct, "slippage", slippagePct, "Adverse fill slippage as a fraction of price, e.g. 0.0005")
+	fs.IntVar(&maxCurvePoints, "max-curve-points", maxCurvePoints, "Cap the stored equity curve and returns, downsampled evenly, for very long runs")
+	fs.Float64Var(&riskFreeRate, "risk-free", riskFreeRate, "Annual risk-free rate subtracted in the Sharpe ratio, e.g. 0.04")
+	minSharpe := fs.Float64("min-sharpe", 0, "Fail (exit status 1) if the Sharpe ratio is below this value")
+	minExpectancy := fs.Float64("min-expectancy", 0, "Fail (exit status 1) if the mean return per trade (%) is below this value")
+	fs.IntVar(&wfWindows, "windows", wfWindows, "Number of -walkforward train/test windows")
+	fs.Float64Var(&wfTrainRatio, "train-ratio", wfTrainRatio, "Share of each -walkforward window used for training")
+	fs.Float64Var(&tradeDecay, "trade-decay", tradeDecay, "Per-trade weight decay for the recency-weighted win rate and expectancy")
+	fs.StringVar(&strategyFlag, "strategy", strategyFlag, "Signal rules: classic (EMA/RSI/MACD), stochastic or bollinger")
+	fs.StringVar(&stochFlag, "stoch", stochFlag, "Stochastic %K,smoothing,%D periods")
+	fs.StringVar(&stochLevelsFlag, "stoch-levels", stochLevelsFlag, "Stochastic overbought,oversold levels")
+	fs.StringVar(&bollingerFlag, "bollinger", bollingerFlag, "Bollinger band period,stddev multiplier")
+	fs.StringVar(&scoreWeightsFlag, "score-weights", scoreWeightsFlag, "Weights of normalized return, Sharpe and drawdown in the composite metric")
+	fs.StringVar(&confirmInterval, "confirm-interval", confirmInterval, "Higher interval whose EMA must be rising to confirm a BUY, e.g. 1h")
+	fs.IntVar(&minHoldPeriods, "min-hold", minHoldPeriods, "Candles after an entry before a signal may close the position")
+	fs.IntVar(&cooldownPeriods, "cooldown", cooldownPeriods, "Candles after an exit before a signal may open a new position")
+	fs.IntVar(&stopLockoutPeriods, "stop-lockout", stopLockoutPeriods, "Candles after a stop-loss exit before a signal may open a new position")
+	fs.StringVar(&replayDir, "replay", replayDir, "Read klines from recorded Binance responses in this directory instead of the API")
+	fs.StringVar(&benchmarkSymbol, "benchmark", benchmarkSymbol, "Symbol to measure alpha and beta against, e.g. BTCUSDT")
+	fs.StringVar(&symbolsFlag, "symbols", symbolsFlag, "Comma-separated symbols for -batch, e.g. BTCUSDT,ETHUSDT,SOLUSDT")
+	fs.Float64Var(&makerFee, "maker-fee", makerFee, "Fee for limit fills (take-profit exits); defaults to -fee")
+	fs.Float64Var(&takerFee, "taker-fee", takerFee, "Fee for market fills (signals and stop-loss exits); defaults to -fee")
+
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
 		}
-		if strings.HasPrefix(arg, "-symbol=") {
-			symbol = strings.TrimPrefix(arg, "-symbol=")
-		} else if strings.HasPrefix(arg, "-balance=") {
-			if val, err := strconv.ParseFloat(strings.TrimPrefix(arg, "-balance="), 64); err == nil {
-				initialBalance = val
-			}
-		} else if strings.HasPrefix(arg, "-fee=") {
-			if val, err := strconv.ParseFloat(strings.TrimPrefix(arg, "-fee="), 64); err == nil {
-				fee = val
-			}
-		} else if strings.HasPrefix(arg, "-interval=") {
-			interval = strings.TrimPrefix(arg, "-interval=")
-		} else if strings.HasPrefix(arg, "-limit=") {
-			if val, err := strconv.Atoi(strings.TrimPrefix(arg, "-limit=")); err == nil {
-				dataLimit = val
-			}
-		} else if arg == "-symbol" && i+1 < len(args) {
-			symbol = args[i+1]
-		} else if arg == "-balance" && i+1 < len(args) {
-			if val, err := strconv.ParseFloat(args[i+1], 64); err == nil {
-				initialBalance = val
-			}
-		} else if arg == "-fee" && i+1 < len(args) {
-			if val, err := strconv.ParseFloat(args[i+1], 64); err == nil {
-				fee = val
-			}
-		} else if arg == "-interval" && i+1 < len(args) {
-			interval = args[i+1]
-		} else if arg == "-limit" && i+1 < len(args) {
-			if val, err := strconv.Atoi(args[i+1]); err == nil {
-				dataLimit = val
-			}
-		} else if arg == "-useml" && i+1 < len(args) {
-			v := strings.ToLower(strings.TrimSpace(args[i+1]))
-			useML = (v == "true" || v == "1" || v == "yes")
+		os.Exit(2)
+	}
+	if fs.NArg() > 0 {
+		log.Fatalf("Unexpected argument %q (see -help)", fs.Arg(0))
+	}
+	if *showVersion {
+		printVersion(os.Stdout)
+		return
+	}
+
+	// The edge gate only checks thresholds, and the maker/taker rates only replace -fee,
+	// when they were given explicitly
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-sharpe":
+			gate.MinSharpe = minSharpe
+		case "min-expectancy":
+			gate.MinExpectancy = minExpectancy
+		case "maker-fee":
+			makerFeeRate = &makerFee
+		case "taker-fee":
+			takerFeeRate = &takerFee
 		}
+	})
+
+	// Comparing saved results needs no market data
+	if diffFiles != "" {
+		runResultDiff(diffFiles)
+		return
 	}
 
 	// Env override for analyze mode
@@ -98,49 +210,259 @@ func RunBacktestCLI() {
 		UseMLAnalyze = true
 		log.Printf("Backtest analyze(): ML mode enabled")
 	}
+	mode, err := parseMLFallback(mlFallback)
+	if err != nil {
+		log.Fatalf("Invalid -ml-fallback: %v", err)
+	}
+	MLFallback = mode
 
-	// Initialize Binance client
-	apiKey := os.Getenv("BINANCE_API_KEY")
-	secretKey := os.Getenv("BINANCE_SECRET_KEY")
-	if apiKey == "" || secretKey == "" {
-		log.Fatal("BINANCE_API_KEY and BINANCE_SECRET_KEY must be set in .env file")
+	Strategy = cfg.Strategy
+	if err := applyStrategyFlags(&Strategy, strategyFlag, emaFlag, rsiFlag, rsiLevelsFlag, macdFlag, stochFlag, stochLevelsFlag, bollingerFlag); err != nil {
+		log.Fatalf("Invalid strategy: %v", err)
+	}
+	if err := Strategy.Validate(); err != nil {
+		log.Fatalf("Invalid strategy: %v", err)
+	}
+
+	feeMap, err := parseFeeOverrides(feeOverrides)
+	if err != nil {
+		log.Fatalf("Invalid -fee-overrides: %v", err)
+	}
+
+	if calendar != "24x7" && calendar != "weekdays" {
+		log.Fatalf("Invalid -calendar %q: use 24x7 or weekdays", calendar)
+	}
+
+	if entryTiming != "close" && entryTiming != "next_open" {
+		log.Fatalf("Invalid -entry %q: use close or next_open", entryTiming)
+	}
+
+	if stopLossPct < 0 || stopLossPct >= 1 || takeProfitPct < 0 {
+		log.Fatalf("Invalid bracket: -stop-loss must be in [0, 1) and -take-profit must not be negative")
+	}
+	if minHoldPeriods < 0 || cooldownPeriods < 0 || stopLockoutPeriods < 0 {
+		log.Fatalf("Invalid spacing: -min-hold, -cooldown and -stop-lockout must not be negative")
+	}
+	if trailingStopPct < 0 || trailingStopPct >= 1 {
+		log.Fatalf("Invalid -trailing-stop %v: must be in [0, 1)", trailingStopPct)
+	}
+	if bracketTieBreak != "stop" && bracketTieBreak != "target" {
+		log.Fatalf("Invalid -bracket-tiebreak %q: use stop or target", bracketTieBreak)
+	}
+
+	if slippagePct < 0 || slippagePct >= 1 {
+		log.Fatalf("Invalid -slippage %v: must be in [0, 1)", slippagePct)
+	}
+
+	if positionSizePct < 0 || positionSizePct > 1 || scaleOutPct < 0 || scaleOutPct > 1 {
+		log.Fatalf("Invalid sizing: -position-size and -scale-out must be between 0 and 1")
+	}
+
+	benchmarkSymbol = strings.ToUpper(strings.TrimSpace(benchmarkSymbol))
+
+	if monteCarloRuns < 0 {
+		log.Fatalf("Invalid -montecarlo %d: must be 0 or more", monteCarloRuns)
+	}
+
+	if confirmInterval != "" {
+		confirmMinutes, err := parseInterval(confirmInterval)
+		if err != nil {
+			log.Fatalf("Invalid -confirm-interval: %v", err)
+		}
+		if baseMinutes, err := parseInterval(interval); err == nil && confirmMinutes <= baseMinutes {
+			log.Fatalf("Invalid -confirm-interval %s: must be longer than -interval %s", confirmInterval, interval)
+		}
+	}
+
+	if tradeDecay <= 0 || tradeDecay > 1 {
+		log.Fatalf("Invalid -trade-decay %v: must be in (0, 1]", tradeDecay)
+	}
+
+	if scoreWeightsFlag != "" {
+		weights, err := parseFloatList(scoreWeightsFlag, 3)
+		if err != nil {
+			log.Fatalf("Invalid -score-weights %q (want return,sharpe,drawdown): %v", scoreWeightsFlag, err)
+		}
+		CompositeWeights = ScoreWeights{Return: weights[0], Sharpe: weights[1], Drawdown: weights[2]}
+		if err := CompositeWeights.Validate(); err != nil {
+			log.Fatalf("Invalid -score-weights: %v", err)
+		}
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Fatalf("Invalid -timezone %q: %v", timezone, err)
 	}
-	binanceClient = NewBinanceClient(apiKey, secretKey)
 
 	fmt.Printf("🚀 Starting backtest for %s\n", symbol)
 	fmt.Printf("💰 Initial Balance: $%.2f\n", initialBalance)
-	fmt.Printf("💸 Transaction Fee: %.3f%%\n", fee*100)
+	if zeroFee {
+		fmt.Printf("💸 Transaction Fee: none (zero-fee mode)\n")
+	} else if makerFeeRate != nil || takerFeeRate != nil {
+		effectiveMaker, effectiveTaker := fee, fee
+		if makerFeeRate != nil {
+			effectiveMaker = *makerFeeRate
+		}
+		if takerFeeRate != nil {
+			effectiveTaker = *takerFeeRate
+		}
+		fmt.Printf("💸 Maker Fee: %.3f%% (limit fills), Taker Fee: %.3f%% (market fills)\n", effectiveMaker*100, effectiveTaker*100)
+	} else {
+		fmt.Printf("💸 Transaction Fee: %.3f%%\n", fee*100)
+	}
+	for feeSymbol, symbolFee := range feeMap {
+		fmt.Printf("   %s Fee Override: %.3f%%\n", feeSymbol, symbolFee*100)
+	}
 	fmt.Printf("⏱️  Interval: %s\n", interval)
+	if confirmInterval != "" {
+		fmt.Printf("🧭 Trend Confirmation: BUY needs a rising %s EMA(%d)\n", confirmInterval, Strategy.EMALong)
+	}
+	if benchmarkSymbol != "" {
+		fmt.Printf("📐 Benchmark: %s\n", benchmarkSymbol)
+	}
 	fmt.Printf("📊 Data Points: %d candles\n", dataLimit)
 	fmt.Println(strings.Repeat("-", 50))
 
 	// Create backtest configuration
 	config := BacktestConfig{
-		Symbol:         symbol,
-		InitialBalance: initialBalance,
-		TransactionFee: fee,
-		Interval:       interval,
-		DataLimit:      dataLimit,
+		Symbol:                 symbol,
+		InitialBalance:         initialBalance,
+		TransactionFee:         fee,
+		MakerFee:               makerFeeRate,
+		TakerFee:               takerFeeRate,
+		FeeOverrides:           feeMap,
+		Interval:               interval,
+		DataLimit:              dataLimit,
+		VaRConfidence:          varConfidence,
+		ParticipationRate:      participation,
+		MaxTradesPerDay:        maxTradesPerDay,
+		Timezone:               location,
+		TradingCalendar:        calendar,
+		EntryTiming:            entryTiming,
+		ZeroFee:                zeroFee,
+		StrictData:             strictData,
+		StopLossPct:            stopLossPct,
+		TrailingStopPct:        trailingStopPct,
+		TakeProfitPct:          takeProfitPct,
+		BracketTieBreak:        bracketTieBreak,
+		QuietSkips:             quietSkips,
+		PositionSizePct:        positionSizePct,
+		ScaleOutPct:            scaleOutPct,
+		AllowShorting:          allowShorting,
+		SlippagePct:            slippagePct,
+		MaxCurvePoints:         maxCurvePoints,
+		RiskFreeRate:           riskFreeRate,
+		ConfirmInterval:        confirmInterval,
+		BenchmarkSymbol:        benchmarkSymbol,
+		MinHoldPeriods:         minHoldPeriods,
+		CooldownPeriods:        cooldownPeriods,
+		StopLossLockoutPeriods: stopLockoutPeriods,
+		WinRateWindow:          winRateWindow,
+		TradeDecay:             tradeDecay,
+	}
+
+	// Synthetic stress tests run entirely in memory
+	if stressTest {
+		runStressTest(config, stressSeed)
+		return
+	}
+
+	// Read market data from recorded responses, or initialize the Binance client
+	if replayDir != "" {
+		marketData = newReplayBinance(replayDir)
+		log.Printf("Replaying recorded Binance responses from %s", replayDir)
+	} else {
+		apiKey := cfg.Binance.APIKey
+		secretKey := cfg.Binance.SecretKey
+		if apiKey == "" || secretKey == "" {
+			log.Fatal("BINANCE_API_KEY and BINANCE_SECRET_KEY must be set in .env file or the config file")
+		}
+		binanceClient = NewBinanceClient(apiKey, secretKey)
+		applyBinanceEnv(binanceClient)
+		binanceClient.SetTickerCacheTTL(0) // Backtests must never reuse a cached live price
+		if err := binanceClient.SetLimitMode(limitMode); err != nil {
+			log.Fatalf("Invalid -limit-mode: %v", err)
+		}
+		marketData = binanceClient
+	}
+
+	// Cancel in-flight requests on Ctrl+C / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if compareIntervals != "" {
+		if rankMetric == "" {
+			rankMetric = "return"
+		}
+		if err := validateRankMetric(rankMetric); err != nil {
+			log.Fatalf("Invalid -rank: %v", err)
+		}
+		runIntervalComparison(ctx, strings.Split(compareIntervals, ","), config, rankMetric)
+		return
+	}
+
+	if batch {
+		var symbols []string
+		for _, s := range strings.Split(symbolsFlag, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				symbols = append(symbols, s)
+			}
+		}
+		if len(symbols) == 0 {
+			log.Fatal("-batch requires -symbols, e.g. -symbols=BTCUSDT,ETHUSDT")
+		}
+		runBatchBacktest(ctx, symbols, config)
+		return
+	}
+
+	if walkForward {
+		if rankMetric == "" {
+			rankMetric = walkForwardObjective
+		}
+		if err := validateRankMetric(rankMetric); err != nil {
+			log.Fatalf("Invalid -rank: %v", err)
+		}
+		runWalkForwardCLI(ctx, config, wfWindows, wfTrainRatio, rankMetric)
+		return
 	}
 
 	// Create and run backtest engine
 	engine := NewBacktestEngine(config)
-	result, err := engine.RunBacktest()
+	engine.ProgressFunc = newProgressPrinter(os.Stderr, "⏳ Backtesting "+config.Symbol)
+	result, err := engine.RunBacktest(ctx)
 	if err != nil {
 		log.Fatalf("Backtest failed: %v", err)
 	}
 
 	// Print results
 	PrintBacktestResults(result)
+	if monteCarloRuns > 0 {
+		PrintMonteCarloStats(MonteCarloResample(result, monteCarloRuns, stressSeed))
+	}
+
+	// Report whether the strategy's edge meets the configured minimum
+	reasons := gate.Check(result)
+	if len(reasons) > 0 {
+		fmt.Printf("\n⛔ EDGE GATE FAILED\n")
+		for _, reason := range reasons {
+			fmt.Printf("   %s\n", reason)
+		}
+	} else if gate.Enabled() {
+		fmt.Printf("\n✅ Edge gate passed\n")
+	}
 
 	// Optionally save results to file
 	if shouldSaveResults() {
 		saveBacktestResults(result)
 	}
+
+	if len(reasons) > 0 {
+		os.Exit(1)
+	}
 }
 
 func printBacktestHelp() {
-	fmt.Println(`
+	fmt.Print(`
 🔍 GoTrading Backtest CLI
 
 USAGE:
@@ -150,9 +472,70 @@ USAGE:
   -symbol      Trading pair to test (default: BTCUSDT)
   -balance     Initial balance in USD (default: 10000)
   -fee         Transaction fee percentage (default: 0.001)
-  -interval    Candle interval: 1m, 5m, 15m, 1h, 4h, 1d (default: 15m)
-  -limit       Number of historical candles (default: 500)
+  -maker-fee   Fee for limit fills (take-profit exits); defaults to -fee
+  -taker-fee   Fee for market fills (signals and stop-loss exits); defaults to -fee
+  -slippage    Adverse fill slippage as a fraction of price, e.g. 0.0005 (default: 0)
+  -zerofee     Ignore all fees to measure pure signal quality (idealized upper bound)
+  -fee-overrides  Per-symbol fees overriding -fee, e.g. BTCUSDT:0.00075,ETHUSDT:0.001
+  -interval    Candle interval: 1m, 3m, 5m, 15m, 30m, 1h, 2h, 4h, 6h, 8h, 12h, 1d, 3d, 1w, 1M (default: 15m)
+  -limit       Number of historical candles, paged above 1000 (default: 500)
+  -max-curve-points  Cap the stored equity curve and returns, downsampled evenly, for very long runs (default: 0 = keep all)
+  -limit-mode  Above 1000 candles: paged fetches all pages, warn caps at 1000 with a warning, error fails (default: paged)
+  -quiet-skips Don't log each skipped signal; only print the per-reason summary
+  -strict-data Abort when the data-quality check finds invalid prices or more than 1% missing candles
   -useml       Use ML-based analyze() instead of classic rules (also via USE_ML_ANALYZE env)
+  -ml-fallback What -useml does while the ML model is untrained: classic or hold (default: hold)
+  -min-ema-atr Minimum EMA gap as a multiple of ATR to act on a cross (default: 0 = disabled)
+  -volume-spike  Only BUY when the candle's volume is at least this multiple of the 20-candle average (default: 0 = disabled)
+  -ema         EMA short,long periods (default: 9,21)
+  -rsi         RSI period (default: 14)
+  -rsi-levels  RSI overbought,oversold levels (default: 70,30)
+  -macd        MACD fast,slow,signal periods (default: 12,26,9)
+  -confirm-interval  Higher interval whose EMA must be rising to confirm a BUY, e.g. 1h (default: disabled)
+  -benchmark   Symbol to regress per-candle returns on for alpha and beta, e.g. BTCUSDT (default: disabled)
+  -strategy    Signal rules: classic (EMA/RSI/MACD), stochastic or bollinger (default: classic)
+  -stoch       Stochastic %K,smoothing,%D periods (default: 14,3,3)
+  -stoch-levels  Stochastic overbought,oversold levels (default: 80,20)
+  -bollinger   Bollinger band period,stddev multiplier (default: 20,2)
+  -participation  Max fraction of a candle's volume per fill, used for the capacity estimate (default: 0.01)
+  -winrate-window  Round trips per rolling win-rate window (default: 10)
+  -trade-decay Per-trade weight decay for the recency-weighted win rate and expectancy (default: 0.9)
+  -var-confidence  Confidence level for per-trade VaR/CVaR (default: 0.95)
+  -max-trades-per-day  Maximum new entries per day; exits are always allowed (default: 0 = unlimited)
+  -min-hold    Candles after an entry before a signal may close the position (default: 0 = disabled)
+  -cooldown    Candles after an exit before a signal may open a new position (default: 0 = disabled)
+  -stop-lockout  Candles after a stop-loss or trailing-stop exit before a signal may open a new position (default: 0 = disabled)
+  -timezone    Timezone for daily limits, e.g. America/Argentina/Buenos_Aires (default: UTC)
+  -position-size  Fraction of cash each BUY deploys, allowing pyramiding (default: 1 = all-in)
+  -scale-out   Fraction of the holding each SELL signal closes (default: 1 = full exit)
+  -allow-short Let a SELL signal while flat open a short, covered by the next BUY
+  -stop-loss   Stop-loss below entry as a fraction, checked against each candle's low (default: 0 = disabled)
+  -trailing-stop  Trailing stop below the highest close since entry as a fraction, e.g. 0.03 (default: 0 = disabled)
+  -take-profit Take-profit above entry as a fraction, checked against each candle's high (default: 0 = disabled)
+  -bracket-tiebreak  Exit used when one candle spans both stop and target: stop or target (default: stop)
+  -entry       Fill signals at the signal candle's close or the next candle's open: close, next_open (default: close)
+  -calendar    Trading calendar for annualizing Sharpe: 24x7 or weekdays (default: 24x7)
+  -min-sharpe  Fail (exit status 1) if the Sharpe ratio is below this value
+  -min-expectancy  Fail (exit status 1) if the mean return per trade (%) is below this value
+  -risk-free   Annual risk-free rate subtracted in the Sharpe ratio, e.g. 0.04 (default: 0)
+  -replay      Read klines from recorded Binance responses in a directory (e.g. testdata) instead of
+               the API; no API keys needed
+  -stress      Run the strategy on synthetic regimes (trend up/down, mean-reverting, chop); no API keys needed
+  -seed        Random seed for -stress price series and -montecarlo resampling (default: 42)
+  -montecarlo  Resample the trades with replacement N times and report 5th/50th/95th percentile
+               return and max drawdown (default: 0 = disabled)
+  -diff        Compare two saved JSON results, e.g. -diff=run1.json,run2.json
+  -walkforward Optimize EMA periods on rolling train segments and test each out-of-sample
+  -windows     Number of -walkforward train/test windows (default: 4)
+  -train-ratio Share of each -walkforward window used for training (default: 0.7)
+  -batch      Backtest every symbol in -symbols with the same settings and compare them
+  -symbols     Comma-separated symbols for -batch, e.g. BTCUSDT,ETHUSDT,SOLUSDT
+  -compare-intervals  Backtest the symbol on several intervals and rank them (e.g. 15m,1h,4h)
+  -rank        Metric used to rank -compare-intervals and pick -walkforward parameters: return, alpha,
+               sharpe, winrate, drawdown, composite (default: return; sharpe for -walkforward)
+  -score-weights  Weights of normalized return, Sharpe and drawdown in the composite metric (default: 1,1,1)
+  -version     Print the version and build metadata, then exit
+  -config      JSON config file supplying defaults; env vars and flags override it (default: $CONFIG_FILE)
   -help        Show this help message
 
 EXAMPLES:
@@ -165,6 +548,15 @@ EXAMPLES:
   # Test with hourly candles
   go run . -backtest -symbol=ADAUSDT -interval=1h -limit=1000
 
+  # Find the best timeframe for ETH by Sharpe ratio
+  go run . -backtest -symbol=ETHUSDT -compare-intervals=15m,1h,4h -rank=sharpe
+
+  # Compare the strategy across several pairs, best alpha first
+  go run . -backtest -batch -symbols=BTCUSDT,ETHUSDT,SOLUSDT
+
+  # Stress-test the strategy on synthetic market regimes
+  go run . -backtest -stress -limit=1000
+
 REQUIREMENTS:
   - Set BINANCE_API_KEY and BINANCE_SECRET_KEY in .env file
   - Ensure you have an active internet connection
@@ -175,6 +567,7 @@ and provide detailed performance metrics including:
 - Win rate and trade statistics
 - Maximum drawdown
 - Sharpe ratio
+- Per-trade Value at Risk and expected shortfall
 - Detailed trade history
 `)
 }
@@ -217,32 +610,124 @@ func saveBacktestResults(result *BacktestResult) {
 	os.Stdout = oldStdout
 
 	fmt.Printf("✅ Results saved to: %s\n", filename)
+
+	jsonFilename := strings.TrimSuffix(filename, ".txt") + ".json"
+	if err := saveBacktestResultsJSON(result, jsonFilename); err != nil {
+		log.Printf("Error saving JSON results: %v", err)
+		return
+	}
+	fmt.Printf("✅ JSON results saved to: %s\n", jsonFilename)
+}
+
+// saveBacktestResultsJSON writes a result as JSON so it can be reloaded for comparison
+func saveBacktestResultsJSON(result *BacktestResult, filename string) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding results: %v", err)
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// loadBacktestResults reads a result previously saved with saveBacktestResultsJSON
+func loadBacktestResults(filename string) (*BacktestResult, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading results file: %v", err)
+	}
+
+	var result BacktestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("error decoding results file %s: %v", filename, err)
+	}
+	return &result, nil
+}
+
+// runResultDiff loads two saved results ("a.json,b.json") and prints their differences
+func runResultDiff(files string) {
+	paths := strings.Split(files, ",")
+	if len(paths) != 2 {
+		log.Fatalf("-diff expects two result files: a.json,b.json")
+	}
+
+	a, err := loadBacktestResults(strings.TrimSpace(paths[0]))
+	if err != nil {
+		log.Fatalf("Error loading %s: %v", paths[0], err)
+	}
+	b, err := loadBacktestResults(strings.TrimSpace(paths[1]))
+	if err != nil {
+		log.Fatalf("Error loading %s: %v", paths[1], err)
+	}
+
+	DiffResults(a, b)
 }
 
-// parseInterval converts interval string to minutes for internal use
+// parseFeeOverrides parses a "SYMBOL:fee,SYMBOL:fee" list into a per-symbol fee map
+func parseFeeOverrides(spec string) (map[string]float64, error) {
+	overrides := make(map[string]float64)
+	if strings.TrimSpace(spec) == "" {
+		return overrides, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected SYMBOL:fee, got %q", entry)
+		}
+		fee, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || fee < 0 {
+			return nil, fmt.Errorf("invalid fee for %s: %q", parts[0], parts[1])
+		}
+		overrides[strings.ToUpper(strings.TrimSpace(parts[0]))] = fee
+	}
+
+	return overrides, nil
+}
+
+// binanceIntervals maps every Binance kline interval to its length in minutes. 1M is a
+// calendar month on Binance; it is approximated here as 30 days, except that
+// aggregateKlines builds real calendar months.
+var binanceIntervals = map[string]int{
+	"1m":  1,
+	"3m":  3,
+	"5m":  5,
+	"15m": 15,
+	"30m": 30,
+	"1h":  60,
+	"2h":  120,
+	"4h":  240,
+	"6h":  360,
+	"8h":  480,
+	"12h": 720,
+	"1d":  1440,
+	"3d":  4320,
+	"1w":  10080,
+	"1M":  43200,
+}
+
+// parseInterval converts interval string to minutes for internal use. Matching is
+// case-insensitive except for 1M (month), which Binance distinguishes from 1m (minute).
 func parseInterval(interval string) (int, error) {
-	switch strings.ToLower(interval) {
-	case "1m":
-		return 1, nil
-	case "5m":
-		return 5, nil
-	case "15m":
-		return 15, nil
-	case "30m":
-		return 30, nil
-	case "1h":
-		return 60, nil
-	case "4h":
-		return 240, nil
-	case "1d":
-		return 1440, nil
-	default:
-		return 0, fmt.Errorf("unsupported interval: %s", interval)
+	if minutes, ok := binanceIntervals[interval]; ok {
+		return minutes, nil
+	}
+	if minutes, ok := binanceIntervals[strings.ToLower(interval)]; ok {
+		return minutes, nil
+	}
+	return 0, fmt.Errorf("unsupported interval: %s", interval)
+}
+
+// intervalDuration returns the length of one candle of a Binance kline interval
+func intervalDuration(interval string) (time.Duration, error) {
+	minutes, err := parseInterval(interval)
+	if err != nil {
+		return 0, err
 	}
+	return time.Duration(minutes) * time.Minute, nil
 }
 
-// runBatchBacktest runs backtests for multiple symbols
-func runBatchBacktest(symbols []string, config BacktestConfig) {
+// runBatchBacktest runs backtests for multiple symbols with the same config and returns the
+// results of those that completed, keyed by symbol
+func runBatchBacktest(ctx context.Context, symbols []string, config BacktestConfig) map[string]*BacktestResult {
 	fmt.Println("🔄 Running batch backtest...")
 
 	results := make(map[string]*BacktestResult)
@@ -253,8 +738,9 @@ func runBatchBacktest(symbols []string, config BacktestConfig) {
 
 		config.Symbol = symbol
 		engine := NewBacktestEngine(config)
+		engine.ProgressFunc = newProgressPrinter(os.Stderr, "⏳ Backtesting "+symbol)
 
-		result, err := engine.RunBacktest()
+		result, err := engine.RunBacktest(ctx)
 		if err != nil {
 			log.Printf("❌ Backtest failed for %s: %v", symbol, err)
 			continue
@@ -266,6 +752,27 @@ func runBatchBacktest(symbols []string, config BacktestConfig) {
 
 	// Print comparison summary
 	printBatchSummary(results)
+	return results
+}
+
+// newProgressPrinter returns a ProgressFunc that redraws a percentage line on w whenever the
+// whole percentage changes, ending the line once the run completes
+func newProgressPrinter(w io.Writer, label string) func(done, total int) {
+	lastPct := -1
+	return func(done, total int) {
+		if total <= 0 {
+			return
+		}
+		pct := done * 100 / total
+		if pct == lastPct {
+			return
+		}
+		lastPct = pct
+		fmt.Fprintf(w, "\r%s... %3d%% (%d/%d candles)", label, pct, done, total)
+		if done >= total {
+			fmt.Fprintln(w)
+		}
+	}
 }
 
 func printBatchSummary(results map[string]*BacktestResult) {
@@ -280,8 +787,24 @@ func printBatchSummary(results map[string]*BacktestResult) {
 	bestPerformer := ""
 	bestReturn := -999.0
 
-	for symbol, result := range results {
-		alpha := result.TotalReturnPct - result.BuyAndHoldReturnPct
+	// Strongest outperformance of buy & hold first
+	symbols := make([]string, 0, len(results))
+	for symbol := range results {
+		symbols = append(symbols, symbol)
+	}
+	alpha := func(symbol string) float64 {
+		return results[symbol].TotalReturnPct - results[symbol].BuyAndHoldReturnPct
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if alpha(symbols[i]) != alpha(symbols[j]) {
+			return alpha(symbols[i]) > alpha(symbols[j])
+		}
+		return symbols[i] < symbols[j]
+	})
+
+	for _, symbol := range symbols {
+		result := results[symbol]
+		alpha := alpha(symbol)
 		fmt.Printf("%-10s %11.2f%% %11.2f%% %11.2f%% %9d %7.1f%%\n",
 			symbol, result.TotalReturnPct, result.BuyAndHoldReturnPct,
 			alpha, result.TotalTrades, result.WinRate)
@@ -296,3 +819,211 @@ func printBatchSummary(results map[string]*BacktestResult) {
 	fmt.Printf("🏆 Best Performer: %s (%.2f%% return)\n", bestPerformer, bestReturn)
 	fmt.Println(strings.Repeat("=", 80))
 }
+
+// rankMetrics maps each per-result ranking metric to its value, where higher is better.
+// The composite metric is scored across results by rankScores instead.
+var rankMetrics = map[string]func(*BacktestResult) float64{
+	"return":   func(r *BacktestResult) float64 { return r.TotalReturnPct },
+	"alpha":    func(r *BacktestResult) float64 { return r.TotalReturnPct - r.BuyAndHoldReturnPct },
+	"sharpe":   func(r *BacktestResult) float64 { return r.SharpeRatio },
+	"winrate":  func(r *BacktestResult) float64 { return r.WinRate },
+	"drawdown": func(r *BacktestResult) float64 { return -r.MaxDrawdownPct },
+}
+
+// validateRankMetric checks that metric is one of rankMetrics or composite
+func validateRankMetric(metric string) error {
+	metric = strings.ToLower(metric)
+	if _, exists := rankMetrics[metric]; exists || metric == "composite" {
+		return nil
+	}
+	return fmt.Errorf("unsupported rank metric: %s", metric)
+}
+
+// rankValue returns the value of a ranking metric for a result, where higher is better
+func rankValue(result *BacktestResult, metric string) (float64, error) {
+	value, exists := rankMetrics[strings.ToLower(metric)]
+	if !exists {
+		return 0, fmt.Errorf("unsupported rank metric: %s", metric)
+	}
+	return value(result), nil
+}
+
+// ScoreWeights weights the components of the composite rank metric
+type ScoreWeights struct {
+	Return   float64
+	Sharpe   float64
+	Drawdown float64
+}
+
+// CompositeWeights are the weights the composite rank metric uses; set with -score-weights
+var CompositeWeights = ScoreWeights{Return: 1, Sharpe: 1, Drawdown: 1}
+
+// Validate checks that the weights are non-negative and not all zero
+func (w ScoreWeights) Validate() error {
+	if w.Return < 0 || w.Sharpe < 0 || w.Drawdown < 0 {
+		return fmt.Errorf("weights must not be negative")
+	}
+	if w.Return+w.Sharpe+w.Drawdown == 0 {
+		return fmt.Errorf("at least one weight must be positive")
+	}
+	return nil
+}
+
+// rankScores returns each result's score under metric, where higher is better. The composite
+// metric min-max normalizes return, Sharpe and max drawdown (lower is better) to 0..1 across
+// results and sums them weighted by CompositeWeights, so its scores are only comparable
+// within one call.
+func rankScores(results []*BacktestResult, metric string) ([]float64, error) {
+	scores := make([]float64, len(results))
+	if strings.ToLower(metric) != "composite" {
+		for i, result := range results {
+			score, err := rankValue(result, metric)
+			if err != nil {
+				return nil, err
+			}
+			scores[i] = score
+		}
+		return scores, nil
+	}
+
+	components := []struct {
+		weight float64
+		value  func(*BacktestResult) float64
+	}{
+		{CompositeWeights.Return, func(r *BacktestResult) float64 { return r.TotalReturnPct }},
+		{CompositeWeights.Sharpe, func(r *BacktestResult) float64 { return r.SharpeRatio }},
+		{CompositeWeights.Drawdown, func(r *BacktestResult) float64 { return -r.MaxDrawdownPct }},
+	}
+	for _, c := range components {
+		low, high := math.Inf(1), math.Inf(-1)
+		for _, result := range results {
+			low = math.Min(low, c.value(result))
+			high = math.Max(high, c.value(result))
+		}
+		for i, result := range results {
+			// Identical values don't separate the results, so they add nothing
+			if high > low {
+				scores[i] += c.weight * (c.value(result) - low) / (high - low)
+			}
+		}
+	}
+	return scores, nil
+}
+
+// runIntervalComparison backtests one symbol across several intervals and ranks them.
+// The smallest interval is fetched once and aggregated into the larger ones where the
+// aggregated series is long enough; otherwise the interval is fetched directly.
+func runIntervalComparison(ctx context.Context, intervals []string, config BacktestConfig, metric string) {
+	fmt.Printf("🔄 Comparing intervals for %s...\n", config.Symbol)
+
+	// Find the smallest interval to use as base data
+	baseInterval := ""
+	baseMinutes := 0
+	for _, interval := range intervals {
+		minutes, err := parseInterval(strings.TrimSpace(interval))
+		if err != nil {
+			log.Printf("❌ Skipping %s: %v", interval, err)
+			continue
+		}
+		if baseMinutes == 0 || minutes < baseMinutes {
+			baseInterval = strings.TrimSpace(interval)
+			baseMinutes = minutes
+		}
+	}
+	if baseMinutes == 0 {
+		log.Printf("❌ No valid intervals to compare")
+		return
+	}
+
+	baseKlines, err := marketData.fetchKlines(ctx, config.Symbol, baseInterval, config.DataLimit)
+	if err != nil {
+		log.Printf("❌ Error fetching base data (%s): %v", baseInterval, err)
+		return
+	}
+
+	results := make(map[string]*BacktestResult)
+	for _, interval := range intervals {
+		interval = strings.TrimSpace(interval)
+		minutes, err := parseInterval(interval)
+		if err != nil {
+			continue
+		}
+		fmt.Printf("\n📊 Testing %s on %s...\n", config.Symbol, interval)
+
+		config.Interval = interval
+		engine := NewBacktestEngine(config)
+
+		var result *BacktestResult
+		aggregated := aggregateKlines(baseKlines, baseMinutes, minutes)
+		if len(aggregated) > Strategy.Warmup() {
+			log.Printf("Using %d %s candles aggregated from %s data", len(aggregated), interval, baseInterval)
+			if err = engine.fetchBenchmark(ctx, len(aggregated)); err == nil {
+				result, err = engine.RunBacktestOnKlines(aggregated)
+			}
+		} else {
+			result, err = engine.RunBacktest(ctx)
+		}
+		if err != nil {
+			log.Printf("❌ Backtest failed for %s: %v", interval, err)
+			continue
+		}
+
+		results[interval] = result
+		fmt.Printf("✅ %s completed: %.2f%% return\n", interval, result.TotalReturnPct)
+	}
+
+	printIntervalRanking(config.Symbol, results, metric)
+}
+
+// rankIntervals returns the intervals of results from best to worst under metric, breaking
+// ties alphabetically
+func rankIntervals(results map[string]*BacktestResult, metric string) []string {
+	intervals := make([]string, 0, len(results))
+	for interval := range results {
+		intervals = append(intervals, interval)
+	}
+	sort.Strings(intervals)
+	ranked := make([]*BacktestResult, len(intervals))
+	for i, interval := range intervals {
+		ranked[i] = results[interval]
+	}
+	scores, _ := rankScores(ranked, metric)
+	score := make(map[string]float64, len(intervals))
+	for i, interval := range intervals {
+		score[interval] = scores[i]
+	}
+	sort.SliceStable(intervals, func(i, j int) bool {
+		return score[intervals[i]] > score[intervals[j]]
+	})
+	return intervals
+}
+
+func printIntervalRanking(symbol string, results map[string]*BacktestResult, metric string) {
+	intervals := rankIntervals(results, metric)
+
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Printf("                 INTERVAL COMPARISON - %s (ranked by %s)\n", symbol, metric)
+	fmt.Println(strings.Repeat("=", 80))
+
+	fmt.Printf("%-6s %-10s %-12s %-12s %-10s %-8s %-10s\n",
+		"Rank", "Interval", "Return %", "Alpha %", "Sharpe", "Trades", "Win Rate")
+	fmt.Println(strings.Repeat("-", 80))
+
+	for i, interval := range intervals {
+		result := results[interval]
+		alpha := result.TotalReturnPct - result.BuyAndHoldReturnPct
+		fmt.Printf("%-6d %-10s %11.2f%% %11.2f%% %10.3f %8d %9.1f%%\n",
+			i+1, interval, result.TotalReturnPct, alpha,
+			result.SharpeRatio, result.TotalTrades, result.WinRate)
+	}
+
+	fmt.Println(strings.Repeat("-", 80))
+	if len(intervals) > 0 {
+		fmt.Printf("🏆 Best Interval: %s\n", intervals[0])
+	}
+	if strings.ToLower(metric) == "composite" {
+		fmt.Printf("Composite weights: return %.2f, Sharpe %.2f, drawdown %.2f\n",
+			CompositeWeights.Return, CompositeWeights.Sharpe, CompositeWeights.Drawdown)
+	}
+	fmt.Println(strings.Repeat("=", 80))
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStdout returns what run prints to standard output
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	defer func() { os.Stdout = stdout }()
+
+	run()
+	w.Close()
+	return <-done
+}
+
+func TestRankIntervals(t *testing.T) {
+	results := map[string]*BacktestResult{
+		"15m": {TotalReturnPct: 5, SharpeRatio: 0.5, MaxDrawdownPct: 10, WinRate: 40, BuyAndHoldReturnPct: 1},
+		"1h":  {TotalReturnPct: 2, SharpeRatio: 1.5, MaxDrawdownPct: 3, WinRate: 60, BuyAndHoldReturnPct: -4},
+	}
+	tests := []struct {
+		metric string
+		want   []string
+	}{
+		{"return", []string{"15m", "1h"}},
+		{"alpha", []string{"1h", "15m"}},
+		{"sharpe", []string{"1h", "15m"}},
+		{"Sharpe", []string{"1h", "15m"}},
+		{"winrate", []string{"1h", "15m"}},
+		{"drawdown", []string{"1h", "15m"}},
+		{"composite", []string{"1h", "15m"}},
+	}
+	for _, tt := range tests {
+		if got := rankIntervals(results, tt.metric); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rankIntervals(%s) = %v, want %v", tt.metric, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRankMetric(t *testing.T) {
+	tests := []struct {
+		metric  string
+		wantErr bool
+	}{
+		{"return", false},
+		{"alpha", false},
+		{"sharpe", false},
+		{"winrate", false},
+		{"drawdown", false},
+		{"composite", false},
+		{"SHARPE", false},
+		{"profit", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if err := validateRankMetric(tt.metric); (err != nil) != tt.wantErr {
+			t.Errorf("validateRankMetric(%q) = %v, want error %v", tt.metric, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRankScoresCompositeWeights(t *testing.T) {
+	// Each strategy leads on exactly one component of the composite score
+	results := []*BacktestResult{
+		{Symbol: "aggressive", TotalReturnPct: 40, SharpeRatio: 0.8, MaxDrawdownPct: 30},
+		{Symbol: "steady", TotalReturnPct: 15, SharpeRatio: 2.0, MaxDrawdownPct: 12},
+		{Symbol: "defensive", TotalReturnPct: 5, SharpeRatio: 1.0, MaxDrawdownPct: 4},
+	}
+	tests := []struct {
+		name       string
+		weights    ScoreWeights
+		wantFirst  string
+		wantScores []float64
+	}{
+		{"return only", ScoreWeights{Return: 1}, "aggressive", []float64{1, 10.0 / 35, 0}},
+		{"sharpe only", ScoreWeights{Sharpe: 1}, "steady", []float64{0, 1, 0.2 / 1.2}},
+		{"drawdown only", ScoreWeights{Drawdown: 1}, "defensive", []float64{0, 18.0 / 26, 1}},
+		{"equal weights", ScoreWeights{Return: 1, Sharpe: 1, Drawdown: 1}, "steady",
+			[]float64{1, 10.0/35 + 1 + 18.0/26, 0.2/1.2 + 1}},
+		{"risk averse", ScoreWeights{Return: 1, Sharpe: 1, Drawdown: 5}, "defensive",
+			[]float64{1, 10.0/35 + 1 + 5*18.0/26, 0.2/1.2 + 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setGlobal(t, &CompositeWeights, tt.weights)
+			scores, err := rankScores(results, "composite")
+			if err != nil {
+				t.Fatal(err)
+			}
+			best := 0
+			for i, score := range scores {
+				if !approxEqual(score, tt.wantScores[i], 1e-9) {
+					t.Errorf("%s scored %.4f, want %.4f", results[i].Symbol, score, tt.wantScores[i])
+				}
+				if score > scores[best] {
+					best = i
+				}
+			}
+			if results[best].Symbol != tt.wantFirst {
+				t.Errorf("%s ranks first, want %s", results[best].Symbol, tt.wantFirst)
+			}
+		})
+	}
+
+	// Components every result shares don't move the scores
+	setGlobal(t, &CompositeWeights, ScoreWeights{Return: 1, Sharpe: 1, Drawdown: 1})
+	same := []*BacktestResult{{TotalReturnPct: 3, SharpeRatio: 1}, {TotalReturnPct: 3, SharpeRatio: 1}}
+	if scores, _ := rankScores(same, "composite"); scores[0] != 0 || scores[1] != 0 {
+		t.Errorf("identical results scored %v, want zeros", scores)
+	}
+}
+
+func TestScoreWeightsValidate(t *testing.T) {
+	tests := []struct {
+		weights ScoreWeights
+		wantErr bool
+	}{
+		{ScoreWeights{Return: 1, Sharpe: 1, Drawdown: 1}, false},
+		{ScoreWeights{Drawdown: 0.5}, false},
+		{ScoreWeights{}, true},
+		{ScoreWeights{Return: 2, Sharpe: -1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.weights.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: got %v, want error %v", tt.weights, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRunBatchBacktest(t *testing.T) {
+	// BTC falls and ETH rises; a strategy that never trades beats buy & hold only on BTC
+	servers := map[string]*klineServer{
+		"BTCUSDT": {klines: testKlines(trendCloses(300, -0.1))},
+		"ETHUSDT": {klines: testKlines(trendCloses(300, 0.1))},
+	}
+	bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server, ok := servers[r.URL.Query().Get("symbol")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"code":-1121,"msg":"Invalid symbol."}`)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	useMarketData(t, bc)
+	useScript(t, nil)
+
+	var results map[string]*BacktestResult
+	out := captureStdout(t, func() {
+		results = runBatchBacktest(context.Background(), []string{"ethusdt", " BTCUSDT", "NOPEUSDT"},
+			BacktestConfig{InitialBalance: 10000, Interval: "15m", DataLimit: 300, QuietSkips: true})
+	})
+
+	if len(results) != 2 || results["BTCUSDT"] == nil || results["ETHUSDT"] == nil {
+		t.Fatalf("got results for %v, want BTCUSDT and ETHUSDT", reflect.ValueOf(results).MapKeys())
+	}
+	for symbol, result := range results {
+		if result.Symbol != symbol {
+			t.Errorf("result under %s is for %s", symbol, result.Symbol)
+		}
+	}
+	if results["BTCUSDT"].BuyAndHoldReturnPct >= 0 || results["ETHUSDT"].BuyAndHoldReturnPct <= 0 {
+		t.Errorf("buy & hold: BTC %.2f%%, ETH %.2f%%; want the data's fall and rise",
+			results["BTCUSDT"].BuyAndHoldReturnPct, results["ETHUSDT"].BuyAndHoldReturnPct)
+	}
+
+	// The summary lists the highest alpha first
+	btc, eth := strings.Index(out, "\nBTCUSDT "), strings.Index(out, "\nETHUSDT ")
+	if btc < 0 || eth < 0 || btc > eth {
+		t.Errorf("summary should list BTCUSDT (higher alpha) before ETHUSDT:\n%s", out)
+	}
+	if !strings.Contains(out, "BATCH BACKTEST SUMMARY") {
+		t.Errorf("output is missing the summary:\n%s", out)
+	}
+}
+
+func TestRunIntervalComparison(t *testing.T) {
+	useReplayData(t)
+	out := captureStdout(t, func() {
+		runIntervalComparison(context.Background(), []string{"15m", "1h"}, replayConfig(), "return")
+	})
+
+	for _, want := range []string{"INTERVAL COMPARISON - BTCUSDT (ranked by return)", "Best Interval:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	ranks := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && (fields[0] == "1" || fields[0] == "2") {
+			ranks = append(ranks, fields[1])
+		}
+	}
+	if len(ranks) != 2 {
+		t.Fatalf("ranked %v, want both intervals:\n%s", ranks, out)
+	}
+	if !strings.Contains(out, "Best Interval: "+ranks[0]) {
+		t.Errorf("best interval isn't the first ranked, %s:\n%s", ranks[0], out)
+	}
+}
+
+func TestBacktestCLIFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+		wantStderr string
+	}{
+		{"known flags", []string{"-backtest", "-replay=testdata", "-balance=5000", "-fee=0.002", "-interval=15m", "-limit=200", "-quiet-skips"}, 0,
+			[]string{"Initial Balance: $5000.00", "Transaction Fee: 0.200%", "Interval: 15m", "Data Points: 200 candles", "BACKTEST RESULTS"}, ""},
+		{"explicit zero maker fee", []string{"-backtest", "-replay=testdata", "-fee=0.001", "-maker-fee=0", "-limit=200", "-quiet-skips"}, 0,
+			[]string{"Maker Fee: 0.000% (limit fills), Taker Fee: 0.100% (market fills)"}, ""},
+		{"help", []string{"-backtest", "-help"}, 0, []string{"GoTrading Backtest CLI", "-symbol"}, ""},
+		{"unknown flag", []string{"-backtest", "-symbal=ETHUSDT"}, 2, nil, "flag provided but not defined: -symbal"},
+		{"bad value", []string{"-backtest", "-balance=abc"}, 2, nil, `invalid value "abc" for flag -balance`},
+		{"stray argument", []string{"-backtest", "ETHUSDT"}, 1, nil, `Unexpected argument "ETHUSDT"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runMain(t, tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d\nstdout:\n%s\nstderr:\n%s", code, tt.wantCode, stdout, stderr)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("stdout is missing %q:\n%s", want, stdout)
+				}
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.wantStderr, stderr)
+			}
+		})
+	}
+}
+
+func TestBacktestResultsJSONRoundTrip(t *testing.T) {
+	result := &BacktestResult{
+		Symbol:         "BTCUSDT",
+		FinalValue:     10250.5,
+		TotalReturnPct: 2.505,
+		Trades: []Trade{{Type: "BUY", Symbol: "BTCUSDT", Price: 100, Quantity: 1.5,
+			Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	path := filepath.Join(t.TempDir(), "result.json")
+	if err := saveBacktestResultsJSON(result, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadBacktestResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, result) {
+		t.Errorf("loaded %+v, want %+v", loaded, result)
+	}
+	for _, d := range resultDeltas(result, loaded) {
+		if d.Delta != 0 {
+			t.Errorf("%s changed by %v through JSON", d.Name, d.Delta)
+		}
+	}
+
+	if _, err := loadBacktestResults(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		want     int // Minutes; 0 when unsupported
+	}{
+		{"1m", 1},
+		{"3m", 3},
+		{"5m", 5},
+		{"15m", 15},
+		{"30m", 30},
+		{"1h", 60},
+		{"2h", 2 * 60},
+		{"4h", 4 * 60},
+		{"6h", 6 * 60},
+		{"8h", 8 * 60},
+		{"12h", 12 * 60},
+		{"1d", 24 * 60},
+		{"3d", 3 * 24 * 60},
+		{"1w", 7 * 24 * 60},
+		{"1M", 30 * 24 * 60},
+		{"2H", 2 * 60},
+		{"1D", 24 * 60},
+		{"45m", 0},
+		{"2d", 0},
+		{"1y", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseInterval(tt.interval)
+		if got != tt.want || (err != nil) != (tt.want == 0) {
+			t.Errorf("parseInterval(%q) = %d, %v; want %d", tt.interval, got, err, tt.want)
+		}
+		if duration, _ := intervalDuration(tt.interval); duration != time.Duration(tt.want)*time.Minute {
+			t.Errorf("intervalDuration(%q) = %v, want %v", tt.interval, duration, time.Duration(tt.want)*time.Minute)
+		}
+	}
+	if len(binanceIntervals) != 15 {
+		t.Errorf("%d intervals supported, want Binance's 15", len(binanceIntervals))
+	}
+}
@@ -0,0 +1,1755 @@
+package main
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdcoffey/techan"
+)
+
+// newTestEngine returns an engine for unit tests that drive trades candle by candle
+func newTestEngine(config BacktestConfig) *BacktestEngine {
+	if config.Symbol == "" {
+		config.Symbol = "TESTUSDT"
+	}
+	if config.InitialBalance == 0 {
+		config.InitialBalance = 10000
+	}
+	config.QuietSkips = true
+	return NewBacktestEngine(config)
+}
+
+func TestStopLossLockout(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		barsAfterStop int
+		wantEntry     bool
+	}{
+		{"next candle", 1, false},
+		{"last locked candle", 2, false},
+		{"lockout over", 3, true},
+		{"long after", 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(BacktestConfig{StopLossPct: 0.02, StopLossLockoutPeriods: 3})
+			be.executeSignal("BUY", 100, start)
+			be.bar = 1
+			be.checkBracket(99, 99, 97, start.Add(time.Minute))
+			if n := len(be.trades); n != 2 || be.trades[1].Type != "STOP" {
+				t.Fatalf("expected BUY then STOP, got %d trades", n)
+			}
+
+			be.bar += tt.barsAfterStop
+			be.executeSignal("BUY", 96, start.Add(time.Duration(be.bar)*time.Minute))
+			if entered := len(be.trades) == 3; entered != tt.wantEntry {
+				t.Errorf("entered = %v, want %v", entered, tt.wantEntry)
+			}
+			if locked := be.skipped[skipStopLockout] == 1; locked == tt.wantEntry {
+				t.Errorf("skipped[%q] = %d", skipStopLockout, be.skipped[skipStopLockout])
+			}
+		})
+	}
+}
+
+func TestStopLossLockoutIgnoresSignalExits(t *testing.T) {
+	be := newTestEngine(BacktestConfig{StopLossPct: 0.02, StopLossLockoutPeriods: 3})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	be.executeSignal("BUY", 100, start)
+	be.bar = 1
+	be.executeSignal("SELL", 101, start.Add(time.Minute))
+	be.bar = 2
+	be.executeSignal("BUY", 101, start.Add(2*time.Minute))
+	if len(be.trades) != 3 {
+		t.Errorf("a signal exit must not lock out re-entry, got %d trades", len(be.trades))
+	}
+}
+
+func TestProgressFunc(t *testing.T) {
+	tests := []struct {
+		name      string
+		dataLimit int
+	}{
+		{"default limit", 500},
+		{"all recorded candles", 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useReplayData(t)
+			config := replayConfig()
+			config.DataLimit = tt.dataLimit
+			engine := NewBacktestEngine(config)
+
+			calls, lastDone := 0, 0
+			wantTotal := tt.dataLimit - Strategy.Warmup()
+			engine.ProgressFunc = func(done, total int) {
+				calls++
+				if done != lastDone+1 {
+					t.Fatalf("done = %d after %d, want it to grow by one per candle", done, lastDone)
+				}
+				if total != wantTotal {
+					t.Fatalf("total = %d, want %d", total, wantTotal)
+				}
+				lastDone = done
+			}
+			if _, err := engine.RunBacktest(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if calls != wantTotal {
+				t.Errorf("called %d times, want once per candle after the warmup (%d)", calls, wantTotal)
+			}
+		})
+	}
+}
+
+func TestAlphaBetaOnCorrelatedReturns(t *testing.T) {
+	benchmark, err := generateSyntheticKlines("chop", 200, 15, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		scale     float64 // Strategy return per unit of benchmark return
+		offset    float64 // Strategy return on top of that, per period
+		wantAlpha float64
+		wantBeta  float64
+	}{
+		{"same returns", 1, 0, 0, 1},
+		{"leveraged", 2, 0, 0, 2},
+		{"outperforming", 1, 0.001, 0.001, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periodReturns := make(map[int64]float64)
+			for i := 1; i < len(benchmark); i++ {
+				prevClose, _ := strconv.ParseFloat(benchmark[i-1].Close, 64)
+				close, _ := strconv.ParseFloat(benchmark[i].Close, 64)
+				periodReturns[benchmark[i].OpenTime] = tt.scale*(close-prevClose)/prevClose + tt.offset
+			}
+			strategyReturns, benchmarkReturns := alignBenchmarkReturns(periodReturns, benchmark)
+			if len(strategyReturns) != len(benchmark)-1 {
+				t.Fatalf("aligned %d periods, want %d", len(strategyReturns), len(benchmark)-1)
+			}
+			alpha, beta := regressOLS(benchmarkReturns, strategyReturns)
+			if !approxEqual(alpha, tt.wantAlpha, 1e-9) || !approxEqual(beta, tt.wantBeta, 1e-9) {
+				t.Errorf("alpha, beta = %v, %v, want %v, %v", alpha, beta, tt.wantAlpha, tt.wantBeta)
+			}
+		})
+	}
+}
+
+func TestAlignBenchmarkReturnsDropsUnmatchedPeriods(t *testing.T) {
+	benchmark, err := generateSyntheticKlines("chop", 10, 15, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodReturns := map[int64]float64{
+		benchmark[0].OpenTime: 0.01, // No previous close to measure the benchmark from
+		benchmark[3].OpenTime: 0.02,
+		benchmark[7].OpenTime: 0.03,
+		12345:                 0.04, // Not a benchmark candle
+	}
+	strategyReturns, benchmarkReturns := alignBenchmarkReturns(periodReturns, benchmark)
+	if !reflect.DeepEqual(strategyReturns, []float64{0.02, 0.03}) || len(benchmarkReturns) != 2 {
+		t.Errorf("aligned %v with %v, want the candle 3 and 7 returns", strategyReturns, benchmarkReturns)
+	}
+}
+
+func TestRegressOLSFlatBenchmark(t *testing.T) {
+	alpha, beta := regressOLS([]float64{0.01, 0.01, 0.01}, []float64{0.01, 0.02, 0.03})
+	if beta != 0 || !approxEqual(alpha, 0.02, 1e-12) {
+		t.Errorf("alpha, beta = %v, %v, want the mean 0.02 and 0", alpha, beta)
+	}
+}
+
+func TestBenchmarkOnReplay(t *testing.T) {
+	// Against its own symbol, a strategy that is only sometimes in the market has a beta
+	// between 0 and 1
+	config := replayConfig()
+	config.BenchmarkSymbol = "BTCUSDT"
+	result := runReplayBacktest(t, config)
+	if result.BenchmarkSymbol != "BTCUSDT" {
+		t.Fatalf("benchmark symbol = %q, want BTCUSDT", result.BenchmarkSymbol)
+	}
+	if result.Beta <= 0 || result.Beta > 1 {
+		t.Errorf("beta = %v, want it in (0, 1]", result.Beta)
+	}
+}
+
+// testCandle is the open, high, low and close of one simulated candle
+type testCandle struct {
+	open, high, low, close float64
+}
+
+// feedCandles runs candles through the engine's per-candle bracket steps in the order
+// RunBacktestOnKlines applies them, without signals
+func feedCandles(be *BacktestEngine, start time.Time, candles []testCandle) {
+	for _, c := range candles {
+		be.bar++
+		timestamp := start.Add(time.Duration(be.bar) * 15 * time.Minute)
+		be.checkBracket(c.open, c.high, c.low, timestamp)
+		be.portfolio.LastPrices[be.config.Symbol] = c.close
+		be.updateTrailingStop(c.close)
+	}
+}
+
+func TestTrailingStopExitsAtPeak(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		entry     string
+		candles   []testCandle
+		wantType  string
+		wantPrice float64
+	}{
+		{
+			name:  "long trails the highest close",
+			entry: "BUY",
+			candles: []testCandle{
+				{100, 102, 99, 101},
+				{101, 115, 100, 110}, // Peak 110, not the 115 high: trail at 110×0.95 = 104.5
+				{110, 110.5, 104, 105},
+			},
+			wantType:  "TRAIL",
+			wantPrice: 104.5,
+		},
+		{
+			name:  "short trails the lowest close",
+			entry: "SELL",
+			candles: []testCandle{
+				{100, 101, 98, 99},
+				{99, 100, 85, 90}, // Trough 90, not the 85 low: trail at 90×1.05 = 94.5
+				{90, 95, 89.5, 94},
+			},
+			wantType:  "TRAIL",
+			wantPrice: 94.5,
+		},
+		{
+			name:  "no rally trails the entry",
+			entry: "BUY",
+			candles: []testCandle{
+				{100, 100, 89, 89.5}, // Peak is still the entry: trail at 95, above the 90 stop
+			},
+			wantType:  "TRAIL",
+			wantPrice: 95,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(BacktestConfig{StopLossPct: 0.1, TrailingStopPct: 0.05, AllowShorting: true})
+			be.executeSignal(tt.entry, 100, start)
+			feedCandles(be, start, tt.candles)
+			if len(be.trades) != 2 {
+				t.Fatalf("got %d trades, want the entry and one exit", len(be.trades))
+			}
+			exit := be.trades[1]
+			if exit.Type != tt.wantType || !approxEqual(exit.Price, tt.wantPrice, 1e-9) {
+				t.Errorf("exit %s at %v, want %s at %v", exit.Type, exit.Price, tt.wantType, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestTrailingStopKeepsPeakOnAdd(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	be := newTestEngine(BacktestConfig{TrailingStopPct: 0.05, PositionSizePct: 0.25})
+	be.executeSignal("BUY", 100, start)
+	feedCandles(be, start, []testCandle{{100, 121, 99, 120}})
+	be.executeSignal("BUY", 110, start.Add(15*time.Minute)) // Pyramid add below the peak
+
+	if be.bracket == nil || be.bracket.Peak != 120 || !approxEqual(be.bracket.Trail, 114, 1e-9) {
+		t.Fatalf("bracket after add = %+v, want the 120 peak and 114 trail kept", be.bracket)
+	}
+}
+
+func TestMinHoldAndCooldownSpacing(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		minHold  int
+		cooldown int
+		wantBars []int
+	}{
+		{"no spacing", 0, 0, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"min hold", 3, 0, []int{0, 3, 4, 7, 8, 11}},
+		{"cooldown", 0, 2, []int{0, 1, 4, 5, 8, 9}},
+		{"both", 3, 2, []int{0, 3, 6, 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(BacktestConfig{MinHoldPeriods: tt.minHold, CooldownPeriods: tt.cooldown})
+			var gotBars []int
+			for bar := 0; bar < 12; bar++ {
+				be.bar = bar
+				signal := "BUY"
+				if bar%2 == 1 {
+					signal = "SELL"
+				}
+				before := len(be.trades)
+				be.executeSignal(signal, 100, start.Add(time.Duration(bar)*15*time.Minute))
+				if len(be.trades) > before {
+					gotBars = append(gotBars, bar)
+				}
+			}
+			if !reflect.DeepEqual(gotBars, tt.wantBars) {
+				t.Errorf("traded on candles %v, want %v", gotBars, tt.wantBars)
+			}
+		})
+	}
+}
+
+func TestIntervalDuration(t *testing.T) {
+	tests := []struct {
+		interval string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{"1m", time.Minute, false},
+		{"15m", 15 * time.Minute, false},
+		{"1h", time.Hour, false},
+		{"4h", 4 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"1x", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := intervalDuration(tt.interval)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("intervalDuration(%q) = %v, %v; want %v (error %v)", tt.interval, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRunBacktestOnKlinesRejectsUnknownInterval(t *testing.T) {
+	klines, err := generateSyntheticKlines("chop", 100, 60, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	be := newTestEngine(BacktestConfig{Interval: "7x"})
+	if _, err := be.RunBacktestOnKlines(klines); err == nil {
+		t.Error("expected an error for an unsupported interval")
+	}
+}
+
+func TestCalculateVaR(t *testing.T) {
+	// Per-trade returns of -10% to +9% in 1% steps, shuffled
+	returns := make([]float64, 20)
+	for i := range returns {
+		returns[i] = float64((i*7)%20-10) / 100
+	}
+	tests := []struct {
+		name       string
+		returns    []float64
+		confidence float64
+		wantVaR    float64
+		wantCVaR   float64
+	}{
+		{"95%", returns, 0.95, 0.10, 0.10},
+		{"90%", returns, 0.90, 0.09, 0.095},
+		{"80%", returns, 0.80, 0.07, 0.085},
+		{"tail below one trade", returns[:3], 0.99, 0.10, 0.10},
+		{"only gains", []float64{0.01, 0.02}, 0.95, -0.01, -0.01},
+		{"no trades", nil, 0.95, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := append([]float64(nil), tt.returns...)
+			valueAtRisk, expectedShortfall := calculateVaR(tt.returns, tt.confidence)
+			if !approxEqual(valueAtRisk, tt.wantVaR, 1e-12) || !approxEqual(expectedShortfall, tt.wantCVaR, 1e-12) {
+				t.Errorf("VaR, CVaR = %v, %v, want %v, %v", valueAtRisk, expectedShortfall, tt.wantVaR, tt.wantCVaR)
+			}
+			if !reflect.DeepEqual(tt.returns, before) {
+				t.Error("calculateVaR reordered its input")
+			}
+		})
+	}
+}
+
+// scriptedStrategy signals the action scripted for each candle index and HOLD elsewhere
+type scriptedStrategy struct {
+	name    string
+	actions map[int]string
+}
+
+func (s scriptedStrategy) Name() string { return s.name }
+
+func (s scriptedStrategy) Evaluate(symbol string, ts *techan.TimeSeries, index int) string {
+	if action, exists := s.actions[index]; exists {
+		return action
+	}
+	return "HOLD"
+}
+
+// useScript makes the strategy signal actions (candle index -> action) for the rest of the test
+func useScript(t *testing.T, actions map[int]string) {
+	t.Helper()
+	script := scriptedStrategy{name: "script-" + t.Name(), actions: actions}
+	RegisterStrategy(script)
+	t.Cleanup(func() { delete(strategyRegistry, script.name) })
+	sc := DefaultStrategyConfig()
+	sc.Name = script.name
+	withStrategy(t, sc)
+}
+
+// testKlines returns 15m klines closing at closes, each opening at the previous close,
+// with the candle's range spanning just its open and close
+func testKlines(closes []float64) []BinanceKline {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	klines := make([]BinanceKline, len(closes))
+	for i, close := range closes {
+		open := close
+		if i > 0 {
+			open = closes[i-1]
+		}
+		openTime := start.Add(time.Duration(i) * 15 * time.Minute)
+		klines[i] = BinanceKline{
+			OpenTime:  openTime.UnixMilli(),
+			Open:      strconv.FormatFloat(open, 'f', -1, 64),
+			High:      strconv.FormatFloat(math.Max(open, close), 'f', -1, 64),
+			Low:       strconv.FormatFloat(math.Min(open, close), 'f', -1, 64),
+			Close:     strconv.FormatFloat(close, 'f', -1, 64),
+			Volume:    "1000",
+			CloseTime: openTime.Add(15*time.Minute).UnixMilli() - 1,
+		}
+	}
+	return klines
+}
+
+// flatCloses returns count closes at price
+func flatCloses(count int, price float64) []float64 {
+	closes := make([]float64, count)
+	for i := range closes {
+		closes[i] = price
+	}
+	return closes
+}
+
+func TestExposurePct(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	tests := []struct {
+		name    string
+		actions map[int]string
+		want    float64
+	}{
+		{"never in the market", nil, 0},
+		{"a quarter of the candles", map[int]string{warmup + 10: "BUY", warmup + 35: "SELL"}, 25},
+		{"two positions", map[int]string{warmup: "BUY", warmup + 10: "SELL", warmup + 50: "BUY", warmup + 60: "SELL"}, 20},
+		{"held to the end", map[int]string{warmup + 40: "BUY"}, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useScript(t, tt.actions)
+			be := newTestEngine(BacktestConfig{Interval: "15m"})
+			result, err := be.RunBacktestOnKlines(testKlines(flatCloses(warmup+100, 100)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !approxEqual(result.ExposurePct, tt.want, 1e-9) {
+				t.Errorf("exposure = %.2f%%, want %.2f%%", result.ExposurePct, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeeOverrides(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	overrides := map[string]float64{"ETHUSDT": 0.002, "SOLUSDT": 0}
+	tests := []struct {
+		symbol  string
+		wantFee float64
+	}{
+		{"ETHUSDT", 0.002},
+		{"SOLUSDT", 0},
+		{"BTCUSDT", 0.001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			be := newTestEngine(BacktestConfig{Symbol: tt.symbol, TransactionFee: 0.001, FeeOverrides: overrides})
+			be.executeSignal("BUY", 100, start)
+			be.executeSignal("SELL", 110, start.Add(time.Minute))
+			if len(be.trades) != 2 {
+				t.Fatalf("got %d trades, want 2", len(be.trades))
+			}
+			for _, trade := range be.trades {
+				if rate := trade.Fee / (trade.Price * trade.Quantity); !approxEqual(rate, tt.wantFee, 1e-12) {
+					t.Errorf("%s fee rate = %v, want %v", trade.Type, rate, tt.wantFee)
+				}
+			}
+		})
+	}
+}
+
+func TestMakerTakerFees(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name      string
+		config    BacktestConfig
+		orderType string
+		wantFee   float64
+	}{
+		{"market pays taker", BacktestConfig{MakerFee: f(0.0002), TakerFee: f(0.001)}, OrderTypeMarket, 0.001},
+		{"limit pays maker", BacktestConfig{MakerFee: f(0.0002), TakerFee: f(0.001)}, OrderTypeLimit, 0.0002},
+		{"single fee, market", BacktestConfig{TransactionFee: 0.001}, OrderTypeMarket, 0.001},
+		{"single fee, limit", BacktestConfig{TransactionFee: 0.001}, OrderTypeLimit, 0.001},
+		{"unset taker falls back", BacktestConfig{TransactionFee: 0.001, MakerFee: f(0.0005)}, OrderTypeMarket, 0.001},
+		{"set maker wins", BacktestConfig{TransactionFee: 0.001, MakerFee: f(0.0005)}, OrderTypeLimit, 0.0005},
+		{"override beats maker", BacktestConfig{Symbol: "ETHUSDT", MakerFee: f(0.0002), TakerFee: f(0.001),
+			FeeOverrides: map[string]float64{"ETHUSDT": 0.002}}, OrderTypeLimit, 0.002},
+		{"zero maker fee", BacktestConfig{TransactionFee: 0.001, MakerFee: f(0)}, OrderTypeLimit, 0},
+		{"zero taker fee", BacktestConfig{TransactionFee: 0.001, TakerFee: f(0)}, OrderTypeMarket, 0},
+		{"zero fee", BacktestConfig{MakerFee: f(0.0002), TakerFee: f(0.001), ZeroFee: true}, OrderTypeMarket, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(tt.config)
+			symbol := be.config.Symbol
+			if !be.ExecuteTrade(symbol, "BUY", tt.orderType, 100, start) ||
+				!be.ExecuteTrade(symbol, "SELL", tt.orderType, 110, start.Add(time.Minute)) {
+				t.Fatal("trade not executed")
+			}
+			for _, trade := range be.trades {
+				if rate := trade.Fee / (trade.Price * trade.Quantity); !approxEqual(rate, tt.wantFee, 1e-12) {
+					t.Errorf("%s fee rate = %v, want %v", trade.Type, rate, tt.wantFee)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeeOverrides(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{"", map[string]float64{}, false},
+		{"BTCUSDT:0.00075", map[string]float64{"BTCUSDT": 0.00075}, false},
+		{" ethusdt : 0.001 ,SOLUSDT:0", map[string]float64{"ETHUSDT": 0.001, "SOLUSDT": 0}, false},
+		{"BTCUSDT", nil, true},
+		{"BTCUSDT:abc", nil, true},
+		{"BTCUSDT:-0.1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFeeOverrides(tt.spec)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseFeeOverrides(%q) = %v, %v; want %v (error %v)", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRunBacktestOnKlinesRejectsMismatchedInterval(t *testing.T) {
+	klines, err := generateSyntheticKlines("chop", 100, 15, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	be := newTestEngine(BacktestConfig{Interval: "1h"})
+	if _, err := be.RunBacktestOnKlines(klines); err == nil || !strings.Contains(err.Error(), "not 1h candles") {
+		t.Errorf("err = %v, want 15m klines rejected as 1h candles", err)
+	}
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	tests := []struct {
+		interval string
+		calendar string
+		want     float64
+	}{
+		{"1d", "", 365},
+		{"1d", "24x7", 365},
+		{"1d", "weekdays", 252},
+		{"1h", "24x7", 365 * 24},
+		{"1h", "weekdays", 252 * 24},
+		{"15m", "weekdays", 252 * 96},
+		{"bogus", "weekdays", 252},
+	}
+	for _, tt := range tests {
+		be := newTestEngine(BacktestConfig{Interval: tt.interval, TradingCalendar: tt.calendar})
+		if got := be.periodsPerYear(); got != tt.want {
+			t.Errorf("periodsPerYear(%s, %q) = %v, want %v", tt.interval, tt.calendar, got, tt.want)
+		}
+	}
+}
+
+func TestTradingCalendarChangesSharpe(t *testing.T) {
+	closes := make([]float64, 300)
+	for i := range closes {
+		closes[i] = 100 + float64(i%7) + float64(i)/10
+	}
+	warmup := DefaultStrategyConfig().Warmup()
+	useScript(t, map[int]string{warmup: "BUY"})
+
+	sharpe := map[string]float64{}
+	for _, calendar := range []string{"24x7", "weekdays"} {
+		result, err := newTestEngine(BacktestConfig{Interval: "15m", TradingCalendar: calendar}).
+			RunBacktestOnKlines(testKlines(closes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sharpe[calendar] = result.SharpeRatio
+	}
+	// Sharpe scales with the square root of the periods per year
+	if sharpe["24x7"] == 0 {
+		t.Fatal("24x7 Sharpe is 0; the series must move for the calendars to differ")
+	}
+	if want := sharpe["24x7"] * math.Sqrt(252.0/365); !approxEqual(sharpe["weekdays"], want, 1e-9) {
+		t.Errorf("weekdays Sharpe = %v, want %v (24x7 Sharpe %v × √(252/365))", sharpe["weekdays"], want, sharpe["24x7"])
+	}
+}
+
+func TestEntryTiming(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	closes := make([]float64, warmup+20)
+	for i := range closes {
+		closes[i] = 100 + float64(i)
+	}
+	signalBar := warmup + 5
+	klines := testKlines(closes)
+	klines[signalBar+1].Open = "150" // Gap away from the signal candle's close
+
+	tests := []struct {
+		name       string
+		timing     string
+		signalBar  int
+		wantTrades int
+		wantPrice  float64
+		wantTime   time.Time
+	}{
+		{"close", "close", signalBar, 1, closes[signalBar], time.UnixMilli(klines[signalBar].OpenTime)},
+		{"default is close", "", signalBar, 1, closes[signalBar], time.UnixMilli(klines[signalBar].OpenTime)},
+		{"next open", "next_open", signalBar, 1, 150, time.UnixMilli(klines[signalBar+1].OpenTime)},
+		{"no next candle", "next_open", len(closes) - 1, 0, 0, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useScript(t, map[int]string{tt.signalBar: "BUY"})
+			result, err := newTestEngine(BacktestConfig{Interval: "15m", EntryTiming: tt.timing}).RunBacktestOnKlines(klines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Trades) != tt.wantTrades {
+				t.Fatalf("got %d trades, want %d", len(result.Trades), tt.wantTrades)
+			}
+			if tt.wantTrades > 0 {
+				fill := result.Trades[0]
+				if fill.Price != tt.wantPrice || !fill.Timestamp.Equal(tt.wantTime) {
+					t.Errorf("filled at %v on %v, want %v on %v", fill.Price, fill.Timestamp, tt.wantPrice, tt.wantTime)
+				}
+			}
+		})
+	}
+}
+
+func TestResultDeltas(t *testing.T) {
+	a := &BacktestResult{FinalValue: 10500, TotalReturnPct: 5, BuyAndHoldReturnPct: 2, MaxDrawdownPct: 8,
+		SharpeRatio: 1.2, TotalTrades: 10, WinRate: 50, AverageWin: 120, AverageLoss: -80, ExposurePct: 40}
+	b := &BacktestResult{FinalValue: 10200, TotalReturnPct: 2, BuyAndHoldReturnPct: 2, MaxDrawdownPct: 5,
+		SharpeRatio: 0.7, TotalTrades: 6, WinRate: 50, AverageWin: 90, AverageLoss: -60, ExposurePct: 25}
+	want := map[string]float64{
+		"Final Value":    -300,
+		"Total Return %": -3,
+		"Alpha %":        -3,
+		"Max Drawdown %": -3,
+		"Sharpe Ratio":   -0.5,
+		"Total Trades":   -4,
+		"Win Rate %":     0,
+		"Average Win":    -30,
+		"Average Loss":   20,
+		"Exposure %":     -15,
+	}
+
+	deltas := resultDeltas(a, b)
+	if len(deltas) != len(want) {
+		t.Fatalf("got %d deltas, want %d", len(deltas), len(want))
+	}
+	for _, d := range deltas {
+		wantDelta, exists := want[d.Name]
+		if !exists {
+			t.Errorf("unexpected metric %q", d.Name)
+			continue
+		}
+		if !approxEqual(d.Delta, wantDelta, 1e-9) || !approxEqual(d.Delta, d.B-d.A, 1e-9) {
+			t.Errorf("%s: delta %v (A %v, B %v), want %v", d.Name, d.Delta, d.A, d.B, wantDelta)
+		}
+	}
+}
+
+func TestDiffResultsTrades(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC) }
+	shared := Trade{Type: "BUY", Symbol: "BTCUSDT", Price: 100, Timestamp: at(1)}
+	a := &BacktestResult{Symbol: "BTCUSDT", Trades: []Trade{shared, {Type: "SELL", Symbol: "BTCUSDT", Price: 110, Timestamp: at(2)}}}
+	b := &BacktestResult{Symbol: "BTCUSDT", Trades: []Trade{shared, {Type: "SELL", Symbol: "BTCUSDT", Price: 105, Timestamp: at(3)}}}
+
+	out := captureStdout(t, func() { DiffResults(a, b) })
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"A only: SELL BTCUSDT at $110.00 (2024-01-01 02:00)", true},
+		{"B only: SELL BTCUSDT at $105.00 (2024-01-01 03:00)", true},
+		{"BUY BTCUSDT at $100.00", false},
+	}
+	for _, tt := range tests {
+		if strings.Contains(out, tt.line) != tt.want {
+			t.Errorf("output contains %q = %v, want %v:\n%s", tt.line, !tt.want, tt.want, out)
+		}
+	}
+}
+
+func TestEstimateCapacity(t *testing.T) {
+	tests := []struct {
+		name      string
+		notionals []float64
+		rate      float64
+		want      float64
+	}{
+		{"no entries", nil, 0.01, 0},
+		{"single entry", []float64{1e6}, 0.01, 1e4},
+		{"thinnest candle limits", []float64{5e6, 2e5, 1e6}, 0.01, 2e3},
+		{"higher participation", []float64{5e6, 2e5, 1e6}, 0.05, 1e4},
+	}
+	for _, tt := range tests {
+		if got := estimateCapacity(tt.notionals, tt.rate); !approxEqual(got, tt.want, 1e-9) {
+			t.Errorf("%s: capacity = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCapacityFromEntryVolume(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	klines := testKlines(flatCloses(warmup+40, 100))
+	klines[warmup+5].Volume = "500"  // $50,000 traded on the first entry candle
+	klines[warmup+20].Volume = "200" // $20,000 on the second
+	klines[warmup+10].Volume = "10"  // Thin exit candles don't limit entries
+	useScript(t, map[int]string{warmup + 5: "BUY", warmup + 10: "SELL", warmup + 20: "BUY", warmup + 30: "SELL"})
+
+	tests := []struct {
+		rate float64
+		want float64
+	}{
+		{0, 200}, // Defaults to 1% of $20,000
+		{0.05, 1000},
+	}
+	for _, tt := range tests {
+		result, err := newTestEngine(BacktestConfig{Interval: "15m", ParticipationRate: tt.rate}).RunBacktestOnKlines(klines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !approxEqual(result.CapacityUSD, tt.want, 1e-9) {
+			t.Errorf("participation %v: capacity = %v, want %v", tt.rate, result.CapacityUSD, tt.want)
+		}
+	}
+}
+
+// roundTripsWithPnL returns round trips with the given P&Ls, in order
+func roundTripsWithPnL(pnls ...float64) []RoundTrip {
+	roundTrips := make([]RoundTrip, len(pnls))
+	for i, pnl := range pnls {
+		roundTrips[i] = RoundTrip{PnL: pnl}
+	}
+	return roundTrips
+}
+
+func TestCalculateRollingWinRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		pnls   []float64
+		window int
+		want   []float64
+	}{
+		{"fewer trades than the window", []float64{1, 1}, 3, nil},
+		{"no window", []float64{1, -1}, 0, nil},
+		{"all wins", []float64{1, 2, 3}, 2, []float64{100, 100}},
+		{"breakeven counts as a loss", []float64{1, 0, -1, 1}, 2, []float64{50, 0, 50}},
+		{"one window", []float64{1, -1, 1, 1}, 4, []float64{75}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculateRollingWinRate(roundTripsWithPnL(tt.pnls...), tt.window); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollingWinRateDropsWhenTheEdgeDecays(t *testing.T) {
+	// Mostly winners in the first half, mostly losers in the second
+	pnls := []float64{5, 3, -1, 4, 6, 2, -2, 3, 1, 4, -3, -1, 2, -4, -2, -1, -5, 1, -2, -3}
+	rolling := calculateRollingWinRate(roundTripsWithPnL(pnls...), 5)
+	if len(rolling) != len(pnls)-4 {
+		t.Fatalf("got %d windows, want %d", len(rolling), len(pnls)-4)
+	}
+	first, last := rolling[0], rolling[len(rolling)-1]
+	if first != 80 || last != 20 {
+		t.Errorf("win rate went from %v%% to %v%%, want 80%% to 20%%", first, last)
+	}
+	half := len(rolling) / 2
+	if calculateMean(rolling[half:]) >= calculateMean(rolling[:half]) {
+		t.Errorf("rolling win rate didn't drop: %v", rolling)
+	}
+}
+
+func TestCalculateWeightedTradeStats(t *testing.T) {
+	// Two early winners followed by two losers: the edge is fading
+	fading := []RoundTrip{{PnL: 10, Return: 0.1}, {PnL: 10, Return: 0.1}, {PnL: -5, Return: -0.05}, {PnL: -5, Return: -0.05}}
+	tests := []struct {
+		name           string
+		roundTrips     []RoundTrip
+		decay          float64
+		wantWinRate    float64
+		wantExpectancy float64
+	}{
+		{"no trades", nil, 0.9, 0, 0},
+		{"decay 1 is the plain average", fading, 1, 50, 2.5},
+		// Weights 0.125, 0.25, 0.5, 1 from oldest to newest, 1.875 in total
+		{"recent losers weigh more", fading, 0.5, 0.375 / 1.875 * 100, -0.0375 / 1.875 * 100},
+		{"single trade", fading[:1], 0.5, 100, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			winRate, expectancy := calculateWeightedTradeStats(tt.roundTrips, tt.decay)
+			if !approxEqual(winRate, tt.wantWinRate, 1e-9) || !approxEqual(expectancy, tt.wantExpectancy, 1e-9) {
+				t.Errorf("got %.4f%% win rate, %.4f%% expectancy; want %.4f%%, %.4f%%",
+					winRate, expectancy, tt.wantWinRate, tt.wantExpectancy)
+			}
+		})
+	}
+
+	weighted, _ := calculateWeightedTradeStats(fading, 0.9)
+	unweighted, _ := calculateWeightedTradeStats(fading, 1)
+	if weighted >= unweighted {
+		t.Errorf("weighted win rate %.2f%% should be below the unweighted %.2f%% when recent trades lose", weighted, unweighted)
+	}
+}
+
+func TestCalculateAutocorrelation(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		lag    int
+		want   float64
+	}{
+		{"alternating", []float64{0.02, -0.02, 0.02, -0.02, 0.02, -0.02}, 1, -5.0 / 6},
+		{"streaks", []float64{0.01, 0.01, 0.01, -0.01, -0.01, -0.01}, 1, 0.5},
+		{"lag two on alternating", []float64{1, -1, 1, -1, 1, -1}, 2, 4.0 / 6},
+		{"constant", []float64{0.01, 0.01, 0.01}, 1, 0},
+		{"too short", []float64{0.01}, 1, 0},
+		{"no lag", []float64{1, -1, 1}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculateAutocorrelation(tt.values, tt.lag); !approxEqual(got, tt.want, 1e-12) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReturnAutocorrOnAlternatingTrades(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	closes := flatCloses(warmup+40, 100)
+	actions := map[int]string{}
+	for k := 0; k < 8; k++ {
+		entry := warmup + 4*k
+		actions[entry], actions[entry+1] = "BUY", "SELL"
+		closes[entry+1] = 110
+		if k%2 == 1 {
+			closes[entry+1] = 90
+		}
+	}
+	useScript(t, actions)
+
+	result, err := newTestEngine(BacktestConfig{Interval: "15m", TransactionFee: 0.001}).RunBacktestOnKlines(testKlines(closes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.RoundTrips) != 8 {
+		t.Fatalf("got %d round trips, want 8", len(result.RoundTrips))
+	}
+	if result.ReturnAutocorr >= -0.5 {
+		t.Errorf("autocorrelation of alternating wins and losses = %.3f, want strongly negative", result.ReturnAutocorr)
+	}
+}
+
+func TestBracketOCO(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		entry     string
+		tieBreak  string
+		candle    testCandle
+		wantType  string // Empty when the position should stay open
+		wantPrice float64
+	}{
+		{"range spans both, stop wins by default", "BUY", "", testCandle{100, 111, 94, 100}, "STOP", 95},
+		{"range spans both, target tie-break", "BUY", "target", testCandle{100, 111, 94, 100}, "TARGET", 110},
+		{"target only", "BUY", "", testCandle{100, 111, 99, 108}, "TARGET", 110},
+		{"stop only", "BUY", "", testCandle{100, 101, 94, 96}, "STOP", 95},
+		{"gap through the target fills at the open", "BUY", "", testCandle{112, 113, 111, 112}, "TARGET", 112},
+		{"gap through the stop fills at the open", "BUY", "target", testCandle{90, 111, 89, 100}, "STOP", 90},
+		{"neither level", "BUY", "", testCandle{100, 109, 96, 101}, "", 0},
+		{"short spans both, stop wins by default", "SELL", "", testCandle{100, 106, 89, 100}, "STOP", 105},
+		{"short target", "SELL", "", testCandle{100, 101, 89, 92}, "TARGET", 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(BacktestConfig{StopLossPct: 0.05, TakeProfitPct: 0.1,
+				BracketTieBreak: tt.tieBreak, AllowShorting: true})
+			be.executeSignal(tt.entry, 100, start)
+			feedCandles(be, start, []testCandle{tt.candle})
+
+			if tt.wantType == "" {
+				if len(be.trades) != 1 {
+					t.Fatalf("got %d trades, want the position left open", len(be.trades))
+				}
+				return
+			}
+			if len(be.trades) != 2 {
+				t.Fatalf("got %d trades, want the entry and one exit", len(be.trades))
+			}
+			exit := be.trades[1]
+			if exit.Type != tt.wantType || !approxEqual(exit.Price, tt.wantPrice, 1e-9) {
+				t.Errorf("exit %s at %v, want %s at %v", exit.Type, exit.Price, tt.wantType, tt.wantPrice)
+			}
+
+			// The other leg is cancelled: a later candle through both levels trades nothing
+			feedCandles(be, start, []testCandle{{100, 120, 80, 100}})
+			if len(be.trades) != 2 {
+				t.Errorf("got %d trades after the exit, want the other leg cancelled", len(be.trades))
+			}
+		})
+	}
+}
+
+func TestSkippedTradeCounters(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		config  BacktestConfig
+		signals []string // One per candle; "" for no signal
+		want    map[string]int
+	}{
+		{"nothing skipped", BacktestConfig{}, []string{"BUY", "SELL"}, map[string]int{}},
+		{"no holdings", BacktestConfig{}, []string{"SELL", "SELL"}, map[string]int{skipNoHoldings: 2}},
+		{"insufficient funds", BacktestConfig{}, []string{"BUY", "BUY", "BUY"}, map[string]int{skipInsufficientFunds: 2}},
+		{"already short", BacktestConfig{AllowShorting: true}, []string{"SELL", "SELL"}, map[string]int{skipAlreadyShort: 1}},
+		{"daily limit", BacktestConfig{MaxTradesPerDay: 1}, []string{"BUY", "SELL", "BUY"}, map[string]int{skipDailyLimit: 1}},
+		{
+			"minimum hold and cooldown",
+			BacktestConfig{MinHoldPeriods: 2, CooldownPeriods: 2},
+			[]string{"BUY", "SELL", "SELL", "BUY", "", "BUY"},
+			map[string]int{skipMinHold: 1, skipCooldown: 1},
+		},
+		{
+			"several reasons",
+			BacktestConfig{MinHoldPeriods: 3},
+			[]string{"SELL", "BUY", "BUY", "SELL", "SELL", "SELL"},
+			map[string]int{skipNoHoldings: 2, skipInsufficientFunds: 1, skipMinHold: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(tt.config)
+			for bar, signal := range tt.signals {
+				be.bar = bar
+				be.executeSignal(signal, 100, start.Add(time.Duration(bar)*15*time.Minute))
+			}
+			if !reflect.DeepEqual(be.skipped, tt.want) {
+				t.Errorf("skipped %v, want %v", be.skipped, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkippedTradesOnResult(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	useScript(t, map[int]string{warmup: "SELL", warmup + 1: "BUY", warmup + 2: "BUY"})
+	result, err := newTestEngine(BacktestConfig{Interval: "15m"}).RunBacktestOnKlines(testKlines(flatCloses(warmup+5, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{skipNoHoldings: 1, skipInsufficientFunds: 1}
+	if !reflect.DeepEqual(result.SkippedTrades, want) {
+		t.Errorf("SkippedTrades = %v, want %v", result.SkippedTrades, want)
+	}
+	if out := captureStdout(t, func() { PrintBacktestResults(result) }); !strings.Contains(out, "no holdings to sell:") {
+		t.Errorf("report doesn't list the skipped trades:\n%s", out)
+	}
+}
+
+func TestStopLossAndTakeProfitOnKlines(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	tests := []struct {
+		name      string
+		low, high string // Range of the candle after the entry, which opens and closes at 99
+		sellThere bool   // Also signal SELL on that candle
+		wantType  string
+		wantPrice float64
+	}{
+		{"dip through the stop", "90", "99.5", false, "STOP", 98},
+		{"spike through the target", "98.5", "106", false, "TARGET", 105},
+		{"stop checked before the signal", "90", "99.5", true, "STOP", 98},
+		{"no level reached", "98.5", "104", true, "SELL", 99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closes := flatCloses(warmup+5, 100)
+			closes[warmup+1], closes[warmup+2] = 99, 99
+			klines := testKlines(closes)
+			klines[warmup+1].Open = "99" // Opens and closes between the levels; only the wick reaches one
+			klines[warmup+1].Low, klines[warmup+1].High = tt.low, tt.high
+			actions := map[int]string{warmup: "BUY"}
+			if tt.sellThere {
+				actions[warmup+1] = "SELL"
+			}
+			useScript(t, actions)
+
+			result, err := newTestEngine(BacktestConfig{Interval: "15m", StopLossPct: 0.02, TakeProfitPct: 0.05}).RunBacktestOnKlines(klines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Trades) != 2 {
+				t.Fatalf("got %d trades, want the entry and one exit", len(result.Trades))
+			}
+			exit := result.Trades[1]
+			if exit.Type != tt.wantType || !approxEqual(exit.Price, tt.wantPrice, 1e-9) {
+				t.Errorf("exit %s at %v, want %s at %v", exit.Type, exit.Price, tt.wantType, tt.wantPrice)
+			}
+			if len(result.RoundTrips) != 1 || result.RoundTrips[0].ExitType != tt.wantType {
+				t.Errorf("round trips = %+v, want one closed by %s", result.RoundTrips, tt.wantType)
+			}
+		})
+	}
+}
+
+// recordingStrategy delegates to another registered strategy and records each signal by the
+// index it was asked about
+type recordingStrategy struct {
+	name    string
+	inner   SignalStrategy
+	signals map[int]string
+}
+
+func (s recordingStrategy) Name() string { return s.name }
+
+func (s recordingStrategy) Evaluate(symbol string, ts *techan.TimeSeries, index int) string {
+	signal := s.inner.Evaluate(symbol, ts, index)
+	s.signals[index] = signal
+	return signal
+}
+
+func TestRunBacktestSignalsMatchRebuiltSeries(t *testing.T) {
+	klines, err := generateSyntheticKlines("mean_reverting", 300, 15, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"classic", "stochastic", "bollinger"} {
+		t.Run(name, func(t *testing.T) {
+			sc := DefaultStrategyConfig()
+			sc.Name = name
+			recorder := recordingStrategy{name: "record-" + name, inner: strategyRegistry[name], signals: map[int]string{}}
+			RegisterStrategy(recorder)
+			t.Cleanup(func() { delete(strategyRegistry, recorder.name) })
+			sc.Name = recorder.name
+			withStrategy(t, sc)
+
+			if _, err := newTestEngine(BacktestConfig{Interval: "15m"}).RunBacktestOnKlines(klines); err != nil {
+				t.Fatal(err)
+			}
+			if len(recorder.signals) == 0 {
+				t.Fatal("strategy was never evaluated")
+			}
+
+			// The old loop copied candles 0..i into a fresh series for every candle
+			trades := 0
+			for i, got := range recorder.signals {
+				prefix := techan.NewTimeSeries()
+				for _, kline := range klines[:i+1] {
+					prefix.AddCandle(klineToCandle(kline, 15*time.Minute))
+				}
+				if want := recorder.inner.Evaluate("TESTUSDT", prefix, prefix.LastIndex()); got != want {
+					t.Errorf("candle %d: got %s, want %s from the rebuilt series", i, got, want)
+				}
+				if got == "BUY" || got == "SELL" {
+					trades++
+				}
+			}
+			if trades == 0 {
+				t.Errorf("no BUY or SELL among %d signals; the comparison proves little", len(recorder.signals))
+			}
+		})
+	}
+}
+
+func BenchmarkRunBacktest(b *testing.B) {
+	klines, err := generateSyntheticKlines("mean_reverting", 1000, 15, 42)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := newTestEngine(BacktestConfig{Interval: "15m"}).RunBacktestOnKlines(klines); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPositionSizingDeploysCashGradually(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		sizePct   float64
+		wantCash  []float64 // Cash left after each of four BUYs at 100, without fees
+		wantTrade int
+	}{
+		{"all-in by default", 0, []float64{0, 0, 0, 0}, 1},
+		{"25% of cash per BUY", 0.25, []float64{7500, 5625, 4218.75, 3164.0625}, 4},
+		{"100% is all-in", 1, []float64{0, 0, 0, 0}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(BacktestConfig{PositionSizePct: tt.sizePct})
+			for i, want := range tt.wantCash {
+				be.executeSignal("BUY", 100, start.Add(time.Duration(i)*15*time.Minute))
+				if !approxEqual(be.portfolio.Cash, want, 1e-6) {
+					t.Errorf("cash after BUY %d = %v, want %v", i+1, be.portfolio.Cash, want)
+				}
+			}
+			if len(be.trades) != tt.wantTrade {
+				t.Errorf("got %d BUY trades, want %d", len(be.trades), tt.wantTrade)
+			}
+			if got := be.portfolio.Holdings["TESTUSDT"] * 100; !approxEqual(got+be.portfolio.Cash, 10000, 1e-6) {
+				t.Errorf("holdings worth %v plus cash %v don't add up to the 10000 deposited", got, be.portfolio.Cash)
+			}
+		})
+	}
+}
+
+func TestPyramidingAndScaleOut(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(bar int) time.Time { return start.Add(time.Duration(bar) * 15 * time.Minute) }
+	be := newTestEngine(BacktestConfig{PositionSizePct: 0.5, ScaleOutPct: 0.5})
+
+	be.executeSignal("BUY", 100, at(0)) // 50 units for 5000
+	be.executeSignal("BUY", 125, at(1)) // 20 units for 2500
+	if got, want := be.portfolio.AvgEntryPrice["TESTUSDT"], 7500.0/70; !approxEqual(got, want, 1e-9) {
+		t.Errorf("average entry = %v, want %v", got, want)
+	}
+
+	be.executeSignal("SELL", 150, at(2)) // Closes half: 35 units
+	if got := be.portfolio.Holdings["TESTUSDT"]; !approxEqual(got, 35, 1e-9) {
+		t.Fatalf("holding after scaling out = %v, want 35", got)
+	}
+	be.executeSignal("SELL", 150, at(3))
+	if got := be.portfolio.Holdings["TESTUSDT"]; !approxEqual(got, 17.5, 1e-9) {
+		t.Fatalf("holding after scaling out twice = %v, want 17.5", got)
+	}
+
+	// FIFO pairing closes the 50 units bought at 100 first, then 2.5 of those bought at 125
+	wantPnL := 50*50 + 2.5*25
+	roundTrips := pairTradesFIFO(be.trades)
+	var quantity, pnl float64
+	for _, rt := range roundTrips {
+		quantity += rt.Quantity
+		pnl += rt.PnL
+	}
+	if !approxEqual(quantity, 52.5, 1e-9) || !approxEqual(pnl, wantPnL, 1e-9) {
+		t.Errorf("round trips cover %v units for %v P&L, want 52.5 units for %v", quantity, pnl, wantPnL)
+	}
+}
+
+func TestShortSelling(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	trend := func(from, to float64) []float64 {
+		closes := flatCloses(warmup+11, from)
+		for i := 0; i <= 10; i++ {
+			closes[warmup+i] = from + (to-from)*float64(i)/10
+		}
+		return closes
+	}
+	tests := []struct {
+		name       string
+		shorting   bool
+		closes     []float64
+		wantTrades []string
+		wantPnL    float64 // Of the short, without fees
+	}{
+		{"falling market", true, trend(100, 80), []string{"SHORT", "COVER"}, 2000},
+		{"rising market", true, trend(100, 110), []string{"SHORT", "COVER"}, -1000},
+		{"shorting disabled", false, trend(100, 80), []string{"BUY"}, 0}, // The SELL is skipped
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useScript(t, map[int]string{warmup: "SELL", warmup + 10: "BUY"})
+			result, err := newTestEngine(BacktestConfig{Interval: "15m", AllowShorting: tt.shorting}).RunBacktestOnKlines(testKlines(tt.closes))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var types []string
+			for _, trade := range result.Trades {
+				types = append(types, trade.Type)
+			}
+			if !reflect.DeepEqual(types, tt.wantTrades) {
+				t.Fatalf("trades %v, want %v", types, tt.wantTrades)
+			}
+			if !tt.shorting {
+				if result.SkippedTrades[skipNoHoldings] != 1 {
+					t.Errorf("skipped %v, want the SELL skipped for no holdings", result.SkippedTrades)
+				}
+				return
+			}
+			if len(result.RoundTrips) != 1 || !result.RoundTrips[0].Short || result.RoundTrips[0].ExitType != "COVER" {
+				t.Fatalf("round trips = %+v, want one short closed by COVER", result.RoundTrips)
+			}
+			if got := result.RoundTrips[0].PnL; !approxEqual(got, tt.wantPnL, 1e-6) {
+				t.Errorf("P&L = %v, want %v", got, tt.wantPnL)
+			}
+			if !approxEqual(result.FinalValue, 10000+tt.wantPnL, 1e-6) {
+				t.Errorf("final value = %v, want %v", result.FinalValue, 10000+tt.wantPnL)
+			}
+		})
+	}
+}
+
+func TestPortfolioValueWhileShort(t *testing.T) {
+	be := newTestEngine(BacktestConfig{AllowShorting: true})
+	be.executeSignal("SELL", 100, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		price float64
+		want  float64
+	}{
+		{100, 10000},
+		{90, 11000},
+		{120, 8000},
+	}
+	for _, tt := range tests {
+		be.portfolio.LastPrices["TESTUSDT"] = tt.price
+		if got := be.GetPortfolioValue(); !approxEqual(got, tt.want, 1e-6) {
+			t.Errorf("value at %v = %v, want %v", tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestSlippageRoundTripAtFlatPrice(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		slippage float64
+		fee      float64
+		shorting bool
+		first    string
+	}{
+		{"no costs", 0, 0, false, "BUY"},
+		{"slippage only", 0.001, 0, false, "BUY"},
+		{"fees only", 0, 0.001, false, "BUY"},
+		{"slippage and fees", 0.001, 0.001, false, "BUY"},
+		{"short with slippage and fees", 0.001, 0.001, true, "SELL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := newTestEngine(BacktestConfig{SlippagePct: tt.slippage, TransactionFee: tt.fee, AllowShorting: tt.shorting})
+			second := map[string]string{"BUY": "SELL", "SELL": "BUY"}[tt.first]
+			be.executeSignal(tt.first, 100, start)
+			be.executeSignal(second, 100, start.Add(15*time.Minute))
+			if len(be.trades) != 2 {
+				t.Fatalf("got %d trades, want 2", len(be.trades))
+			}
+
+			// Buys fill above and sells below the requested price, and fees apply to the fill
+			buyPrice, sellPrice := 100*(1+tt.slippage), 100*(1-tt.slippage)
+			var want float64
+			if tt.shorting {
+				quantity := 10000 / (sellPrice * (1 + tt.fee))
+				want = 10000 + quantity*sellPrice*(1-tt.fee) - quantity*buyPrice*(1+tt.fee)
+			} else {
+				quantity := 10000 / (buyPrice * (1 + tt.fee))
+				want = quantity * sellPrice * (1 - tt.fee)
+			}
+			got := be.GetPortfolioValue()
+			if !approxEqual(got, want, 1e-6) {
+				t.Errorf("value after the round trip = %v, want %v", got, want)
+			}
+			if tt.slippage+tt.fee > 0 && got >= 10000 {
+				t.Errorf("a flat round trip with costs ended at %v, want a loss", got)
+			}
+			for _, trade := range be.trades {
+				buying := trade.Type == "BUY" || trade.Type == "COVER"
+				if wantPrice := map[bool]float64{true: buyPrice, false: sellPrice}[buying]; !approxEqual(trade.Price, wantPrice, 1e-9) {
+					t.Errorf("%s filled at %v, want %v", trade.Type, trade.Price, wantPrice)
+				}
+			}
+		})
+	}
+}
+
+func TestRunningStatsMatchesTwoPass(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+	}{
+		{"empty", nil},
+		{"single value", []float64{0.5}},
+		{"small returns", []float64{0.01, -0.02, 0.015, 0.003, -0.007, 0.012}},
+		{"constant", []float64{3, 3, 3, 3}},
+		{"large offset", []float64{1e9 + 1, 1e9 + 2, 1e9 + 3, 1e9 + 4}},
+		{"mixed magnitudes", []float64{1e-6, 250, -3.5, 42, 0, 1e4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rs runningStats
+			for _, v := range tt.values {
+				rs.Add(v)
+			}
+			mean := calculateMean(tt.values)
+			stdDev := calculateStdDev(tt.values, mean)
+			tolerance := 1e-9 * math.Max(1, math.Abs(mean))
+			if rs.Count() != len(tt.values) || !approxEqual(rs.Mean(), mean, tolerance) || !approxEqual(rs.StdDev(), stdDev, 1e-9) {
+				t.Errorf("running: n=%d mean=%v sd=%v; two-pass: n=%d mean=%v sd=%v",
+					rs.Count(), rs.Mean(), rs.StdDev(), len(tt.values), mean, stdDev)
+			}
+		})
+	}
+}
+
+func TestDownsampledSeries(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxPoints int
+		count     int
+		wantLen   int
+	}{
+		{"uncapped", 0, 1000, 1000},
+		{"under the cap", 100, 50, 50},
+		{"at the cap", 100, 100, 100},
+		{"long series", 100, 10000, 79},
+		{"cap of one keeps two", 1, 10, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := newDownsampledSeries(tt.maxPoints)
+			for i := 0; i < tt.count; i++ {
+				ds.Add(float64(i))
+			}
+			values := ds.Values()
+			if len(values) != tt.wantLen {
+				t.Errorf("kept %d points, want %d", len(values), tt.wantLen)
+			}
+			if tt.maxPoints > 0 && len(values) > int(math.Max(float64(tt.maxPoints), 2)) {
+				t.Errorf("kept %d points, more than the cap of %d", len(values), tt.maxPoints)
+			}
+			// Kept points are evenly spaced from the first one
+			stride := ds.stride
+			for i, v := range values {
+				if v != float64(i*stride) {
+					t.Fatalf("point %d = %v, want %v (stride %d)", i, v, float64(i*stride), stride)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxCurvePointsKeepsSummaryStats(t *testing.T) {
+	klines, err := generateSyntheticKlines("chop", 20000, 15, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[int]string{}
+	for bar := 100; bar < len(klines); bar += 50 {
+		actions[bar], actions[bar+20] = "BUY", "SELL"
+	}
+	useScript(t, actions)
+	run := func(maxPoints int) *BacktestResult {
+		result, err := newTestEngine(BacktestConfig{Interval: "15m", TransactionFee: 0.001, MaxCurvePoints: maxPoints}).RunBacktestOnKlines(klines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	full, capped := run(0), run(500)
+	if len(capped.EquityCurve) > 500 || len(capped.DailyReturns) > 500 {
+		t.Errorf("capped run stored %d equity and %d return points, want at most 500",
+			len(capped.EquityCurve), len(capped.DailyReturns))
+	}
+	if len(full.EquityCurve) < 10000 {
+		t.Fatalf("uncapped run stored only %d equity points", len(full.EquityCurve))
+	}
+	stats := []struct {
+		name         string
+		full, capped float64
+	}{
+		{"final value", full.FinalValue, capped.FinalValue},
+		{"max drawdown", full.MaxDrawdown, capped.MaxDrawdown},
+		{"Sharpe", full.SharpeRatio, capped.SharpeRatio},
+		{"win rate", full.WinRate, capped.WinRate},
+		{"trades", float64(full.TotalTrades), float64(capped.TotalTrades)},
+	}
+	for _, s := range stats {
+		if s.full != s.capped {
+			t.Errorf("%s = %v with the cap, want %v as without it", s.name, s.capped, s.full)
+		}
+	}
+}
+
+func TestSharpeRatioAnnualization(t *testing.T) {
+	// All-in from the first simulated candle on daily candles with no fees, so the per-period
+	// returns are exactly these: mean 0.005, sample standard deviation √0.00035 ≈ 0.0187083
+	returns := []float64{0.02, -0.01, 0.03, 0, -0.02, 0.01}
+	warmup := DefaultStrategyConfig().Warmup()
+	closes := flatCloses(warmup+1+len(returns), 100)
+	for i, r := range returns {
+		closes[warmup+1+i] = closes[warmup+i] * (1 + r)
+	}
+	useScript(t, map[int]string{warmup: "BUY"})
+
+	tests := []struct {
+		name         string
+		riskFreeRate float64
+		want         float64 // (mean - rf/365) / sd × √365
+	}{
+		{"no risk-free rate", 0, 0.005 / math.Sqrt(0.00035) * math.Sqrt(365)},               // ≈ 5.1060
+		{"3.65% risk-free", 0.0365, (0.005 - 0.0001) / math.Sqrt(0.00035) * math.Sqrt(365)}, // ≈ 5.0039
+		{"36.5% risk-free", 0.365, (0.005 - 0.001) / math.Sqrt(0.00035) * math.Sqrt(365)},   // ≈ 4.0848
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newTestEngine(BacktestConfig{Interval: "1d", RiskFreeRate: tt.riskFreeRate}).RunBacktestOnKlines(dailyKlines(closes))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.DailyReturns) != len(returns) {
+				t.Fatalf("got %d period returns, want %d", len(result.DailyReturns), len(returns))
+			}
+			if !approxEqual(result.SharpeRatio, tt.want, 1e-9) {
+				t.Errorf("Sharpe = %v, want %v", result.SharpeRatio, tt.want)
+			}
+		})
+	}
+}
+
+// dailyKlines is testKlines on 1d candles
+func dailyKlines(closes []float64) []BinanceKline {
+	klines := testKlines(closes)
+	start := time.UnixMilli(klines[0].OpenTime)
+	for i := range klines {
+		openTime := start.Add(time.Duration(i) * 24 * time.Hour)
+		klines[i].OpenTime = openTime.UnixMilli()
+		klines[i].CloseTime = openTime.Add(24*time.Hour).UnixMilli() - 1
+	}
+	return klines
+}
+
+func TestEdgeGateCheck(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	profitable := &BacktestResult{Symbol: "BTCUSDT", SharpeRatio: 1.2, ExpectancyPct: 0.8, RoundTrips: roundTripsWithPnL(10)}
+	losing := &BacktestResult{Symbol: "BTCUSDT", SharpeRatio: -0.4, ExpectancyPct: -0.3, RoundTrips: roundTripsWithPnL(-10)}
+	idle := &BacktestResult{Symbol: "BTCUSDT"}
+	tests := []struct {
+		name        string
+		gate        EdgeGate
+		result      *BacktestResult
+		wantReasons []string // Substrings of each reason
+	}{
+		{"disabled", EdgeGate{}, losing, nil},
+		{"profitable passes both", EdgeGate{MinSharpe: f(1), MinExpectancy: f(0)}, profitable, nil},
+		{"Sharpe below the minimum", EdgeGate{MinSharpe: f(1.5)}, profitable, []string{"Sharpe 1.200 is below the minimum 1.500"}},
+		{"negative expectancy", EdgeGate{MinExpectancy: f(0)}, losing, []string{"expectancy -0.30% per trade"}},
+		{"fails both", EdgeGate{MinSharpe: f(0), MinExpectancy: f(0)}, losing, []string{"Sharpe", "expectancy"}},
+		{"no trades to measure", EdgeGate{MinExpectancy: f(0)}, idle, []string{"no completed trades"}},
+		{"Sharpe at the minimum passes", EdgeGate{MinSharpe: f(0)}, idle, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons := tt.gate.Check(tt.result)
+			if len(reasons) != len(tt.wantReasons) {
+				t.Fatalf("got reasons %q, want %q", reasons, tt.wantReasons)
+			}
+			for i, want := range tt.wantReasons {
+				if !strings.Contains(reasons[i], want) {
+					t.Errorf("reason %d = %q, want it to mention %q", i, reasons[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPairTradesFIFO(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	trade := func(minute int, tradeType string, price, quantity, fee float64) Trade {
+		return Trade{Symbol: "TESTUSDT", Type: tradeType, Price: price, Quantity: quantity, Fee: fee,
+			Timestamp: start.Add(time.Duration(minute) * time.Minute)}
+	}
+	tests := []struct {
+		name   string
+		trades []Trade
+		want   []RoundTrip // Only prices, quantity, P&L, exit type and side are compared
+	}{
+		{
+			"two buys closed by one larger sell",
+			[]Trade{trade(0, "BUY", 100, 2, 0), trade(1, "BUY", 110, 3, 0), trade(2, "SELL", 120, 5, 0)},
+			[]RoundTrip{
+				{EntryPrice: 100, ExitPrice: 120, Quantity: 2, PnL: 40, ExitType: "SELL"},
+				{EntryPrice: 110, ExitPrice: 120, Quantity: 3, PnL: 30, ExitType: "SELL"},
+			},
+		},
+		{
+			"one buy closed by two sells",
+			[]Trade{trade(0, "BUY", 100, 4, 0), trade(1, "SELL", 90, 1, 0), trade(2, "TARGET", 130, 3, 0)},
+			[]RoundTrip{
+				{EntryPrice: 100, ExitPrice: 90, Quantity: 1, PnL: -10, ExitType: "SELL"},
+				{EntryPrice: 100, ExitPrice: 130, Quantity: 3, PnL: 90, ExitType: "TARGET"},
+			},
+		},
+		{
+			"oldest lot first, not the latest",
+			[]Trade{trade(0, "BUY", 100, 1, 0), trade(1, "BUY", 200, 1, 0), trade(2, "SELL", 150, 1, 0)},
+			[]RoundTrip{{EntryPrice: 100, ExitPrice: 150, Quantity: 1, PnL: 50, ExitType: "SELL"}},
+		},
+		{
+			"fees split pro rata",
+			[]Trade{trade(0, "BUY", 100, 2, 2), trade(1, "BUY", 100, 2, 4), trade(2, "SELL", 110, 4, 8)},
+			[]RoundTrip{
+				{EntryPrice: 100, ExitPrice: 110, Quantity: 2, PnL: 20 - 2 - 4, ExitType: "SELL"},
+				{EntryPrice: 100, ExitPrice: 110, Quantity: 2, PnL: 20 - 4 - 4, ExitType: "SELL"},
+			},
+		},
+		{
+			"short covered at a profit",
+			[]Trade{trade(0, "SHORT", 100, 3, 0), trade(1, "COVER", 80, 3, 0)},
+			[]RoundTrip{{EntryPrice: 100, ExitPrice: 80, Quantity: 3, PnL: 60, ExitType: "COVER", Short: true}},
+		},
+		{
+			"exit without an entry is ignored",
+			[]Trade{trade(0, "SELL", 100, 1, 0)},
+			[]RoundTrip{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pairTradesFIFO(tt.trades)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d round trips, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				rt := got[i]
+				if rt.EntryPrice != want.EntryPrice || rt.ExitPrice != want.ExitPrice || rt.ExitType != want.ExitType ||
+					rt.Short != want.Short || !approxEqual(rt.Quantity, want.Quantity, 1e-12) || !approxEqual(rt.PnL, want.PnL, 1e-9) {
+					t.Errorf("round trip %d = %+v, want %+v", i, rt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRMultiples(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	exits := []float64{110, 97, 105, 94} // The last one runs through the 95 stop
+	closes := flatCloses(warmup+len(exits)*3+1, 100)
+	actions := map[int]string{}
+	for k, exit := range exits {
+		bar := warmup + 3*k
+		actions[bar], actions[bar+1] = "BUY", "SELL"
+		closes[bar+1] = exit
+	}
+
+	tests := []struct {
+		name     string
+		stopLoss float64
+		wantR    []float64
+		wantAvg  float64
+	}{
+		{"5% stop", 0.05, []float64{2, -0.6, 1, -1}, 0.35},
+		{"10% stop", 0.10, []float64{1, -0.3, 0.5, -0.6}, 0.15},
+		{"no stop", 0, []float64{0, 0, 0, 0}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useScript(t, actions)
+			result, err := newTestEngine(BacktestConfig{Interval: "15m", StopLossPct: tt.stopLoss}).RunBacktestOnKlines(testKlines(closes))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.RoundTrips) != len(tt.wantR) {
+				t.Fatalf("got %d round trips, want %d", len(result.RoundTrips), len(tt.wantR))
+			}
+			for i, rt := range result.RoundTrips {
+				if !approxEqual(rt.RMultiple, tt.wantR[i], 1e-9) {
+					t.Errorf("round trip %d (%s at %v): R = %v, want %v", i, rt.ExitType, rt.ExitPrice, rt.RMultiple, tt.wantR[i])
+				}
+			}
+			if !approxEqual(result.AvgRMultiple, tt.wantAvg, 1e-9) {
+				t.Errorf("average R = %v, want %v", result.AvgRMultiple, tt.wantAvg)
+			}
+		})
+	}
+}
+
+func TestBestAndWorstTrades(t *testing.T) {
+	tests := []struct {
+		name      string
+		pnls      []float64
+		wantBest  int // Index of the expected best round trip; -1 for none
+		wantWorst int
+	}{
+		{"no trades", nil, -1, -1},
+		{"single trade is both", []float64{5}, 0, 0},
+		{"clear best and worst", []float64{10, -40, 120, -3, 0}, 2, 1},
+		{"all losers", []float64{-5, -1, -9}, 1, 2},
+		{"ties keep the earliest", []float64{7, -2, 7, -2}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roundTrips := roundTripsWithPnL(tt.pnls...)
+			for i := range roundTrips {
+				roundTrips[i].Quantity = float64(i) // Tells apart trips with equal P&L
+			}
+			best, worst := bestAndWorstTrades(roundTrips)
+			for _, c := range []struct {
+				label string
+				got   *RoundTrip
+				want  int
+			}{{"best", best, tt.wantBest}, {"worst", worst, tt.wantWorst}} {
+				if c.want < 0 {
+					if c.got != nil {
+						t.Errorf("%s = %+v, want nil", c.label, *c.got)
+					}
+					continue
+				}
+				if c.got == nil || *c.got != roundTrips[c.want] {
+					t.Errorf("%s = %+v, want round trip %d %+v", c.label, c.got, c.want, roundTrips[c.want])
+				}
+			}
+		})
+	}
+}
+
+func TestBestAndWorstTradesOnResult(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	closes := flatCloses(warmup+40, 100)
+	closes[warmup+5] = 120  // First trade exits with a gain
+	closes[warmup+25] = 80  // Second trade exits with a loss
+	closes[warmup+35] = 101 // Third trade exits with a small gain
+	useScript(t, map[int]string{
+		warmup: "BUY", warmup + 5: "SELL",
+		warmup + 20: "BUY", warmup + 25: "SELL",
+		warmup + 30: "BUY", warmup + 35: "SELL",
+	})
+	be := newTestEngine(BacktestConfig{Interval: "15m"})
+	result, err := be.RunBacktestOnKlines(testKlines(closes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.RoundTrips) != 3 {
+		t.Fatalf("got %d round trips, want 3", len(result.RoundTrips))
+	}
+	if result.BestTrade == nil || *result.BestTrade != result.RoundTrips[0] {
+		t.Errorf("best trade = %+v, want the first round trip %+v", result.BestTrade, result.RoundTrips[0])
+	}
+	if result.WorstTrade == nil || *result.WorstTrade != result.RoundTrips[1] {
+		t.Errorf("worst trade = %+v, want the second round trip %+v", result.WorstTrade, result.RoundTrips[1])
+	}
+	if line := formatRoundTrip(*result.WorstTrade); !strings.Contains(line, "TESTUSDT long -$") {
+		t.Errorf("worst trade summary %q should show a long loss", line)
+	}
+}
+
+func TestConfirmIntervalInBacktest(t *testing.T) {
+	tests := []struct {
+		name            string
+		confirmInterval string
+		step            float64 // Price change per 15m candle
+		wantBuys        int
+	}{
+		{"uptrend confirms the BUY", "1h", 0.5, 1},
+		{"downtrend suppresses the BUY", "1h", -0.1, 0},
+		{"no confirmation in a downtrend", "", -0.1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			klines := testKlines(trendCloses(240, tt.step))
+			data := &fakeMarketData{klines: map[string][]BinanceKline{
+				"15m": klines,
+				"1h":  aggregateKlines(klines, 15, 60),
+			}}
+			useMarketData(t, data)
+			useScript(t, map[int]string{200: "BUY"})
+
+			be := newTestEngine(BacktestConfig{Interval: "15m", DataLimit: len(klines), ConfirmInterval: tt.confirmInterval})
+			result, err := be.RunBacktest(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			buys := 0
+			for _, trade := range result.Trades {
+				if trade.Type == "BUY" {
+					buys++
+				}
+			}
+			if buys != tt.wantBuys {
+				t.Errorf("got %d buys, want %d", buys, tt.wantBuys)
+			}
+			wantFetches := 1
+			if tt.confirmInterval != "" {
+				wantFetches = 2
+			}
+			if len(data.limits) != wantFetches {
+				t.Errorf("fetched klines %d times, want %d", len(data.limits), wantFetches)
+			}
+		})
+	}
+}
+
+func TestAggregateKlinesAlignment(t *testing.T) {
+	// Wednesday 2024-01-10 through Friday 2024-04-05
+	daily := dailyKlines(trendCloses(96, 1))[9:]
+	tests := []struct {
+		name          string
+		klines        []BinanceKline
+		baseMinutes   int
+		targetMinutes int
+		wantOpens     []string // UTC open times of the aggregated candles
+		wantCandles   int      // Base candles in the first aggregated one
+	}{
+		{"1w starts on Monday", daily, 1440, 10080,
+			[]string{"2024-01-15", "2024-01-22", "2024-01-29", "2024-02-05", "2024-02-12", "2024-02-19",
+				"2024-02-26", "2024-03-04", "2024-03-11", "2024-03-18", "2024-03-25"}, 7},
+		{"1M is the calendar month", daily, 1440, 43200, []string{"2024-02-01", "2024-03-01"}, 29},
+		{"1d from 15m counts from midnight UTC", testKlines(flatCloses(4*96+10, 100)), 15, 1440,
+			[]string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04"}, 96},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregated := aggregateKlines(tt.klines, tt.baseMinutes, tt.targetMinutes)
+			var opens []string
+			for _, kline := range aggregated {
+				opens = append(opens, time.UnixMilli(kline.OpenTime).UTC().Format("2006-01-02"))
+			}
+			if !reflect.DeepEqual(opens, tt.wantOpens) {
+				t.Fatalf("candles open on %v, want %v", opens, tt.wantOpens)
+			}
+
+			// The first candle spans exactly its bucket's base candles
+			first := aggregated[0]
+			var firstBase int
+			for firstBase < len(tt.klines) && tt.klines[firstBase].OpenTime != first.OpenTime {
+				firstBase++
+			}
+			last := tt.klines[firstBase+tt.wantCandles-1]
+			if first.Open != tt.klines[firstBase].Open || first.Close != last.Close || first.CloseTime != last.CloseTime {
+				t.Errorf("first candle %+v doesn't span base candles %d through %d", first, firstBase, firstBase+tt.wantCandles-1)
+			}
+		})
+	}
+
+	// Candles that don't divide the target can't be aggregated
+	for _, c := range []struct{ base, target int }{{4320, 10080}, {4320, 43200}, {10080, 43200}} {
+		if got := aggregateKlines(daily, c.base, c.target); got != nil {
+			t.Errorf("aggregateKlines(%d -> %d) = %d candles, want nil", c.base, c.target, len(got))
+		}
+	}
+}
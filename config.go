@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AppConfig holds the settings shared by the live bot and the backtest CLI. LoadConfig fills
+// it from an optional JSON file and then the environment; each CLI applies its flags on top,
+// so flags override env vars, which override the file.
+type AppConfig struct {
+	Binance         BinanceConfig    `json:"binance"`
+	TradingPairs    []string         `json:"trading_pairs"`
+	IntervalMinutes int              `json:"interval_minutes"`
+	Strategy        StrategyConfig   `json:"strategy"`         // Same keys as the "Strategy" block of a saved backtest result
+	MinHoldPeriods  int              `json:"min_hold_periods"` // Candles after a BUY before a SELL is acted on
+	CooldownPeriods int              `json:"cooldown_periods"` // Candles after a SELL before a BUY is acted on
+	Telegram        TelegramConfig   `json:"telegram"`
+	Backtest        BacktestDefaults `json:"backtest"`
+}
+
+// BinanceConfig holds the API credentials
+type BinanceConfig struct {
+	APIKey    string `json:"api_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// TelegramConfig holds the bot credentials and where signals are sent
+type TelegramConfig struct {
+	BotToken       string            `json:"bot_token"`
+	ChatID         string            `json:"chat_id"`
+	SymbolChats    map[string]string `json:"symbol_chats"` // symbol -> chat ID, see TELEGRAM_SYMBOL_CHATS
+	SendAllUpdates bool              `json:"send_all_updates"`
+}
+
+// BacktestDefaults are the backtest CLI settings used when the matching flag isn't given
+type BacktestDefaults struct {
+	Symbol         string  `json:"symbol"`
+	InitialBalance float64 `json:"initial_balance"`
+	Fee            float64 `json:"fee"`
+	Interval       string  `json:"interval"`
+	Limit          int     `json:"limit"`
+}
+
+// DefaultAppConfig returns the settings used when neither the file nor the environment sets them
+func DefaultAppConfig() AppConfig {
+	return AppConfig{
+		IntervalMinutes: 5,
+		Strategy:        DefaultStrategyConfig(),
+		Backtest: BacktestDefaults{
+			Symbol:         "BTCUSDT",
+			InitialBalance: 10000,
+			Fee:            0.001,
+			Interval:       "15m",
+			Limit:          500,
+		},
+	}
+}
+
+// LoadConfig reads the JSON config file at path over the defaults, then applies the
+// environment variables that are set. An empty path uses the defaults and environment only.
+// Unknown keys in the file are rejected so typos don't silently fall back to defaults.
+func LoadConfig(path string) (*AppConfig, error) {
+	cfg := DefaultAppConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %v", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("error decoding config file %s: %v", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.normalizeSymbols()
+	return &cfg, nil
+}
+
+// normalizeSymbols trims and upper-cases the trading pairs and symbol chat routes, whether
+// they came from the file or the environment, since Binance and routing use upper case.
+// Empty pairs, e.g. from a trailing comma, are dropped.
+func (cfg *AppConfig) normalizeSymbols() {
+	pairs := make([]string, 0, len(cfg.TradingPairs))
+	for _, symbol := range cfg.TradingPairs {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			pairs = append(pairs, symbol)
+		}
+	}
+	cfg.TradingPairs = pairs
+
+	if cfg.Telegram.SymbolChats != nil {
+		routes := make(map[string]string, len(cfg.Telegram.SymbolChats))
+		for symbol, chatID := range cfg.Telegram.SymbolChats {
+			routes[strings.ToUpper(strings.TrimSpace(symbol))] = strings.TrimSpace(chatID)
+		}
+		cfg.Telegram.SymbolChats = routes
+	}
+}
+
+// applyEnv overrides the config with the environment variables that are set
+func (cfg *AppConfig) applyEnv() error {
+	strs := []struct {
+		name string
+		dst  *string
+	}{
+		{"BINANCE_API_KEY", &cfg.Binance.APIKey},
+		{"BINANCE_SECRET_KEY", &cfg.Binance.SecretKey},
+		{"TELEGRAM_BOT_TOKEN", &cfg.Telegram.BotToken},
+		{"TELEGRAM_CHAT_ID", &cfg.Telegram.ChatID},
+	}
+	for _, v := range strs {
+		if s := os.Getenv(v.name); s != "" {
+			*v.dst = s
+		}
+	}
+
+	if s := os.Getenv("TRADING_PAIRS"); s != "" {
+		cfg.TradingPairs = strings.Split(s, ",")
+	}
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"INTERVAL_MINUTES", &cfg.IntervalMinutes},
+		{"MIN_HOLD_PERIODS", &cfg.MinHoldPeriods},
+		{"COOLDOWN_PERIODS", &cfg.CooldownPeriods},
+	}
+	for _, v := range ints {
+		if s := os.Getenv(v.name); s != "" {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", v.name, s, err)
+			}
+			*v.dst = n
+		}
+	}
+	if cfg.MinHoldPeriods < 0 || cfg.CooldownPeriods < 0 {
+		return fmt.Errorf("min_hold_periods and cooldown_periods must not be negative")
+	}
+	if s := os.Getenv("TELEGRAM_SYMBOL_CHATS"); s != "" {
+		routes, err := parseSymbolChats(s)
+		if err != nil {
+			return fmt.Errorf("invalid TELEGRAM_SYMBOL_CHATS: %v", err)
+		}
+		cfg.Telegram.SymbolChats = routes
+	}
+	if s := os.Getenv("SEND_ALL_UPDATES"); s != "" {
+		cfg.Telegram.SendAllUpdates = strings.ToLower(s) == "true"
+	}
+
+	return applyStrategyEnv(&cfg.Strategy)
+}
+
+// configPathFromArgs returns the -config file given in args (as -config=path or -config path),
+// falling back to CONFIG_FILE
+func configPathFromArgs(args []string) string {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-config=") {
+			return strings.TrimPrefix(arg, "-config=")
+		}
+		if arg == "-config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv("CONFIG_FILE")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestConfig writes a JSON config file into a temp dir and returns its path
+func writeTestConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeTestConfig(t, `{"min_hold_periods": 1, "backtest": {"symbol": "ETHUSDT"}}`)
+	tests := []struct {
+		name        string
+		env         string
+		args        []string
+		wantMinHold int
+	}{
+		{"file only", "", nil, 1},
+		{"env over file", "2", nil, 2},
+		{"flag over env", "2", []string{"-min-hold=3"}, 3},
+		{"flag over file", "", []string{"-min-hold=3"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MIN_HOLD_PERIODS", tt.env)
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The CLIs default each flag to the loaded value, as RunBacktestCLI does
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			minHold := fs.Int("min-hold", cfg.MinHoldPeriods, "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if *minHold != tt.wantMinHold {
+				t.Errorf("min hold = %d, want %d", *minHold, tt.wantMinHold)
+			}
+			if cfg.Backtest.Symbol != "ETHUSDT" {
+				t.Errorf("backtest symbol = %q, want the file's ETHUSDT", cfg.Backtest.Symbol)
+			}
+		})
+	}
+}
+
+func TestLoadConfigNormalizesSymbols(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		pairsEnv   string
+		chatsEnv   string
+		wantPairs  []string
+		wantRoutes map[string]string
+	}{
+		{
+			name:       "file",
+			file:       `{"trading_pairs": [" btcusdt", "EthUsdt "], "telegram": {"symbol_chats": {"solusdt ": "42"}}}`,
+			wantPairs:  []string{"BTCUSDT", "ETHUSDT"},
+			wantRoutes: map[string]string{"SOLUSDT": "42"},
+		},
+		{
+			name:       "env",
+			file:       `{}`,
+			pairsEnv:   "btcusdt, ethusdt,,",
+			chatsEnv:   " solusdt : 42 ",
+			wantPairs:  []string{"BTCUSDT", "ETHUSDT"},
+			wantRoutes: map[string]string{"SOLUSDT": "42"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRADING_PAIRS", tt.pairsEnv)
+			t.Setenv("TELEGRAM_SYMBOL_CHATS", tt.chatsEnv)
+			cfg, err := LoadConfig(writeTestConfig(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.TradingPairs, tt.wantPairs) {
+				t.Errorf("pairs = %q, want %q", cfg.TradingPairs, tt.wantPairs)
+			}
+			if !reflect.DeepEqual(cfg.Telegram.SymbolChats, tt.wantRoutes) {
+				t.Errorf("routes = %v, want %v", cfg.Telegram.SymbolChats, tt.wantRoutes)
+			}
+		})
+	}
+}
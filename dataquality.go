@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// maxMissingCandlePct is the share of missing candles above which gaps count as a serious issue
+const maxMissingCandlePct = 1.0
+
+// DataGap is a stretch of missing candles between two consecutive klines
+type DataGap struct {
+	After   time.Time // Open time of the last candle before the gap
+	Missing int       // Number of candles missing
+}
+
+// DataQualityReport summarizes the health of a kline series before it is backtested
+type DataQualityReport struct {
+	Candles        int
+	Start          time.Time
+	End            time.Time
+	Gaps           []DataGap
+	MissingCandles int
+	ZeroVolume     int // Candles with no traded volume
+	BadPrices      int // Candles with a zero, negative or unparseable OHLC price
+}
+
+// checkDataQuality inspects klines spaced intervalMinutes apart for gaps, zero-volume
+// candles and invalid prices
+func checkDataQuality(klines []BinanceKline, intervalMinutes int) DataQualityReport {
+	report := DataQualityReport{Candles: len(klines)}
+	if len(klines) == 0 {
+		return report
+	}
+
+	report.Start = time.UnixMilli(klines[0].OpenTime)
+	report.End = time.UnixMilli(klines[len(klines)-1].OpenTime)
+	step := int64(intervalMinutes) * 60 * 1000
+
+	for i, k := range klines {
+		for _, field := range []string{k.Open, k.High, k.Low, k.Close} {
+			if price, err := strconv.ParseFloat(field, 64); err != nil || price <= 0 {
+				report.BadPrices++
+				break
+			}
+		}
+
+		if volume, err := strconv.ParseFloat(k.Volume, 64); err == nil && volume == 0 {
+			report.ZeroVolume++
+		}
+
+		if i > 0 && step > 0 {
+			if missing := int((k.OpenTime-klines[i-1].OpenTime)/step) - 1; missing > 0 {
+				report.Gaps = append(report.Gaps, DataGap{
+					After:   time.UnixMilli(klines[i-1].OpenTime),
+					Missing: missing,
+				})
+				report.MissingCandles += missing
+			}
+		}
+	}
+
+	return report
+}
+
+// MissingPct returns the missing candles as a percentage of the expected candle count
+func (r DataQualityReport) MissingPct() float64 {
+	expected := r.Candles + r.MissingCandles
+	if expected == 0 {
+		return 0
+	}
+	return float64(r.MissingCandles) / float64(expected) * 100
+}
+
+// SeriousIssues lists the problems that make a backtest over this data unreliable
+func (r DataQualityReport) SeriousIssues() []string {
+	var issues []string
+	if r.BadPrices > 0 {
+		issues = append(issues, fmt.Sprintf("%d candles with zero, negative or invalid prices", r.BadPrices))
+	}
+	if r.MissingPct() > maxMissingCandlePct {
+		issues = append(issues, fmt.Sprintf("%.1f%% of candles missing across %d gaps", r.MissingPct(), len(r.Gaps)))
+	}
+	return issues
+}
+
+// printDataQualityReport prints a short data health summary
+func printDataQualityReport(r DataQualityReport) {
+	fmt.Printf("\n🩺 DATA QUALITY\n")
+	fmt.Printf("   Candles:              %d\n", r.Candles)
+	if r.Candles > 0 {
+		fmt.Printf("   Date Range:           %s → %s\n",
+			r.Start.UTC().Format("2006-01-02 15:04"), r.End.UTC().Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("   Gaps:                 %d (%d missing candles)\n", len(r.Gaps), r.MissingCandles)
+	for i, gap := range r.Gaps {
+		if i == 5 {
+			fmt.Printf("      ... and %d more\n", len(r.Gaps)-i)
+			break
+		}
+		fmt.Printf("      after %s: %d missing\n", gap.After.UTC().Format("2006-01-02 15:04"), gap.Missing)
+	}
+	fmt.Printf("   Zero-Volume Candles:  %d\n", r.ZeroVolume)
+	fmt.Printf("   Invalid Prices:       %d\n", r.BadPrices)
+	for _, issue := range r.SeriousIssues() {
+		fmt.Printf("   ⚠️  %s\n", issue)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// problemKlines returns 100 15m klines with gaps, zero-volume candles and bad prices injected
+func problemKlines() []BinanceKline {
+	klines := testKlines(flatCloses(100, 100))
+	klines[20].Volume = "0"
+	klines[21].Volume = "0.0"
+	klines[30].Close = "0"
+	klines[31].Low = "-1"
+	klines[32].Open = "abc"
+	klines[33].High, klines[33].Low = "0", "0" // Counted once per candle
+	// Drop candles 60-62 and 80 to leave two gaps
+	return append(append(klines[:60:60], klines[63:80]...), klines[81:]...)
+}
+
+func TestCheckDataQuality(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(candle int) time.Time { return start.Add(time.Duration(candle) * 15 * time.Minute).Local() }
+
+	tests := []struct {
+		name       string
+		klines     []BinanceKline
+		wantReport DataQualityReport
+		wantIssues []string // Substrings of each expected serious issue
+	}{
+		{
+			name:       "clean",
+			klines:     testKlines(flatCloses(10, 100)),
+			wantReport: DataQualityReport{Candles: 10, Start: at(0), End: at(9)},
+		},
+		{
+			name:       "empty",
+			wantReport: DataQualityReport{},
+		},
+		{
+			name:   "injected problems",
+			klines: problemKlines(),
+			wantReport: DataQualityReport{
+				Candles:        96,
+				Start:          at(0),
+				End:            at(99),
+				Gaps:           []DataGap{{After: at(59), Missing: 3}, {After: at(79), Missing: 1}},
+				MissingCandles: 4,
+				ZeroVolume:     2,
+				BadPrices:      4,
+			},
+			wantIssues: []string{"4 candles with zero, negative or invalid prices", "4.0% of candles missing across 2 gaps"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := checkDataQuality(tt.klines, 15)
+			if !reflect.DeepEqual(report, tt.wantReport) {
+				t.Errorf("got %+v, want %+v", report, tt.wantReport)
+			}
+			issues := report.SeriousIssues()
+			if len(issues) != len(tt.wantIssues) {
+				t.Fatalf("got issues %q, want %q", issues, tt.wantIssues)
+			}
+			for i, want := range tt.wantIssues {
+				if !strings.Contains(issues[i], want) {
+					t.Errorf("issue %d = %q, want it to mention %q", i, issues[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestStrictDataAbortsBacktest(t *testing.T) {
+	tests := []struct {
+		name    string
+		klines  []BinanceKline
+		strict  bool
+		wantErr bool
+	}{
+		{"clean data, strict", testKlines(flatCloses(100, 100)), true, false},
+		{"bad data, lenient", problemKlines(), false, false},
+		{"bad data, strict", problemKlines(), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMarketData(t, &fakeMarketData{klines: map[string][]BinanceKline{"15m": tt.klines}})
+			be := newTestEngine(BacktestConfig{Interval: "15m", DataLimit: len(tt.klines), StrictData: tt.strict})
+			var err error
+			captureStdout(t, func() { _, err = be.RunBacktest(context.Background()) })
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "data quality check failed") {
+					t.Errorf("got %v, want a data quality error", err)
+				}
+			} else if err != nil && strings.Contains(err.Error(), "data quality") {
+				t.Errorf("unexpected data quality error: %v", err)
+			}
+		})
+	}
+}
@@ -2,17 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -46,67 +54,427 @@ type BinanceTicker struct {
 	WeightedAvg   string `json:"weightedAvgPrice"`
 }
 
+// OrderResponse is Binance's acknowledgement of a new order
+type OrderResponse struct {
+	Symbol              string `json:"symbol"`
+	OrderID             int64  `json:"orderId"`
+	ClientOrderID       string `json:"clientOrderId"`
+	TransactTime        int64  `json:"transactTime"`
+	Price               string `json:"price"`
+	OrigQty             string `json:"origQty"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	Status              string `json:"status"`
+	TimeInForce         string `json:"timeInForce"`
+	Type                string `json:"type"`
+	Side                string `json:"side"`
+}
+
+// BinanceAPIError is an error response returned by the Binance API
+type BinanceAPIError struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+}
+
+func (e *BinanceAPIError) Error() string {
+	return fmt.Sprintf("binance API error %d (HTTP %d): %s", e.Code, e.StatusCode, e.Msg)
+}
+
+// BinanceAPI is the market data the bot and backtests read from Binance. *BinanceClient
+// implements it against the REST API; replayBinance serves recorded responses instead.
+type BinanceAPI interface {
+	fetchKlines(ctx context.Context, symbol string, interval string, limit int) ([]BinanceKline, error)
+	fetch24hrTickers(ctx context.Context, symbols []string) (map[string]BinanceTicker, error)
+}
+
 type BinanceClient struct {
-	apiKey    string
-	secretKey string
-	baseURL   string
+	apiKey     string
+	secretKey  string
+	baseURL    string
+	streamURL  string // WebSocket market stream base, used by StreamKlines
+	httpClient *http.Client
+	limitMode  string        // What fetchKlines does above binanceMaxKlines: "paged" (default), "warn" or "error"
+	MaxRetries int           // Retries after a 5xx, 429 or network error; 0 disables retrying
+	retryDelay time.Duration // Backoff before the first retry, doubled on each further attempt
+
+	tickerTTL   time.Duration // How long fetch24hrTickers reuses a ticker; 0 disables the cache
+	tickerMu    sync.Mutex
+	tickerCache map[string]cachedTicker // symbol -> last 24hr ticker, created on first fetch
+}
+
+// cachedTicker is a 24hr ticker and when it was fetched
+type cachedTicker struct {
+	ticker    BinanceTicker
+	fetchedAt time.Time
 }
 
+// defaultTickerCacheTTL is how long 24hr tickers are reused between loop iterations and commands
+const defaultTickerCacheTTL = 10 * time.Second
+
+// defaultBinanceTimeout bounds every Binance HTTP call so a hung connection can't freeze the bot
+const defaultBinanceTimeout = 15 * time.Second
+
+// maxRetryDelay caps the exponential backoff between retries
+const maxRetryDelay = 30 * time.Second
+
 type TelegramBot struct {
 	botToken string
 	chatID   string
+	apiURL   string // Telegram Bot API base URL
+
+	// symbolChats routes a symbol's signals to its own chat; other symbols use chatID
+	symbolChats map[string]string
+
+	queue        chan telegramMessage // Messages waiting for the Enqueue worker
+	sendInterval time.Duration        // Minimum gap between queued messages
+	startWorker  sync.Once
+}
+
+// telegramQueueSize bounds how many messages Enqueue buffers before dropping new ones
+const telegramQueueSize = 100
+
+// telegramMaxRetries is how many times the queue worker retries a rate-limited message
+const telegramMaxRetries = 5
+
+// telegramMessage is a queued message and the chat it goes to
+type telegramMessage struct {
+	chatID string
+	text   string
+}
+
+// TelegramRateLimitError is a 429 from the Bot API; RetryAfter is how long it asked to wait
+type TelegramRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TelegramRateLimitError) Error() string {
+	return fmt.Sprintf("telegram rate limit: retry after %v", e.RetryAfter)
 }
 
 var (
 	seriesMap = make(map[string]*techan.TimeSeries)
 	binanceClient *BinanceClient
+	marketData BinanceAPI // Where klines and tickers are read from; binanceClient unless replaying
 	telegramBot *TelegramBot
 	sendAllUpdates bool
+	dropFormingCandles bool // FORMING_CANDLE=drop: never evaluate the still-open tail candle
 )
 
 func NewBinanceClient(apiKey, secretKey string) *BinanceClient {
 	return &BinanceClient{
-		apiKey:    apiKey,
-		secretKey: secretKey,
-		baseURL:   "https://api.binance.com",
+		apiKey:     apiKey,
+		secretKey:  secretKey,
+		baseURL:    "https://api.binance.com",
+		streamURL:  "wss://stream.binance.com",
+		httpClient: &http.Client{Timeout: defaultBinanceTimeout},
+		limitMode:  "paged",
+		MaxRetries: 3,
+		retryDelay: 500 * time.Millisecond,
+		tickerTTL:  defaultTickerCacheTTL,
+	}
+}
+
+// SetLimitMode selects how fetchKlines handles limits above the per-request cap: "paged"
+// fetches every page, "warn" fetches a single capped page and logs a warning, "error" fails
+func (bc *BinanceClient) SetLimitMode(mode string) error {
+	switch mode {
+	case "paged", "warn", "error":
+		bc.limitMode = mode
+		return nil
+	default:
+		return fmt.Errorf("unsupported limit mode: %s (use paged, warn or error)", mode)
+	}
+}
+
+// SetTickerCacheTTL sets how long fetch24hrTickers reuses a symbol's ticker; 0 disables the
+// cache so every call hits the API
+func (bc *BinanceClient) SetTickerCacheTTL(ttl time.Duration) {
+	bc.tickerMu.Lock()
+	defer bc.tickerMu.Unlock()
+	bc.tickerTTL = ttl
+	bc.tickerCache = nil
+}
+
+// SetTimeout changes the per-request timeout of the client
+func (bc *BinanceClient) SetTimeout(timeout time.Duration) {
+	bc.httpClient.Timeout = timeout
+}
+
+// applyBinanceEnv sets the client timeout from BINANCE_TIMEOUT_SECONDS, the retry count from
+// BINANCE_MAX_RETRIES and the ticker cache TTL from BINANCE_TICKER_CACHE_SECONDS, if present
+func applyBinanceEnv(bc *BinanceClient) {
+	if seconds, err := strconv.Atoi(os.Getenv("BINANCE_TIMEOUT_SECONDS")); err == nil && seconds > 0 {
+		bc.SetTimeout(time.Duration(seconds) * time.Second)
+	}
+	if retries, err := strconv.Atoi(os.Getenv("BINANCE_MAX_RETRIES")); err == nil && retries >= 0 {
+		bc.MaxRetries = retries
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("BINANCE_TICKER_CACHE_SECONDS")); err == nil && seconds >= 0 {
+		bc.SetTickerCacheTTL(time.Duration(seconds) * time.Second)
 	}
 }
 
+// get issues a GET request bound to ctx, retrying transient failures, and records its
+// latency and outcome in botMetrics under the endpoint path (e.g. "klines")
+func (bc *BinanceClient) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := bc.doWithRetry(req)
+	failed := err != nil || resp.StatusCode >= 400
+	botMetrics.RecordFetch(strings.TrimPrefix(req.URL.Path, "/api/v3/"), time.Since(start), failed)
+	return resp, err
+}
+
+// doWithRetry sends req, retrying up to MaxRetries times on network errors, 5xx and 429
+// responses. Waits grow exponentially from retryDelay with jitter; a 429's Retry-After
+// header takes precedence. Only use it for idempotent requests without a body.
+func (bc *BinanceClient) doWithRetry(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	delay := bc.retryDelay
+
+	for attempt := 0; ; attempt++ {
+		resp, err := bc.httpClient.Do(req.Clone(ctx))
+		if ctx.Err() != nil {
+			if err == nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		retryable := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		if !retryable || attempt >= bc.MaxRetries {
+			return resp, err
+		}
+
+		wait := delay
+		if delay > 0 {
+			wait += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		}
+		if err != nil {
+			log.Printf("Error de red en %s: %v (reintento %d/%d en %v)", req.URL.Path, err, attempt+1, bc.MaxRetries, wait)
+		} else {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+					wait = time.Duration(seconds) * time.Second
+				}
+			}
+			log.Printf("Binance respondió %d en %s (reintento %d/%d en %v)", resp.StatusCode, req.URL.Path, attempt+1, bc.MaxRetries, wait)
+			resp.Body.Close()
+		}
+
+		if !sleepContext(ctx, wait) {
+			return nil, ctx.Err()
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// checkBinanceResponse returns a *BinanceAPIError for non-2xx responses
+func checkBinanceResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	apiErr := &BinanceAPIError{StatusCode: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Msg == "" {
+		apiErr.Msg = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
 func (bc *BinanceClient) signRequest(params string) string {
 	h := hmac.New(sha256.New, []byte(bc.secretKey))
 	h.Write([]byte(params))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-func (bc *BinanceClient) fetchKlines(symbol string, interval string, limit int) ([]BinanceKline, error) {
+// binanceRecvWindow is how long (ms) after its timestamp Binance accepts a signed request
+const binanceRecvWindow = 5000
+
+// liveTradingEnabled reports whether real orders are allowed (LIVE_TRADING=true)
+func liveTradingEnabled() bool {
+	return strings.ToLower(os.Getenv("LIVE_TRADING")) == "true"
+}
+
+// PlaceOrder sends a signed order to POST /api/v3/order. side is BUY or SELL and orderType
+// MARKET or LIMIT; price is only sent (good-till-cancelled) for LIMIT orders. It refuses to
+// run unless LIVE_TRADING=true, so signal-only and backtest modes never place real orders.
+func (bc *BinanceClient) PlaceOrder(ctx context.Context, symbol, side, orderType string, quantity, price float64) (*OrderResponse, error) {
+	if !liveTradingEnabled() {
+		return nil, fmt.Errorf("live trading is disabled: set LIVE_TRADING=true to place real orders")
+	}
+	if side != "BUY" && side != "SELL" {
+		return nil, fmt.Errorf("unsupported order side: %s (use BUY or SELL)", side)
+	}
+	if orderType != "MARKET" && orderType != "LIMIT" {
+		return nil, fmt.Errorf("unsupported order type: %s (use MARKET or LIMIT)", orderType)
+	}
+	if quantity <= 0 || (orderType == "LIMIT" && price <= 0) {
+		return nil, fmt.Errorf("invalid order size: quantity %v, price %v", quantity, price)
+	}
+
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("side", side)
+	params.Set("type", orderType)
+	params.Set("quantity", strconv.FormatFloat(quantity, 'f', -1, 64))
+	if orderType == "LIMIT" {
+		params.Set("price", strconv.FormatFloat(price, 'f', -1, 64))
+		params.Set("timeInForce", "GTC")
+	}
+	params.Set("recvWindow", strconv.Itoa(binanceRecvWindow))
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+
+	query := params.Encode()
+	body := query + "&signature=" + bc.signRequest(query)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bc.baseURL+"/api/v3/order", strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating order request: %v", err)
+	}
+	req.Header.Set("X-MBX-APIKEY", bc.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := bc.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error placing order: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkBinanceResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var order OrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return nil, fmt.Errorf("error decoding order response: %v", err)
+	}
+	return &order, nil
+}
+
+// binanceMaxKlines is the maximum number of klines Binance returns per request
+const binanceMaxKlines = 1000
+
+// klinesPageDelay is the pause between paged kline requests to respect rate limits
+const klinesPageDelay = 100 * time.Millisecond
+
+// fetchKlines returns the most recent limit klines. When limit exceeds the per-request cap
+// it pages through history, or caps/fails according to the client's limit mode.
+func (bc *BinanceClient) fetchKlines(ctx context.Context, symbol string, interval string, limit int) ([]BinanceKline, error) {
+	if limit > binanceMaxKlines {
+		switch bc.limitMode {
+		case "error":
+			return nil, fmt.Errorf("requested %d klines but Binance returns at most %d per request and paging is disabled",
+				limit, binanceMaxKlines)
+		case "warn":
+			log.Printf("Warning: requested %d klines for %s but paging is disabled; only the latest %d will be fetched",
+				limit, symbol, binanceMaxKlines)
+			return bc.fetchKlinesPage(ctx, symbol, interval, binanceMaxKlines, 0)
+		default:
+			return bc.fetchKlinesPaged(ctx, symbol, interval, limit)
+		}
+	}
+	return bc.fetchKlinesPage(ctx, symbol, interval, limit, 0)
+}
+
+// fetchKlinesPaged walks backward from now in pages of up to binanceMaxKlines until limit
+// klines are collected, returning them in chronological order. It returns an error if
+// fewer klines are available than requested.
+func (bc *BinanceClient) fetchKlinesPaged(ctx context.Context, symbol string, interval string, limit int) ([]BinanceKline, error) {
+	collected := make([]BinanceKline, 0, limit)
+	seen := make(map[int64]bool)
+	var endTime int64 // 0 means "up to now"
+
+	for len(collected) < limit {
+		pageSize := limit - len(collected)
+		if endTime != 0 {
+			pageSize++ // Room for a candle repeated from the previous page's boundary
+		}
+		if pageSize > binanceMaxKlines {
+			pageSize = binanceMaxKlines
+		}
+
+		page, err := bc.fetchKlinesPage(ctx, symbol, interval, pageSize, endTime)
+		if err != nil {
+			return nil, err
+		}
+
+		// Drop candles already collected from an overlapping page boundary
+		older := make([]BinanceKline, 0, len(page))
+		for _, kline := range page {
+			if !seen[kline.OpenTime] {
+				seen[kline.OpenTime] = true
+				older = append(older, kline)
+			}
+		}
+		if len(older) == 0 {
+			break // No more history available
+		}
+
+		collected = append(older, collected...)
+		endTime = page[0].OpenTime - 1
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(klinesPageDelay):
+		}
+	}
+
+	if len(collected) < limit {
+		return nil, fmt.Errorf("only %d of %d requested klines available for %s %s",
+			len(collected), limit, symbol, interval)
+	}
+
+	return collected[len(collected)-limit:], nil
+}
+
+// fetchKlinesPage fetches a single page of klines ending at endTime (ms), or the most
+// recent klines when endTime is 0
+func (bc *BinanceClient) fetchKlinesPage(ctx context.Context, symbol string, interval string, limit int, endTime int64) ([]BinanceKline, error) {
 	url := fmt.Sprintf("%s/api/v3/klines?symbol=%s&interval=%s&limit=%d", 
 		bc.baseURL, symbol, interval, limit)
+	if endTime > 0 {
+		url += fmt.Sprintf("&endTime=%d", endTime)
+	}
 	
-	resp, err := http.Get(url)
+	resp, err := bc.get(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching klines: %v", err)
+		return nil, fmt.Errorf("error fetching klines: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := checkBinanceResponse(resp); err != nil {
+		return nil, fmt.Errorf("error fetching klines: %w", err)
+	}
+
+	return decodeKlines(resp.Body, symbol)
+}
+
+// decodeKlines parses a /api/v3/klines response body
+func decodeKlines(r io.Reader, symbol string) ([]BinanceKline, error) {
 	var rawKlines [][]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&rawKlines); err != nil {
+	if err := json.NewDecoder(r).Decode(&rawKlines); err != nil {
 		return nil, fmt.Errorf("error decoding klines: %v", err)
 	}
 
 	var klines []BinanceKline
-	for _, raw := range rawKlines {
+	for i, raw := range rawKlines {
 		if len(raw) < 12 {
 			continue
 		}
 		
-		kline := BinanceKline{
-			OpenTime:  int64(raw[0].(float64)),
-			Open:      raw[1].(string),
-			High:      raw[2].(string),
-			Low:       raw[3].(string),
-			Close:     raw[4].(string),
-			Volume:    raw[5].(string),
-			CloseTime: int64(raw[6].(float64)),
+		kline, err := parseKlineRow(raw)
+		if err != nil {
+			log.Printf("Kline malformada para %s (fila %d): %v", symbol, i, err)
+			return nil, fmt.Errorf("error parsing kline row %d for %s: %v", i, symbol, err)
 		}
 		klines = append(klines, kline)
 	}
@@ -114,64 +482,266 @@ func (bc *BinanceClient) fetchKlines(symbol string, interval string, limit int)
 	return klines, nil
 }
 
-func fetchHistoricalData(symbol string) {
-	klines, err := binanceClient.fetchKlines(symbol, "15m", 100)
+// parseKlineRow converts a raw kline array into a BinanceKline, reporting which field
+// has an unexpected type instead of panicking
+func parseKlineRow(raw []interface{}) (BinanceKline, error) {
+	var kline BinanceKline
+
+	openTime, ok := raw[0].(float64)
+	if !ok {
+		return kline, fmt.Errorf("field openTime: expected number, got %T", raw[0])
+	}
+	closeTime, ok := raw[6].(float64)
+	if !ok {
+		return kline, fmt.Errorf("field closeTime: expected number, got %T", raw[6])
+	}
+	kline.OpenTime = int64(openTime)
+	kline.CloseTime = int64(closeTime)
+
+	stringFields := []struct {
+		name  string
+		index int
+		dest  *string
+	}{
+		{"open", 1, &kline.Open},
+		{"high", 2, &kline.High},
+		{"low", 3, &kline.Low},
+		{"close", 4, &kline.Close},
+		{"volume", 5, &kline.Volume},
+	}
+	for _, field := range stringFields {
+		value, ok := raw[field.index].(string)
+		if !ok {
+			return kline, fmt.Errorf("field %s: expected string, got %T", field.name, raw[field.index])
+		}
+		*field.dest = value
+	}
+
+	return kline, nil
+}
+
+// fetchHistoricalData loads the latest interval klines for symbol into its live series, each
+// candle spanning period
+func fetchHistoricalData(ctx context.Context, symbol string, interval string, period time.Duration) {
+	klines, err := marketData.fetchKlines(ctx, symbol, interval, 100)
 	if err != nil {
 		log.Printf("Error obteniendo klines para %s: %v", symbol, err)
 		return
 	}
 
+	// The newest kline is usually still open; its OHLCV will keep changing until it closes
+	if dropFormingCandles {
+		now := time.Now().UnixMilli()
+		for len(klines) > 0 && klines[len(klines)-1].CloseTime >= now {
+			klines = klines[:len(klines)-1]
+		}
+	}
+
 	ts := techan.NewTimeSeries()
 	for _, kline := range klines {
-		open, _ := strconv.ParseFloat(kline.Open, 64)
-		high, _ := strconv.ParseFloat(kline.High, 64)
-		low, _ := strconv.ParseFloat(kline.Low, 64)
-		close, _ := strconv.ParseFloat(kline.Close, 64)
-		
-		period := techan.NewTimePeriod(time.UnixMilli(kline.OpenTime), time.Minute*15)
-		c := techan.NewCandle(period)
-		c.OpenPrice = big.NewDecimal(open)
-		c.MaxPrice = big.NewDecimal(high)
-		c.MinPrice = big.NewDecimal(low)
-		c.ClosePrice = big.NewDecimal(close)
-		c.Volume = big.NewDecimal(0)
-		ts.AddCandle(c)
+		ts.AddCandle(klineToCandle(kline, period))
 	}
 
+	trimSeries(ts, maxSeriesCandles)
 	seriesMap[symbol] = ts
 	log.Printf("Datos históricos cargados para %s (%d velas)", symbol, len(klines))
 }
 
-func (bc *BinanceClient) fetch24hrTickers(symbols []string) (map[string]BinanceTicker, error) {
-	url := fmt.Sprintf("%s/api/v3/ticker/24hr", bc.baseURL)
+// closedCandles returns a view of ts without its last, still-forming candle
+func closedCandles(ts *techan.TimeSeries) *techan.TimeSeries {
+	if len(ts.Candles) == 0 {
+		return ts
+	}
+	return &techan.TimeSeries{Candles: ts.Candles[:len(ts.Candles)-1]}
+}
+
+// liveInterval is the kline interval of the live series: its history, forming, verified and
+// streamed candles all span one liveInterval
+const liveInterval = "15m"
+
+// defaultMaxSeriesCandles is the live rolling window when SERIES_MAX_CANDLES is unset
+const defaultMaxSeriesCandles = 500
+
+// maxSeriesCandles caps each live series so memory and indicator cost don't grow for the
+// lifetime of the bot
+var maxSeriesCandles = defaultMaxSeriesCandles
+
+// updateFormingCandle folds a polled price into the candle of the period containing now.
+// While that period lasts the candle is updated in place (close, plus high/low tracking the
+// extremes seen); once the boundary passes a new candle is opened at the price. Only one
+// candle per period is ever kept.
+func updateFormingCandle(ts *techan.TimeSeries, price float64, now time.Time, period time.Duration) {
+	start := now.Truncate(period)
+	value := big.NewDecimal(price)
+
+	if last := ts.LastCandle(); last != nil && !last.Period.Start.Before(start) {
+		last.ClosePrice = value
+		if value.GT(last.MaxPrice) {
+			last.MaxPrice = value
+		}
+		if value.LT(last.MinPrice) {
+			last.MinPrice = value
+		}
+		return
+	}
+
+	c := techan.NewCandle(techan.NewTimePeriod(start, period))
+	c.OpenPrice = value
+	c.MaxPrice = value
+	c.MinPrice = value
+	c.ClosePrice = value
+	c.Volume = big.ZERO
+	ts.AddCandle(c)
+	trimSeries(ts, maxSeriesCandles)
+}
+
+// trimSeries drops the oldest candles once ts holds more than maxLen; maxLen <= 0 keeps
+// everything. Signals are always computed from the series' current last index, so nothing
+// downstream holds indices that the trim would shift.
+func trimSeries(ts *techan.TimeSeries, maxLen int) {
+	if maxLen <= 0 {
+		return
+	}
+	if extra := len(ts.Candles) - maxLen; extra > 0 {
+		ts.Candles = append([]*techan.Candle(nil), ts.Candles[extra:]...)
+	}
+}
+
+// klineToCandle converts a Binance kline into a techan candle of the given period
+func klineToCandle(kline BinanceKline, period time.Duration) *techan.Candle {
+	open, _ := strconv.ParseFloat(kline.Open, 64)
+	high, _ := strconv.ParseFloat(kline.High, 64)
+	low, _ := strconv.ParseFloat(kline.Low, 64)
+	close, _ := strconv.ParseFloat(kline.Close, 64)
+	volume, _ := strconv.ParseFloat(kline.Volume, 64)
+
+	c := techan.NewCandle(techan.NewTimePeriod(time.UnixMilli(kline.OpenTime), period))
+	c.OpenPrice = big.NewDecimal(open)
+	c.MaxPrice = big.NewDecimal(high)
+	c.MinPrice = big.NewDecimal(low)
+	c.ClosePrice = big.NewDecimal(close)
+	c.Volume = big.NewDecimal(volume)
+	return c
+}
+
+// lastVerifiedCandle holds, per symbol, the open time (ms) of the last closed candle
+// replaced with the exchange's record
+var lastVerifiedCandle = make(map[string]int64)
+
+// verifyClosedCandle fetches the most recently closed interval candle for symbol and, the first
+// time it is seen, replaces the synthetic candles covering its period with the exchange's OHLCV
+func verifyClosedCandle(ctx context.Context, symbol string, ts *techan.TimeSeries, interval string, period time.Duration, now time.Time) {
+	closedOpen := now.Truncate(period).Add(-period).UnixMilli()
+	if lastVerifiedCandle[symbol] >= closedOpen {
+		return
+	}
+
+	klines, err := marketData.fetchKlines(ctx, symbol, interval, 2)
+	if err != nil {
+		log.Printf("Error verificando vela cerrada para %s: %v", symbol, err)
+		return
+	}
+
+	for _, kline := range klines {
+		if kline.OpenTime == closedOpen {
+			replaceCandlePeriod(ts, klineToCandle(kline, period))
+			lastVerifiedCandle[symbol] = closedOpen
+			log.Printf("[%s] Vela cerrada verificada (%s)", symbol, time.UnixMilli(closedOpen).Format("15:04"))
+			return
+		}
+	}
+}
+
+// replaceCandlePeriod swaps every candle starting within closed's period for closed itself,
+// keeping the series in chronological order
+func replaceCandlePeriod(ts *techan.TimeSeries, closed *techan.Candle) {
+	start, end := closed.Period.Start, closed.Period.End
+
+	candles := make([]*techan.Candle, 0, len(ts.Candles)+1)
+	inserted := false
+	for _, c := range ts.Candles {
+		if c.Period.Start.Before(start) {
+			candles = append(candles, c)
+			continue
+		}
+		if !inserted {
+			candles = append(candles, closed)
+			inserted = true
+		}
+		if !c.Period.Start.Before(end) {
+			candles = append(candles, c)
+		}
+	}
+	if !inserted {
+		candles = append(candles, closed)
+	}
+	ts.Candles = candles
+}
+
+// fetch24hrTickers returns the 24hr tickers of symbols, requesting only those not cached
+// within tickerTTL through the symbols=[...] form of the endpoint
+func (bc *BinanceClient) fetch24hrTickers(ctx context.Context, symbols []string) (map[string]BinanceTicker, error) {
+	tickers := make(map[string]BinanceTicker)
+	var missing []string
+
+	now := time.Now()
+	bc.tickerMu.Lock()
+	for _, symbol := range symbols {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		if cached, ok := bc.tickerCache[symbol]; ok && bc.tickerTTL > 0 && now.Sub(cached.fetchedAt) < bc.tickerTTL {
+			tickers[symbol] = cached.ticker
+		} else {
+			missing = append(missing, symbol)
+		}
+	}
+	bc.tickerMu.Unlock()
+
+	if len(missing) == 0 {
+		return tickers, nil
+	}
+
+	symbolsJSON, err := json.Marshal(missing)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding symbols: %v", err)
+	}
+	apiURL := fmt.Sprintf("%s/api/v3/ticker/24hr?symbols=%s", bc.baseURL, url.QueryEscape(string(symbolsJSON)))
 	
-	resp, err := http.Get(url)
+	resp, err := bc.get(ctx, apiURL)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching tickers: %v", err)
+		return nil, fmt.Errorf("error fetching tickers: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var allTickers []BinanceTicker
-	if err := json.NewDecoder(resp.Body).Decode(&allTickers); err != nil {
+	if err := checkBinanceResponse(resp); err != nil {
+		return nil, fmt.Errorf("error fetching tickers: %w", err)
+	}
+
+	var fetched []BinanceTicker
+	if err := json.NewDecoder(resp.Body).Decode(&fetched); err != nil {
 		return nil, fmt.Errorf("error decoding tickers: %v", err)
 	}
 
-	// Filter only requested symbols
-	tickers := make(map[string]BinanceTicker)
-	for _, ticker := range allTickers {
-		for _, symbol := range symbols {
-			if ticker.Symbol == symbol {
-				tickers[symbol] = ticker
-				break
-			}
+	fetchedAt := time.Now()
+	bc.tickerMu.Lock()
+	if bc.tickerCache == nil {
+		bc.tickerCache = make(map[string]cachedTicker)
+	}
+	for _, ticker := range fetched {
+		tickers[ticker.Symbol] = ticker
+		if bc.tickerTTL > 0 {
+			bc.tickerCache[ticker.Symbol] = cachedTicker{ticker: ticker, fetchedAt: fetchedAt}
 		}
 	}
+	bc.tickerMu.Unlock()
 	
 	return tickers, nil
 }
 
-func fetchCurrentPrices(symbols []string) map[string]BinanceTicker {
-	tickers, err := binanceClient.fetch24hrTickers(symbols)
+func fetchCurrentPrices(ctx context.Context, symbols []string) map[string]BinanceTicker {
+	tickers, err := marketData.fetch24hrTickers(ctx, symbols)
 	if err != nil {
 		log.Printf("Error obteniendo precios: %v", err)
 		return nil
@@ -190,18 +760,109 @@ func NewTelegramBot(botToken, chatID string) *TelegramBot {
 	return &TelegramBot{
 		botToken: botToken,
 		chatID:   chatID,
+		apiURL:   "https://api.telegram.org",
+		queue:    make(chan telegramMessage, telegramQueueSize),
+		// Telegram allows about one message per second to the same chat
+		sendInterval: time.Second,
+	}
+}
+
+// parseSymbolChats parses a "BTCUSDT:123,ETHUSDT:456" symbol → chat ID list
+func parseSymbolChats(value string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid symbol chat %q (expected SYMBOL:CHAT_ID)", entry)
+		}
+		symbol := strings.ToUpper(strings.TrimSpace(parts[0]))
+		chatID := strings.TrimSpace(parts[1])
+		if symbol == "" || chatID == "" {
+			return nil, fmt.Errorf("invalid symbol chat %q (expected SYMBOL:CHAT_ID)", entry)
+		}
+		if _, err := strconv.ParseInt(chatID, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid chat ID for %s: %q", symbol, chatID)
+		}
+		routes[symbol] = chatID
+	}
+	return routes, nil
+}
+
+// SetSymbolChats routes each listed symbol's signals to its chat ID
+func (tb *TelegramBot) SetSymbolChats(routes map[string]string) {
+	tb.symbolChats = routes
+}
+
+// ChatFor returns the chat a symbol's signals go to, falling back to the default chat
+func (tb *TelegramBot) ChatFor(symbol string) string {
+	if chatID, ok := tb.symbolChats[strings.ToUpper(strings.TrimSpace(symbol))]; ok {
+		return chatID
+	}
+	return tb.chatID
+}
+
+// EnqueueFor queues message for the chat symbol is routed to
+func (tb *TelegramBot) EnqueueFor(symbol, message string) {
+	tb.enqueue(tb.ChatFor(symbol), message)
+}
+
+// Enqueue queues message for delivery by a background worker, which sends at most one message
+// per sendInterval and retries rate-limited ones after the delay Telegram asks for. It never
+// blocks: when the queue is full the message is dropped and logged.
+func (tb *TelegramBot) Enqueue(message string) {
+	tb.enqueue(tb.chatID, message)
+}
+
+func (tb *TelegramBot) enqueue(chatID, message string) {
+	tb.startWorker.Do(func() { go tb.drainQueue() })
+
+	select {
+	case tb.queue <- telegramMessage{chatID: chatID, text: message}:
+	default:
+		botMetrics.RecordNotifierError()
+		log.Printf("Cola de Telegram llena; mensaje descartado")
+	}
+}
+
+// drainQueue sends queued messages one by one at a safe rate
+func (tb *TelegramBot) drainQueue() {
+	for message := range tb.queue {
+		for attempt := 0; ; attempt++ {
+			err := tb.sendMessageTo(message.chatID, message.text)
+			var rateLimit *TelegramRateLimitError
+			if errors.As(err, &rateLimit) && attempt < telegramMaxRetries {
+				log.Printf("Telegram pidió esperar %v antes de reenviar", rateLimit.RetryAfter)
+				time.Sleep(rateLimit.RetryAfter)
+				continue
+			}
+			if err != nil {
+				botMetrics.RecordNotifierError()
+				log.Printf("Error enviando mensaje encolado a Telegram: %v", err)
+			}
+			break
+		}
+		time.Sleep(tb.sendInterval)
 	}
 }
 
 func (tb *TelegramBot) sendMessage(message string) error {
-	if tb.botToken == "" || tb.chatID == "" {
+	return tb.sendMessageTo(tb.chatID, message)
+}
+
+// sendMessageTo sends message to a specific chat
+func (tb *TelegramBot) sendMessageTo(chatID, message string) error {
+	if tb.botToken == "" || chatID == "" {
 		return fmt.Errorf("telegram bot token or chat ID not configured")
 	}
 	
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", tb.botToken)
+	apiURL := fmt.Sprintf("%s/bot%s/sendMessage", tb.apiURL, tb.botToken)
 	
 	payload := map[string]string{
-		"chat_id":    tb.chatID,
+		"chat_id":    chatID,
 		"text":       message,
 		"parse_mode": "HTML",
 	}
@@ -217,10 +878,24 @@ func (tb *TelegramBot) sendMessage(message string) error {
 	}
 	defer resp.Body.Close()
 	
+	if resp.StatusCode == http.StatusTooManyRequests {
+		var body struct {
+			Parameters struct {
+				RetryAfter int `json:"retry_after"`
+			} `json:"parameters"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		retryAfter := time.Duration(body.Parameters.RetryAfter) * time.Second
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		return &TelegramRateLimitError{RetryAfter: retryAfter}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("telegram API returned status code: %d", resp.StatusCode)
 	}
 	
+	botMetrics.RecordNotifierSent()
 	return nil
 }
 
@@ -273,6 +948,95 @@ func formatSignalMessage(symbol, action, price string) string {
 	return msg
 }
 
+// runEdgeGate backtests each symbol on its latest 500 liveInterval candles with the default backtest
+// settings and returns an error listing every symbol whose edge is below the gate
+func runEdgeGate(ctx context.Context, symbols []string, gate EdgeGate) error {
+	var failures []string
+	for _, symbol := range symbols {
+		symbol = strings.TrimSpace(symbol)
+		engine := NewBacktestEngine(BacktestConfig{
+			Symbol:          symbol,
+			InitialBalance:  10000,
+			TransactionFee:  0.001,
+			Interval:        liveInterval,
+			DataLimit:       500,
+			QuietSkips:      true,
+			MinHoldPeriods:  liveSpacing.minHold,
+			CooldownPeriods: liveSpacing.cooldown,
+		})
+		result, err := engine.RunBacktest(ctx)
+		if err != nil {
+			return fmt.Errorf("backtest de %s falló: %v", symbol, err)
+		}
+		failures = append(failures, gate.Check(result)...)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("la estrategia no supera el edge gate: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// nextCandleCheck returns the next time to poll: the upcoming interval boundary plus the
+// confirmation delay. A poll still inside the current boundary's delay window waits for it.
+func nextCandleCheck(now time.Time, interval, delay time.Duration) time.Time {
+	boundary := now.Add(-delay).Truncate(interval).Add(interval)
+	return boundary.Add(delay)
+}
+
+// sleepContext waits for d or until ctx is cancelled, reporting whether the full wait elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// processSignal evaluates the strategy on ts, records the signal and notifies BUY/SELL
+func processSignal(symbol string, ts *techan.TimeSeries, lastPrice string) {
+	signal := analyzeSignal(symbol, ts)
+	action := signal.Action
+	if ok, elapsed, required := liveSpacing.Allow(symbol, action, ts.LastCandle()); !ok {
+		log.Printf("[%s] Señal %s ignorada: %d de %d velas desde la anterior", symbol, action, elapsed, required)
+		action = "HOLD"
+	} else if liveSpacing.Repeated(symbol, action, ts.LastCandle()) {
+		log.Printf("[%s] Señal %s ya procesada en esta vela", symbol, action)
+		action = "HOLD"
+	}
+	botMetrics.RecordSignal(symbol, lastPrice, action, len(ts.Candles))
+	log.Printf("[%s] Precio: $%s → Señal: %s (confianza %.2f, %s)", symbol, lastPrice, action, signal.Confidence, signal.Reason)
+
+	// Simulate the signal on the paper portfolio
+	if paperTrader != nil {
+		price, _ := strconv.ParseFloat(lastPrice, 64)
+		if _, err := paperTrader.OnSignal(symbol, action, price, time.Now()); err != nil {
+			log.Printf("Error guardando el portafolio de paper trading: %v", err)
+		}
+	}
+
+	// Display additional info for buy/sell signals
+	if action == "BUY" {
+		log.Printf("🚀 SEÑAL DE COMPRA detectada para %s", symbol)
+
+		// Send signal to Telegram
+		if telegramBot != nil {
+			telegramBot.EnqueueFor(symbol, formatSignalMessage(symbol, action, lastPrice))
+		}
+	} else if action == "SELL" {
+		log.Printf("🔻 SEÑAL DE VENTA detectada para %s", symbol)
+
+		// Send signal to Telegram
+		if telegramBot != nil {
+			telegramBot.EnqueueFor(symbol, formatSignalMessage(symbol, action, lastPrice))
+		}
+	}
+}
+
 // analyze moved to analyze.go
 
 func main() {
@@ -287,17 +1051,57 @@ func main() {
     // If not backtest, parse flags normally
     backtestFlag := flag.Bool("backtest", false, "Run backtest mode")
     useMLAnalyzeFlag := flag.Bool("useml", false, "Use ML-based analyze() in live/backtest modes")
+	mlFallbackFlag := flag.String("ml-fallback", "hold", "What -useml does while the ML model is untrained: classic or hold")
+	metricsAddrFlag := flag.String("metrics-addr", "", "Address for the /healthz, /status and /metrics HTTP server, e.g. :9090 (falls back to METRICS_ADDR; disabled when empty)")
+	minEMAATRFlag := flag.Float64("min-ema-atr", 0, "Minimum EMA gap as a multiple of ATR to act on a cross (0 = disabled)")
+	strategyFlag := flag.String("strategy", "", "Signal rules: classic (EMA/RSI/MACD), stochastic or bollinger (default classic)")
+	emaFlag := flag.String("ema", "", "EMA short,long periods (default 9,21)")
+	rsiFlag := flag.String("rsi", "", "RSI period (default 14)")
+	rsiLevelsFlag := flag.String("rsi-levels", "", "RSI overbought,oversold levels (default 70,30)")
+	macdFlag := flag.String("macd", "", "MACD fast,slow,signal periods (default 12,26,9)")
+	stochFlag := flag.String("stoch", "", "Stochastic %K,smoothing,%D periods (default 14,3,3)")
+	stochLevelsFlag := flag.String("stoch-levels", "", "Stochastic overbought,oversold levels (default 80,20)")
+	bollingerFlag := flag.String("bollinger", "", "Bollinger band period,stddev multiplier (default 20,2)")
+	minSharpeFlag := flag.Float64("min-sharpe", 0, "Refuse to start unless a backtest of each pair reaches this Sharpe ratio")
+	minExpectancyFlag := flag.Float64("min-expectancy", 0, "Refuse to start unless a backtest of each pair reaches this mean return per trade (%)")
+	configFlag := flag.String("config", "", "JSON config file; env vars override its values and flags override env (default $CONFIG_FILE)")
+	versionFlag := flag.Bool("version", false, "Print the version and build metadata, then exit")
 	flag.Parse()
+
+	if *versionFlag {
+		printVersion(os.Stdout)
+		return
+	}
+
+	// The edge gate only checks thresholds that were given explicitly
+	var gate EdgeGate
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-sharpe":
+			gate.MinSharpe = minSharpeFlag
+		case "min-expectancy":
+			gate.MinExpectancy = minExpectancyFlag
+		}
+	})
 	
 	if *backtestFlag {
 		RunBacktestCLI()
 		return
 	}
 
+    // A JSON config file can replace .env entirely
     err := godotenv.Load()
-	if err != nil {
+	if err != nil && *configFlag == "" && os.Getenv("CONFIG_FILE") == "" {
 		log.Fatal("Error cargando .env")
 	}
+	configPath := *configFlag
+	if configPath == "" {
+		configPath = os.Getenv("CONFIG_FILE")
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
 
     // Toggle ML analyze() via flag or env
     useMLEnv := strings.ToLower(os.Getenv("USE_ML_ANALYZE"))
@@ -305,38 +1109,115 @@ func main() {
         UseMLAnalyze = true
         log.Printf("ML analyze() enabled (flag/env)")
     }
+	if MLFallback, err = parseMLFallback(*mlFallbackFlag); err != nil {
+		log.Fatal(err)
+	}
+	MinEMAATRMultiple = *minEMAATRFlag
+
+	Strategy = cfg.Strategy
+	if err := applyStrategyFlags(&Strategy, *strategyFlag, *emaFlag, *rsiFlag, *rsiLevelsFlag, *macdFlag, *stochFlag, *stochLevelsFlag, *bollingerFlag); err != nil {
+		log.Fatal(err)
+	}
+	if err := Strategy.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	liveSpacing = newSignalSpacing(cfg.MinHoldPeriods, cfg.CooldownPeriods)
 
     // Initialize Binance client
-	apiKey := os.Getenv("BINANCE_API_KEY")
-	secretKey := os.Getenv("BINANCE_SECRET_KEY")
+	apiKey := cfg.Binance.APIKey
+	secretKey := cfg.Binance.SecretKey
 	if apiKey == "" || secretKey == "" {
-		log.Fatal("BINANCE_API_KEY and BINANCE_SECRET_KEY must be set in .env file")
+		log.Fatal("BINANCE_API_KEY and BINANCE_SECRET_KEY must be set in .env file or the config file")
 	}
 	binanceClient = NewBinanceClient(apiKey, secretKey)
+	applyBinanceEnv(binanceClient)
+	marketData = binanceClient
+
+	// Cancel in-flight requests and stop the loop on Ctrl+C / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	symbols := strings.Split(os.Getenv("TRADING_PAIRS"), ",")
-	intervalMin, _ := strconv.Atoi(os.Getenv("INTERVAL_MINUTES"))
+	symbols := cfg.TradingPairs
+	intervalMin := cfg.IntervalMinutes
 	if intervalMin == 0 {
 		intervalMin = 5 // default 5 minutes
 	}
 
+	// Optionally align polls to candle closes, waiting a few seconds for the exchange to finalize them
+	closeDelayStr := os.Getenv("CANDLE_CLOSE_DELAY_SECONDS")
+	alignToCandleClose := closeDelayStr != ""
+	closeDelaySec := 0
+	if alignToCandleClose {
+		closeDelaySec, err = strconv.Atoi(strings.TrimSpace(closeDelayStr))
+		if err != nil || closeDelaySec < 0 {
+			log.Fatalf("CANDLE_CLOSE_DELAY_SECONDS inválido %q: usar un número de segundos no negativo", closeDelayStr)
+		}
+	}
+	closeDelay := time.Duration(closeDelaySec) * time.Second
+
+	// Optionally replace synthetic candles with the exchange's closed candle at each boundary
+	verifyEnv := strings.ToLower(os.Getenv("VERIFY_CLOSED_CANDLES"))
+	verifyClosedCandles := verifyEnv == "true" || verifyEnv == "1" || verifyEnv == "yes"
+
+	// Decide what to do with the still-forming candle at the tail of the series
+	switch formingMode := strings.ToLower(os.Getenv("FORMING_CANDLE")); formingMode {
+	case "", "keep":
+	case "drop":
+		dropFormingCandles = true
+	default:
+		log.Fatalf("FORMING_CANDLE inválido %q: usar keep o drop", formingMode)
+	}
+
+	// Bound how many candles each live series keeps
+	if s := os.Getenv("SERIES_MAX_CANDLES"); s != "" {
+		maxCandles, err := strconv.Atoi(s)
+		if err != nil || maxCandles <= 0 {
+			log.Fatalf("SERIES_MAX_CANDLES inválido %q: usar un entero positivo", s)
+		}
+		if warmup := Strategy.Warmup(); maxCandles <= warmup {
+			log.Fatalf("SERIES_MAX_CANDLES=%d es insuficiente: la estrategia necesita más de %d velas", maxCandles, warmup)
+		}
+		maxSeriesCandles = maxCandles
+	}
+
+	// Optionally receive real closed candles over WebSocket instead of polling tickers
+	streamEnv := strings.ToLower(os.Getenv("STREAM_KLINES"))
+	streamKlines := streamEnv == "true" || streamEnv == "1" || streamEnv == "yes"
+
+	// Refuse to trade a strategy whose recent backtest shows no edge
+	if gate.Enabled() {
+		log.Println("Verificando el edge de la estrategia con un backtest previo...")
+		if err := runEdgeGate(ctx, symbols, gate); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Edge gate superado")
+	}
+
 	// Initialize Telegram bot (optional)
-	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	chatID := os.Getenv("TELEGRAM_CHAT_ID")
-	sendAllUpdatesStr := strings.ToLower(os.Getenv("SEND_ALL_UPDATES"))
-	sendAllUpdates = sendAllUpdatesStr == "true"
+	botToken := cfg.Telegram.BotToken
+	chatID := cfg.Telegram.ChatID
+	sendAllUpdates = cfg.Telegram.SendAllUpdates
 	
 	if botToken != "" && chatID != "" && botToken != "your_bot_token_here" && chatID != "your_chat_id_here" {
 		telegramBot = NewTelegramBot(botToken, chatID)
 		log.Printf("Telegram bot configurado - Enviará señales a chat ID: %s", chatID)
+
+		if len(cfg.Telegram.SymbolChats) > 0 {
+			telegramBot.SetSymbolChats(cfg.Telegram.SymbolChats)
+			for symbol, id := range cfg.Telegram.SymbolChats {
+				log.Printf("Señales de %s → chat ID: %s", symbol, id)
+			}
+		}
 		
 		// Send startup message
 		startupMsg := "🤖 <b>Bot de Trading Iniciado</b>\n\n"
+		startupMsg += fmt.Sprintf("🏷️ Versión: %s (%s)\n", Version, Commit)
 		startupMsg += "📊 Analizando pares: " + strings.Join(symbols, ", ") + "\n"
 		startupMsg += fmt.Sprintf("⏰ Intervalo: %d minutos\n", intervalMin)
 		startupMsg += "🔍 Buscando señales de trading..."
 		
 		if err := telegramBot.sendMessage(startupMsg); err != nil {
+			botMetrics.RecordNotifierError()
 			log.Printf("Error enviando mensaje de inicio a Telegram: %v", err)
 		} else {
 			log.Println("Mensaje de inicio enviado a Telegram")
@@ -345,29 +1226,70 @@ func main() {
 		log.Println("Telegram bot no configurado - solo logs locales")
 	}
 
-	log.Printf("Iniciando bot de trading con Binance API...")
+	// Answer /status, /price and /backtest from the configured chat
+	commandsEnv := strings.ToLower(os.Getenv("TELEGRAM_COMMANDS"))
+	if telegramBot != nil && (commandsEnv == "true" || commandsEnv == "1" || commandsEnv == "yes") {
+		go func() {
+			if err := telegramBot.ListenCommands(ctx, telegramCommandHandlers(ctx)); err != nil && ctx.Err() == nil {
+				log.Printf("Comandos de Telegram detenidos: %v", err)
+			}
+		}()
+		log.Println("Comandos de Telegram habilitados: /status, /price, /backtest")
+	}
+
+	// Simulate every signal on an in-memory portfolio persisted to PAPER_STATE_FILE
+	paperEnv := strings.ToLower(os.Getenv("PAPER_TRADING"))
+	if paperEnv == "true" || paperEnv == "1" || paperEnv == "yes" {
+		pt, reportInterval, err := newPaperTraderFromEnv()
+		if err != nil {
+			log.Fatalf("Paper trading: %v", err)
+		}
+		paperTrader = pt
+		log.Printf("Paper trading habilitado (reporte cada %v)", reportInterval)
+		reportPaperPortfolio()
+		go runPaperReports(ctx, reportInterval)
+	}
+
+	metricsAddr := *metricsAddrFlag
+	if metricsAddr == "" {
+		metricsAddr = os.Getenv("METRICS_ADDR")
+	}
+	if metricsAddr != "" {
+		startMetricsServer(metricsAddr, botMetrics)
+	}
+
+	log.Printf("Iniciando bot de trading con Binance API... (%s)", versionString())
 	log.Printf("Pares a analizar: %v", symbols)
 	log.Printf("Intervalo: %d minutos", intervalMin)
 
+	livePeriod, err := intervalDuration(liveInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Cargar datos históricos iniciales
 	for _, symbol := range symbols {
 		symbol = strings.TrimSpace(symbol)
 		log.Printf("Cargando datos históricos para %s...", symbol)
-		fetchHistoricalData(symbol)
+		fetchHistoricalData(ctx, symbol, liveInterval, livePeriod)
 		time.Sleep(100 * time.Millisecond) // Small delay to avoid rate limits
 	}
 
+	if streamKlines {
+		runStreamLoop(ctx, symbols, liveInterval, livePeriod)
+		log.Println("Bot detenido")
+		return
+	}
+
 	// Loop principal
 	for {
 		log.Println("\n=== Consultando precios actuales ===")
-		tickers := fetchCurrentPrices(symbols)
+		tickers := fetchCurrentPrices(ctx, symbols)
+		botMetrics.RecordPoll(time.Now())
 		
 		// Send price updates to Telegram if enabled
 		if telegramBot != nil && sendAllUpdates && len(tickers) > 0 {
-			priceUpdateMsg := formatPriceUpdate(symbols, tickers)
-			if err := telegramBot.sendMessage(priceUpdateMsg); err != nil {
-				log.Printf("Error enviando actualización de precios a Telegram: %v", err)
-			}
+			telegramBot.Enqueue(formatPriceUpdate(symbols, tickers))
 		}
 		
 		for _, symbol := range symbols {
@@ -384,62 +1306,41 @@ func main() {
 				continue
 			}
 			
-			// Add current price as new candle
+			// Fold the current price into the forming live candle
 			price, err := strconv.ParseFloat(ticker.LastPrice, 64)
 			if err != nil {
 				log.Printf("Error parsing price for %s: %v (raw: %s)", symbol, err, ticker.LastPrice)
 				continue
 			}
 			
-			high, err := strconv.ParseFloat(ticker.HighPrice, 64)
-			if err != nil {
-				log.Printf("Error parsing high price for %s: %v (raw: %s)", symbol, err, ticker.HighPrice)
-				continue
-			}
-			
-			low, err := strconv.ParseFloat(ticker.LowPrice, 64)
-			if err != nil {
-				log.Printf("Error parsing low price for %s: %v (raw: %s)", symbol, err, ticker.LowPrice)
-				continue
+			if verifyClosedCandles {
+				verifyClosedCandle(ctx, symbol, ts, liveInterval, livePeriod, time.Now())
 			}
 			
-			period := techan.NewTimePeriod(time.Now(), time.Minute*15)
-			c := techan.NewCandle(period)
-			c.OpenPrice = big.NewDecimal(price)  // Simplified
-			c.MaxPrice = big.NewDecimal(high)
-			c.MinPrice = big.NewDecimal(low)
-			c.ClosePrice = big.NewDecimal(price)
-			c.Volume = big.NewDecimal(0)
-			ts.AddCandle(c)
-
-			action := analyze(symbol, ts)
-			log.Printf("[%s] Precio: $%s → Señal: %s", symbol, ticker.LastPrice, action)
-			
-			// Display additional info for buy/sell signals
-			if action == "BUY" {
-				log.Printf("🚀 SEÑAL DE COMPRA detectada para %s", symbol)
-				
-				// Send signal to Telegram
-				if telegramBot != nil {
-					signalMsg := formatSignalMessage(symbol, action, ticker.LastPrice)
-					if err := telegramBot.sendMessage(signalMsg); err != nil {
-						log.Printf("Error enviando señal BUY a Telegram: %v", err)
-					}
-				}
-			} else if action == "SELL" {
-				log.Printf("🔻 SEÑAL DE VENTA detectada para %s", symbol)
-				
-				// Send signal to Telegram
-				if telegramBot != nil {
-					signalMsg := formatSignalMessage(symbol, action, ticker.LastPrice)
-					if err := telegramBot.sendMessage(signalMsg); err != nil {
-						log.Printf("Error enviando señal SELL a Telegram: %v", err)
-					}
-				}
+			updateFormingCandle(ts, price, time.Now(), livePeriod)
+
+			// The ticker candle is still forming; optionally evaluate the last closed one instead
+			series := ts
+			if dropFormingCandles {
+				series = closedCandles(ts)
 			}
+			processSignal(symbol, series, ticker.LastPrice)
 		}
 		
+		if alignToCandleClose {
+			nextCheck := nextCandleCheck(time.Now(), livePeriod, closeDelay)
+			log.Printf("\nEsperando al cierre de vela: próxima consulta a las %s\n", nextCheck.Format("15:04:05"))
+			if !sleepContext(ctx, time.Until(nextCheck)) {
+				log.Println("Bot detenido")
+				return
+			}
+			continue
+		}
+
 		log.Printf("\nEsperando %d minutos antes de la próxima consulta...\n", intervalMin)
-		time.Sleep(time.Duration(intervalMin) * time.Minute)
+		if !sleepContext(ctx, time.Duration(intervalMin)*time.Minute) {
+			log.Println("Bot detenido")
+			return
+		}
 	}
 }
@@ -0,0 +1,1248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sdcoffey/big"
+	"github.com/sdcoffey/techan"
+)
+
+// mainArgsEnv makes the test binary run main with these space-separated arguments instead
+// of the tests, so runMain can check paths that exit the process
+const mainArgsEnv = "GOTRADING_TEST_MAIN_ARGS"
+
+// TestMain silences the engine's trade log unless the tests run with -v
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(mainArgsEnv); ok {
+		os.Args = append([]string{"goTrading"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the program with args in a child process, returning its output and exit code
+func runMain(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), mainArgsEnv+"="+strings.Join(args, " "))
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), cmd.ProcessState.ExitCode()
+}
+
+// fakeMarketData serves canned klines per interval and tickers, recording what was asked for
+type fakeMarketData struct {
+	klines  map[string][]BinanceKline // interval -> klines, oldest first
+	tickers map[string]BinanceTicker
+	limits  []int // limit of each fetchKlines call
+}
+
+func (f *fakeMarketData) fetchKlines(ctx context.Context, symbol string, interval string, limit int) ([]BinanceKline, error) {
+	f.limits = append(f.limits, limit)
+	klines, exists := f.klines[interval]
+	if !exists {
+		return nil, fmt.Errorf("no %s klines", interval)
+	}
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	return klines, nil
+}
+
+func (f *fakeMarketData) fetch24hrTickers(ctx context.Context, symbols []string) (map[string]BinanceTicker, error) {
+	return f.tickers, nil
+}
+
+// useMarketData points marketData at data for the rest of the test
+func useMarketData(t *testing.T, data BinanceAPI) {
+	t.Helper()
+	previous := marketData
+	marketData = data
+	t.Cleanup(func() { marketData = previous })
+}
+
+// klineServer is a fake /api/v3/klines endpoint serving klines, oldest first. It honors
+// limit and endTime like Binance, returning overlap extra candles past endTime to mimic
+// overlapping pages, and records the query of every request.
+type klineServer struct {
+	klines  []BinanceKline
+	overlap int
+
+	mu       sync.Mutex
+	requests []map[string]string
+}
+
+func (ks *klineServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ks.mu.Lock()
+	ks.requests = append(ks.requests, map[string]string{
+		"limit": query.Get("limit"), "endTime": query.Get("endTime"),
+	})
+	ks.mu.Unlock()
+
+	end := len(ks.klines)
+	if endTime, err := strconv.ParseInt(query.Get("endTime"), 10, 64); err == nil {
+		end = 0
+		for end < len(ks.klines) && ks.klines[end].OpenTime <= endTime {
+			end++
+		}
+		if end += ks.overlap; end > len(ks.klines) {
+			end = len(ks.klines)
+		}
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	rows := make([][]interface{}, 0, end-start)
+	for _, k := range ks.klines[start:end] {
+		rows = append(rows, []interface{}{k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume,
+			k.CloseTime, "0", 1, "0", "0", "0"})
+	}
+	json.NewEncoder(w).Encode(rows)
+}
+
+// newTestBinanceClient returns a client pointed at handler that retries without waiting
+func newTestBinanceClient(t *testing.T, handler http.Handler) *BinanceClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	bc := NewBinanceClient("", "")
+	bc.baseURL = server.URL
+	bc.retryDelay = 0
+	return bc
+}
+
+func TestFetchKlinesPaged(t *testing.T) {
+	tests := []struct {
+		name      string
+		available int
+		overlap   int
+		limit     int
+		wantPages int
+		wantErr   bool
+	}{
+		{"single page", 3000, 0, 500, 1, false},
+		{"exactly one full page", 3000, 0, 1000, 1, false},
+		{"several pages", 3000, 0, 2500, 3, false},
+		{"overlapping page boundaries", 3000, 1, 2500, 3, false},
+		{"not enough history", 1100, 0, 1200, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			klines := testKlines(flatCloses(tt.available, 100))
+			server := &klineServer{klines: klines, overlap: tt.overlap}
+			bc := newTestBinanceClient(t, server)
+
+			got, err := bc.fetchKlines(context.Background(), "BTCUSDT", "15m", tt.limit)
+			if len(server.requests) != tt.wantPages {
+				t.Errorf("made %d requests, want %d", len(server.requests), tt.wantPages)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d klines", len(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.limit {
+				t.Fatalf("got %d klines, want %d", len(got), tt.limit)
+			}
+			want := klines[len(klines)-tt.limit:]
+			for i := range got {
+				if got[i].OpenTime != want[i].OpenTime {
+					t.Fatalf("kline %d opens at %d, want %d", i, got[i].OpenTime, want[i].OpenTime)
+				}
+			}
+			for _, request := range server.requests {
+				if limit, _ := strconv.Atoi(request["limit"]); limit > binanceMaxKlines {
+					t.Errorf("requested %d klines in one page, more than the cap", limit)
+				}
+			}
+		})
+	}
+}
+
+// captureLog collects the standard logger's output for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
+
+func TestKlineLimitMode(t *testing.T) {
+	tests := []struct {
+		mode         string
+		wantRequests int
+		wantKlines   int
+		wantWarning  bool
+		wantErr      bool
+	}{
+		{"paged", 2, 2000, false, false},
+		{"warn", 1, 1000, true, false},
+		{"error", 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			server := &klineServer{klines: testKlines(flatCloses(3000, 100))}
+			bc := newTestBinanceClient(t, server)
+			if err := bc.SetLimitMode(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			logs := captureLog(t)
+
+			klines, err := bc.fetchKlines(context.Background(), "BTCUSDT", "15m", 2000)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchKlines error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(server.requests) != tt.wantRequests || len(klines) != tt.wantKlines {
+				t.Errorf("made %d requests for %d klines, want %d for %d",
+					len(server.requests), len(klines), tt.wantRequests, tt.wantKlines)
+			}
+			if warned := strings.Contains(logs.String(), "paging is disabled"); warned != tt.wantWarning {
+				t.Errorf("warning logged = %v, want %v (log: %q)", warned, tt.wantWarning, logs.String())
+			}
+		})
+	}
+
+	if err := NewBinanceClient("", "").SetLimitMode("truncate"); err == nil {
+		t.Error("expected an error for an unknown limit mode")
+	}
+}
+
+func TestKlineLimitModeWithinCap(t *testing.T) {
+	for _, mode := range []string{"paged", "warn", "error"} {
+		server := &klineServer{klines: testKlines(flatCloses(3000, 100))}
+		bc := newTestBinanceClient(t, server)
+		bc.SetLimitMode(mode)
+		logs := captureLog(t)
+		klines, err := bc.fetchKlines(context.Background(), "BTCUSDT", "15m", binanceMaxKlines)
+		if err != nil || len(klines) != binanceMaxKlines || logs.Len() > 0 {
+			t.Errorf("%s: got %d klines, error %v, log %q; want %d quietly", mode, len(klines), err, logs.String(), binanceMaxKlines)
+		}
+	}
+}
+
+func TestBinanceAPIErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantCode int
+		wantMsg  string
+	}{
+		{"invalid symbol", http.StatusBadRequest, `{"code":-1121,"msg":"Invalid symbol."}`, -1121, "Invalid symbol."},
+		{"invalid interval", http.StatusBadRequest, `{"code":-1120,"msg":"Invalid interval."}`, -1120, "Invalid interval."},
+		{"non-JSON body", http.StatusNotFound, "<html>not found</html>", 0, "Not Found"},
+		{"server error", http.StatusServiceUnavailable, "", 0, "Service Unavailable"},
+	}
+	fetches := map[string]func(bc *BinanceClient) error{
+		"klines": func(bc *BinanceClient) error {
+			_, err := bc.fetchKlines(context.Background(), "NOPEUSDT", "15m", 10)
+			return err
+		},
+		"tickers": func(bc *BinanceClient) error {
+			_, err := bc.fetch24hrTickers(context.Background(), []string{"NOPEUSDT"})
+			return err
+		},
+	}
+	for _, tt := range tests {
+		for endpoint, fetch := range fetches {
+			t.Run(tt.name+"/"+endpoint, func(t *testing.T) {
+				bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+					io.WriteString(w, tt.body)
+				}))
+				bc.MaxRetries = 0
+
+				err := fetch(bc)
+				var apiErr *BinanceAPIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("got %v, want a *BinanceAPIError", err)
+				}
+				if apiErr.StatusCode != tt.status || apiErr.Code != tt.wantCode || apiErr.Msg != tt.wantMsg {
+					t.Errorf("got HTTP %d code %d %q, want HTTP %d code %d %q",
+						apiErr.StatusCode, apiErr.Code, apiErr.Msg, tt.status, tt.wantCode, tt.wantMsg)
+				}
+			})
+		}
+	}
+}
+
+func TestBinanceCallsHonorDeadlines(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	tests := []struct {
+		name        string
+		ctxTimeout  time.Duration // 0 leaves the context without a deadline
+		httpTimeout time.Duration
+		wantCtxErr  error // Expected context error, or nil for the client's own timeout
+	}{
+		{"context deadline", 50 * time.Millisecond, time.Minute, context.DeadlineExceeded},
+		{"client timeout", 0, 50 * time.Millisecond, nil},
+	}
+	fetches := map[string]func(ctx context.Context, bc *BinanceClient) error{
+		"klines": func(ctx context.Context, bc *BinanceClient) error {
+			_, err := bc.fetchKlines(ctx, "BTCUSDT", "15m", 10)
+			return err
+		},
+		"tickers": func(ctx context.Context, bc *BinanceClient) error {
+			_, err := bc.fetch24hrTickers(ctx, []string{"BTCUSDT"})
+			return err
+		},
+	}
+	for _, tt := range tests {
+		for endpoint, fetch := range fetches {
+			t.Run(tt.name+"/"+endpoint, func(t *testing.T) {
+				bc := newTestBinanceClient(t, slow)
+				bc.MaxRetries = 0
+				bc.SetTimeout(tt.httpTimeout)
+				ctx := context.Background()
+				if tt.ctxTimeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+					defer cancel()
+				}
+
+				start := time.Now()
+				err := fetch(ctx, bc)
+				if elapsed := time.Since(start); elapsed > 2*time.Second {
+					t.Errorf("call blocked for %v", elapsed)
+				}
+				if err == nil {
+					t.Fatal("expected an error from the hung server")
+				}
+				if tt.wantCtxErr != nil && !errors.Is(err, tt.wantCtxErr) {
+					t.Errorf("got %v, want %v", err, tt.wantCtxErr)
+				}
+			})
+		}
+	}
+}
+
+// flakyResponse is one scripted failure of a flaky endpoint; status 0 drops the connection
+type flakyResponse struct {
+	status     int
+	retryAfter string
+}
+
+func TestDoWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     []flakyResponse // Served in order before the endpoint recovers
+		maxRetries   int
+		retryDelay   time.Duration
+		wantRequests int
+		wantErr      bool
+	}{
+		{"503 twice then 200", []flakyResponse{{status: 503}, {status: 503}}, 3, 0, 3, false},
+		{"network errors", []flakyResponse{{}, {}}, 3, 0, 3, false},
+		// Finishing at all within the test timeout means Retry-After replaced the hour
+		{"429 with Retry-After", []flakyResponse{{status: 429, retryAfter: "0"}}, 3, time.Hour, 2, false},
+		{"retries exhausted", []flakyResponse{{status: 500}, {status: 502}, {status: 503}}, 2, 0, 3, true},
+		{"retrying disabled", []flakyResponse{{status: 503}}, 0, 0, 1, true},
+		{"client errors aren't retried", []flakyResponse{{status: 400}}, 3, 0, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			klines := &klineServer{klines: testKlines(flatCloses(20, 100))}
+			bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				attempt := requests
+				mu.Unlock()
+				if attempt > len(tt.failures) {
+					klines.ServeHTTP(w, r)
+					return
+				}
+				failure := tt.failures[attempt-1]
+				if failure.status == 0 {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+					return
+				}
+				if failure.retryAfter != "" {
+					w.Header().Set("Retry-After", failure.retryAfter)
+				}
+				w.WriteHeader(failure.status)
+			}))
+			bc.MaxRetries = tt.maxRetries
+			bc.retryDelay = tt.retryDelay
+
+			got, err := bc.fetchKlines(context.Background(), "BTCUSDT", "15m", 10)
+			if requests != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", requests, tt.wantRequests)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(got) != 10 {
+				t.Errorf("got %d klines, want 10", len(got))
+			}
+		})
+	}
+}
+
+func TestFetch24hrTickersRetries(t *testing.T) {
+	requests := 0
+	bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `[{"symbol":"BTCUSDT","lastPrice":"42000.00"}]`)
+	}))
+
+	tickers, err := bc.fetch24hrTickers(context.Background(), []string{"BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 || tickers["BTCUSDT"].LastPrice != "42000.00" {
+		t.Errorf("got %+v after %d requests, want BTCUSDT at 42000.00 after 3", tickers, requests)
+	}
+}
+
+// tickerServer is a fake /api/v3/ticker/24hr endpoint answering the symbols=[...] form and
+// recording the symbols of every request
+type tickerServer struct {
+	mu       sync.Mutex
+	requests [][]string
+}
+
+func (ts *tickerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var symbols []string
+	if err := json.Unmarshal([]byte(r.URL.Query().Get("symbols")), &symbols); err != nil {
+		http.Error(w, `{"code":-1100,"msg":"Illegal characters found in parameter 'symbols'"}`, http.StatusBadRequest)
+		return
+	}
+	ts.mu.Lock()
+	ts.requests = append(ts.requests, symbols)
+	ts.mu.Unlock()
+
+	tickers := make([]BinanceTicker, len(symbols))
+	for i, symbol := range symbols {
+		tickers[i] = BinanceTicker{Symbol: symbol, LastPrice: strconv.Itoa(len(ts.requests))}
+	}
+	json.NewEncoder(w).Encode(tickers)
+}
+
+func TestTickerCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		ttl          time.Duration
+		pause        time.Duration // Between the two calls
+		second       []string      // Symbols of the second call
+		wantRequests [][]string
+	}{
+		{"within the TTL", time.Minute, 0, []string{"BTCUSDT", "ETHUSDT"}, [][]string{{"BTCUSDT", "ETHUSDT"}}},
+		{"cache disabled", 0, 0, []string{"BTCUSDT", "ETHUSDT"},
+			[][]string{{"BTCUSDT", "ETHUSDT"}, {"BTCUSDT", "ETHUSDT"}}},
+		{"expired", time.Millisecond, 5 * time.Millisecond, []string{"BTCUSDT"},
+			[][]string{{"BTCUSDT", "ETHUSDT"}, {"BTCUSDT"}}},
+		{"only uncached symbols fetched", time.Minute, 0, []string{"BTCUSDT", " SOLUSDT "},
+			[][]string{{"BTCUSDT", "ETHUSDT"}, {"SOLUSDT"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &tickerServer{}
+			bc := newTestBinanceClient(t, server)
+			bc.SetTickerCacheTTL(tt.ttl)
+
+			if _, err := bc.fetch24hrTickers(context.Background(), []string{"BTCUSDT", "ETHUSDT"}); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.pause)
+			tickers, err := bc.fetch24hrTickers(context.Background(), tt.second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(server.requests, tt.wantRequests) {
+				t.Errorf("requested %v, want %v", server.requests, tt.wantRequests)
+			}
+			if len(tickers) != len(tt.second) {
+				t.Errorf("got %d tickers, want %d", len(tickers), len(tt.second))
+			}
+			for _, symbol := range tt.second {
+				if _, ok := tickers[strings.TrimSpace(symbol)]; !ok {
+					t.Errorf("missing %s ticker", symbol)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTickerCacheTTLClearsCache(t *testing.T) {
+	server := &tickerServer{}
+	bc := newTestBinanceClient(t, server)
+	bc.SetTickerCacheTTL(time.Minute)
+	for i := 0; i < 2; i++ {
+		bc.fetch24hrTickers(context.Background(), []string{"BTCUSDT"})
+		bc.SetTickerCacheTTL(time.Minute) // Drops what the first call cached
+	}
+	if len(server.requests) != 2 {
+		t.Errorf("made %d requests, want 2 after resetting the cache", len(server.requests))
+	}
+
+	t.Setenv("BINANCE_TICKER_CACHE_SECONDS", "0")
+	applyBinanceEnv(bc)
+	if bc.tickerTTL != 0 {
+		t.Errorf("BINANCE_TICKER_CACHE_SECONDS=0 left the TTL at %v", bc.tickerTTL)
+	}
+}
+
+func TestFetchKlinesStopsRetryingWhenCancelled(t *testing.T) {
+	var requests int
+	var mu sync.Mutex
+	bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	bc.retryDelay = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := bc.fetchKlines(ctx, "BTCUSDT", "15m", 10)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests, want 1 before the backoff was cut short", requests)
+	}
+}
+
+func TestDecodeKlines(t *testing.T) {
+	const valid = `[1704067200000,"100.0","101.0","99.0","100.5","12.5",1704068099999,"1256.25",42,"6.0","603.0","0"]`
+	tests := []struct {
+		name      string
+		body      string
+		wantCount int
+		wantErr   string // Substring of the error; empty when decoding should succeed
+	}{
+		{"valid rows", "[" + valid + "," + valid + "]", 2, ""},
+		{"empty", "[]", 0, ""},
+		{"short row skipped", `[[1704067200000,"100.0"],` + valid + "]", 1, ""},
+		{"null close", `[[1704067200000,"100.0","101.0","99.0",null,"12.5",1704068099999,"0",1,"0","0","0"]]`, 0, "field close"},
+		{"numeric open", `[[1704067200000,100.0,"101.0","99.0","100.5","12.5",1704068099999,"0",1,"0","0","0"]]`, 0, "field open"},
+		{"string open time", `[["1704067200000","100.0","101.0","99.0","100.5","12.5",1704068099999,"0",1,"0","0","0"]]`, 0, "field openTime"},
+		{"null close time", `[[1704067200000,"100.0","101.0","99.0","100.5","12.5",null,"0",1,"0","0","0"]]`, 0, "field closeTime"},
+		{"second row malformed", "[" + valid + `,[1704068100000,"100.0","101.0","99.0","100.5",null,1704068999999,"0",1,"0","0","0"]]`, 0, "row 1"},
+		{"not klines", `{"code":-1121,"msg":"Invalid symbol."}`, 0, "error decoding klines"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			klines, err := decodeKlines(strings.NewReader(tt.body), "BTCUSDT")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(klines) != tt.wantCount {
+				t.Fatalf("got %d klines, want %d", len(klines), tt.wantCount)
+			}
+			for _, kline := range klines {
+				want := BinanceKline{OpenTime: 1704067200000, CloseTime: 1704068099999,
+					Open: "100.0", High: "101.0", Low: "99.0", Close: "100.5", Volume: "12.5"}
+				if kline != want {
+					t.Errorf("got %+v, want %+v", kline, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchHistoricalDataUsesInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		minutes  int
+	}{
+		{"15m", 15},
+		{"1h", 60},
+		{"4h", 240},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			klines, err := generateSyntheticKlines("trend_up", 120, tt.minutes, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			useMarketData(t, &fakeMarketData{klines: map[string][]BinanceKline{tt.interval: klines}})
+			period, err := intervalDuration(tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fetchHistoricalData(context.Background(), "BTCUSDT", tt.interval, period)
+			ts := seriesMap["BTCUSDT"]
+			delete(seriesMap, "BTCUSDT")
+			if ts == nil || len(ts.Candles) == 0 {
+				t.Fatal("no series loaded")
+			}
+			want := time.Duration(tt.minutes) * time.Minute
+			for i, candle := range ts.Candles {
+				if got := candle.Period.Length(); got != want {
+					t.Fatalf("candle %d lasts %v, want %v", i, got, want)
+				}
+				if i > 0 && candle.Period.Start.Sub(ts.Candles[i-1].Period.Start) != want {
+					t.Fatalf("candle %d starts %v after the previous one, want %v",
+						i, candle.Period.Start.Sub(ts.Candles[i-1].Period.Start), want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchHistoricalDataDropsFormingCandle(t *testing.T) {
+	now := time.Now()
+	closed := testKlines([]float64{100, 101, 102, 103})
+	// Shift the candles so the last one is still open
+	offset := now.Add(-3*15*time.Minute-time.Minute).UnixMilli() - closed[0].OpenTime
+	for i := range closed {
+		closed[i].OpenTime += offset
+		closed[i].CloseTime += offset
+	}
+
+	tests := []struct {
+		name      string
+		drop      bool
+		wantCount int
+		wantClose float64
+	}{
+		{"keep", false, 4, 103},
+		{"drop", true, 3, 102},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setGlobal(t, &dropFormingCandles, tt.drop)
+			useMarketData(t, &fakeMarketData{klines: map[string][]BinanceKline{"15m": closed}})
+
+			fetchHistoricalData(context.Background(), "BTCUSDT", "15m", 15*time.Minute)
+			ts := seriesMap["BTCUSDT"]
+			delete(seriesMap, "BTCUSDT")
+			if ts == nil || len(ts.Candles) != tt.wantCount {
+				t.Fatalf("loaded %v, want %d candles", ts, tt.wantCount)
+			}
+			if got := ts.LastCandle().ClosePrice.Float(); got != tt.wantClose {
+				t.Errorf("last close = %.0f, want %.0f", got, tt.wantClose)
+			}
+		})
+	}
+}
+
+// recordingPredictor is an MLPredictor that answers HOLD and remembers the last close it saw
+type recordingPredictor struct {
+	lastClose *float64
+}
+
+func (p recordingPredictor) Predict(symbol string, ts *techan.TimeSeries) (string, error) {
+	*p.lastClose = ts.LastCandle().ClosePrice.Float()
+	return "HOLD", nil
+}
+
+func TestClosedCandlesFeedsModelLastClosedCandle(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	setGlobal(t, &UseMLAnalyze, true)
+	var lastClose float64
+	setGlobal[MLPredictor](t, &mlPredictor, recordingPredictor{&lastClose})
+	ts := klineSeries(testKlines([]float64{100, 101, 102, 103}))
+
+	tests := []struct {
+		name   string
+		series *techan.TimeSeries
+		want   float64
+	}{
+		{"forming candle included", ts, 103},
+		{"forming candle dropped", closedCandles(ts), 102},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyze("TESTUSDT", tt.series)
+			if lastClose != tt.want {
+				t.Errorf("model saw close %.0f, want %.0f", lastClose, tt.want)
+			}
+		})
+	}
+	if len(ts.Candles) != 4 {
+		t.Errorf("closedCandles modified the live series: %d candles left", len(ts.Candles))
+	}
+	if empty := techan.NewTimeSeries(); closedCandles(empty) != empty {
+		t.Error("closedCandles of an empty series should return it unchanged")
+	}
+}
+
+func TestNextCandleCheck(t *testing.T) {
+	at := func(hour, minute, second int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, second, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		now      time.Time
+		interval time.Duration
+		delay    time.Duration
+		want     time.Time
+	}{
+		{"mid candle", at(10, 7, 0), 15 * time.Minute, 5 * time.Second, at(10, 15, 5)},
+		{"on the boundary", at(10, 15, 0), 15 * time.Minute, 5 * time.Second, at(10, 15, 5)},
+		{"inside the delay window", at(10, 15, 3), 15 * time.Minute, 5 * time.Second, at(10, 15, 5)},
+		{"right after the delayed check", at(10, 15, 5), 15 * time.Minute, 5 * time.Second, at(10, 30, 5)},
+		{"no delay", at(10, 15, 0), 15 * time.Minute, 0, at(10, 30, 0)},
+		{"hourly candles", at(10, 20, 0), time.Hour, 10 * time.Second, at(11, 0, 10)},
+	}
+	for _, tt := range tests {
+		if got := nextCandleCheck(tt.now, tt.interval, tt.delay); !got.Equal(tt.want) {
+			t.Errorf("%s: next check at %s, want %s", tt.name, got.Format("15:04:05"), tt.want.Format("15:04:05"))
+		}
+	}
+}
+
+func TestKlineToCandleVolume(t *testing.T) {
+	tests := []struct {
+		volume string
+		want   float64
+	}{
+		{"1234.5678", 1234.5678},
+		{"0", 0},
+		{"0.00000001", 0.00000001},
+	}
+	klines := testKlines(flatCloses(len(tests), 100))
+	for i, tt := range tests {
+		klines[i].Volume = tt.volume
+	}
+	volume := techan.NewVolumeIndicator(klineSeries(klines))
+	for i, tt := range tests {
+		if got := volume.Calculate(i).Float(); got != tt.want {
+			t.Errorf("candle %d volume = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateFormingCandle(t *testing.T) {
+	period := 15 * time.Minute
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	type tick struct {
+		offset time.Duration // From start
+		price  float64
+	}
+	type ohlc struct {
+		start                  time.Time
+		open, high, low, close float64
+	}
+	tests := []struct {
+		name  string
+		ticks []tick
+		want  []ohlc
+	}{
+		{"single tick", []tick{{time.Minute, 100}}, []ohlc{{start, 100, 100, 100, 100}}},
+		{"ticks within one period", []tick{{0, 100}, {3 * time.Minute, 104}, {7 * time.Minute, 97}, {14 * time.Minute, 101}},
+			[]ohlc{{start, 100, 104, 97, 101}}},
+		{"boundary opens a new candle", []tick{{time.Minute, 100}, {10 * time.Minute, 102}, {15 * time.Minute, 103}, {20 * time.Minute, 99}},
+			[]ohlc{{start, 100, 102, 100, 102}, {start.Add(period), 103, 103, 99, 99}}},
+		{"skipped period", []tick{{time.Minute, 100}, {31 * time.Minute, 105}},
+			[]ohlc{{start, 100, 100, 100, 100}, {start.Add(2 * period), 105, 105, 105, 105}}},
+		{"late tick updates the last candle", []tick{{16 * time.Minute, 100}, {14 * time.Minute, 90}},
+			[]ohlc{{start.Add(period), 100, 100, 90, 90}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := techan.NewTimeSeries()
+			for _, tick := range tt.ticks {
+				updateFormingCandle(ts, tick.price, start.Add(tick.offset), period)
+			}
+			if len(ts.Candles) != len(tt.want) {
+				t.Fatalf("got %d candles, want %d", len(ts.Candles), len(tt.want))
+			}
+			for i, want := range tt.want {
+				c := ts.Candles[i]
+				got := ohlc{c.Period.Start, c.OpenPrice.Float(), c.MaxPrice.Float(), c.MinPrice.Float(), c.ClosePrice.Float()}
+				if got != want || c.Period.Length() != period {
+					t.Errorf("candle %d = %+v lasting %v, want %+v lasting %v", i, got, c.Period.Length(), want, period)
+				}
+			}
+		})
+	}
+}
+
+func TestTrimSeries(t *testing.T) {
+	tests := []struct {
+		name      string
+		candles   int
+		maxLen    int
+		wantCount int
+	}{
+		{"under the cap", 5, 10, 5},
+		{"at the cap", 10, 10, 10},
+		{"over the cap", 25, 10, 10},
+		{"no cap", 25, 0, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := klineSeries(testKlines(trendCloses(tt.candles, 1)))
+			last := ts.LastCandle()
+			trimSeries(ts, tt.maxLen)
+			if len(ts.Candles) != tt.wantCount {
+				t.Fatalf("got %d candles, want %d", len(ts.Candles), tt.wantCount)
+			}
+			if ts.LastCandle() != last {
+				t.Error("the most recent candle wasn't preserved")
+			}
+			if first := ts.Candles[0].ClosePrice.Float(); first != float64(100+tt.candles-tt.wantCount) {
+				t.Errorf("oldest kept close = %v, want %v", first, 100+tt.candles-tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestLiveSeriesStayWithinCap(t *testing.T) {
+	setGlobal(t, &maxSeriesCandles, 50)
+	period := 15 * time.Minute
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ts := techan.NewTimeSeries()
+	for i := 0; i < 400; i++ {
+		updateFormingCandle(ts, 100+float64(i), start.Add(time.Duration(i)*5*time.Minute), period)
+		if len(ts.Candles) > maxSeriesCandles {
+			t.Fatalf("series grew to %d candles after %d ticks, over the cap of %d", len(ts.Candles), i+1, maxSeriesCandles)
+		}
+	}
+	if len(ts.Candles) != maxSeriesCandles {
+		t.Errorf("series holds %d candles, want %d", len(ts.Candles), maxSeriesCandles)
+	}
+	last := ts.LastCandle()
+	if !last.Period.Start.Equal(start.Add(399*5*time.Minute).Truncate(period)) || last.ClosePrice.Float() != 499 {
+		t.Errorf("last candle = %v, want the one holding the final tick", last)
+	}
+
+	// History loads are trimmed to the same window
+	useMarketData(t, &fakeMarketData{klines: map[string][]BinanceKline{"15m": testKlines(flatCloses(100, 100))}})
+	fetchHistoricalData(context.Background(), "BTCUSDT", "15m", period)
+	defer delete(seriesMap, "BTCUSDT")
+	if got := len(seriesMap["BTCUSDT"].Candles); got != maxSeriesCandles {
+		t.Errorf("history loaded %d candles, want %d", got, maxSeriesCandles)
+	}
+}
+
+func TestVerifyClosedCandleReplacesSynthetic(t *testing.T) {
+	setGlobal(t, &lastVerifiedCandle, map[string]int64{})
+	period := 15 * time.Minute
+	boundary := time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)
+
+	// Polled prices build the synthetic 10:00 candle, then the 10:15 one starts forming
+	ts := techan.NewTimeSeries()
+	for i, price := range []float64{100, 103, 98, 101} {
+		updateFormingCandle(ts, price, boundary.Add(-period+time.Duration(i)*3*time.Minute), period)
+	}
+	updateFormingCandle(ts, 102, boundary.Add(time.Second), period)
+
+	exchange := BinanceKline{
+		OpenTime: boundary.Add(-period).UnixMilli(), CloseTime: boundary.UnixMilli() - 1,
+		Open: "99.5", High: "104.2", Low: "97.1", Close: "101.3", Volume: "52.5",
+	}
+	forming := BinanceKline{
+		OpenTime: boundary.UnixMilli(), CloseTime: boundary.Add(period).UnixMilli() - 1,
+		Open: "101.3", High: "102", Low: "101", Close: "102", Volume: "3",
+	}
+	data := &fakeMarketData{klines: map[string][]BinanceKline{"15m": {exchange, forming}}}
+	useMarketData(t, data)
+
+	verifyClosedCandle(context.Background(), "BTCUSDT", ts, "15m", period, boundary.Add(5*time.Second))
+	if len(ts.Candles) != 2 {
+		t.Fatalf("got %d candles, want the verified one and the forming one", len(ts.Candles))
+	}
+	closed := ts.Candles[0]
+	want := klineToCandle(exchange, period)
+	if !closed.Period.Start.Equal(want.Period.Start) || closed.OpenPrice.Float() != 99.5 ||
+		closed.MaxPrice.Float() != 104.2 || closed.MinPrice.Float() != 97.1 ||
+		closed.ClosePrice.Float() != 101.3 || closed.Volume.Float() != 52.5 {
+		t.Errorf("closed candle = %v, want the exchange's %v", closed, want)
+	}
+	if got := ts.Candles[1].ClosePrice.Float(); got != 102 {
+		t.Errorf("forming candle close = %v, want the polled 102 left alone", got)
+	}
+
+	// The same closed candle isn't fetched again on later polls
+	verifyClosedCandle(context.Background(), "BTCUSDT", ts, "15m", period, boundary.Add(time.Minute))
+	if len(data.limits) != 1 {
+		t.Errorf("fetched %d times, want once per closed candle", len(data.limits))
+	}
+}
+
+func TestReplaceCandlePeriod(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	candleAt := func(minute int, length time.Duration, close float64) *techan.Candle {
+		c := techan.NewCandle(techan.NewTimePeriod(start.Add(time.Duration(minute)*time.Minute), length))
+		c.ClosePrice = big.NewDecimal(close)
+		return c
+	}
+	tests := []struct {
+		name   string
+		series []*techan.Candle
+		closed *techan.Candle
+		want   []float64 // Closes after the replacement, in order
+	}{
+		{"replaces the matching candle", []*techan.Candle{candleAt(0, 15*time.Minute, 1), candleAt(15, 15*time.Minute, 2), candleAt(30, 15*time.Minute, 3)},
+			candleAt(15, 15*time.Minute, 20), []float64{1, 20, 3}},
+		{"collapses several synthetic candles", []*techan.Candle{candleAt(0, 5*time.Minute, 1), candleAt(5, 5*time.Minute, 2), candleAt(10, 5*time.Minute, 3), candleAt(15, 5*time.Minute, 4)},
+			candleAt(0, 15*time.Minute, 10), []float64{10, 4}},
+		{"fills a missing period", []*techan.Candle{candleAt(0, 15*time.Minute, 1), candleAt(30, 15*time.Minute, 3)},
+			candleAt(15, 15*time.Minute, 2), []float64{1, 2, 3}},
+		{"appends after the last candle", []*techan.Candle{candleAt(0, 15*time.Minute, 1)},
+			candleAt(15, 15*time.Minute, 2), []float64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := &techan.TimeSeries{Candles: tt.series}
+			replaceCandlePeriod(ts, tt.closed)
+			var got []float64
+			for _, c := range ts.Candles {
+				got = append(got, c.ClosePrice.Float())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("closes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunEdgeGateBlocksLosingStrategy(t *testing.T) {
+	warmup := DefaultStrategyConfig().Warmup()
+	minExpectancy := 0.0
+	gate := EdgeGate{MinExpectancy: &minExpectancy}
+	tests := []struct {
+		name    string
+		exit    float64 // Price the scripted round trips sell at, after buying at 100
+		wantErr bool
+	}{
+		{"negative expectancy", 95, true},
+		{"positive expectancy", 105, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closes := flatCloses(500, 100)
+			actions := map[int]string{}
+			for bar := warmup; bar+1 < len(closes); bar += 10 {
+				actions[bar], actions[bar+1] = "BUY", "SELL"
+				closes[bar+1] = tt.exit
+			}
+			useScript(t, actions)
+			useMarketData(t, &fakeMarketData{klines: map[string][]BinanceKline{liveInterval: testKlines(closes)}})
+
+			var err error
+			captureStdout(t, func() { err = runEdgeGate(context.Background(), []string{"BTCUSDT"}, gate) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runEdgeGate() = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "expectancy") {
+				t.Errorf("error %q doesn't say why", err)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderSignsRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		orderType  string
+		price      float64
+		wantParams map[string]string // Expected form values; "" means must be absent
+	}{
+		{"market", "MARKET", 0, map[string]string{"type": "MARKET", "price": "", "timeInForce": ""}},
+		{"limit", "LIMIT", 42000.5, map[string]string{"type": "LIMIT", "price": "42000.5", "timeInForce": "GTC"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIVE_TRADING", "true")
+			var got *http.Request
+			var body string
+			bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				raw, _ := io.ReadAll(r.Body)
+				got, body = r, string(raw)
+				io.WriteString(w, `{"symbol":"BTCUSDT","orderId":7,"status":"FILLED","side":"BUY","type":"`+tt.orderType+`","executedQty":"0.01"}`)
+			}))
+			bc.apiKey, bc.secretKey = "test-key", "test-secret"
+
+			order, err := bc.PlaceOrder(context.Background(), "BTCUSDT", "BUY", tt.orderType, 0.01, tt.price)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if order.OrderID != 7 || order.Status != "FILLED" {
+				t.Errorf("order = %+v, want the decoded response", order)
+			}
+			if got.Method != http.MethodPost || got.URL.Path != "/api/v3/order" {
+				t.Errorf("request %s %s, want POST /api/v3/order", got.Method, got.URL.Path)
+			}
+			if key := got.Header.Get("X-MBX-APIKEY"); key != "test-key" {
+				t.Errorf("X-MBX-APIKEY = %q, want test-key", key)
+			}
+
+			// The signature is the HMAC of everything before it
+			query, signature, found := strings.Cut(body, "&signature=")
+			if !found {
+				t.Fatalf("body %q has no signature", body)
+			}
+			if want := bc.signRequest(query); signature != want {
+				t.Errorf("signature = %s, want %s", signature, want)
+			}
+			values, err := url.ParseQuery(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{"symbol": "BTCUSDT", "side": "BUY", "quantity": "0.01",
+				"recvWindow": strconv.Itoa(binanceRecvWindow)}
+			for name, value := range tt.wantParams {
+				want[name] = value
+			}
+			for name, value := range want {
+				if values.Get(name) != value {
+					t.Errorf("%s = %q, want %q", name, values.Get(name), value)
+				}
+			}
+			if ts, err := strconv.ParseInt(values.Get("timestamp"), 10, 64); err != nil || time.Since(time.UnixMilli(ts)) > time.Minute {
+				t.Errorf("timestamp = %q, want the current time in ms", values.Get("timestamp"))
+			}
+		})
+	}
+}
+
+func TestPlaceOrderRefusals(t *testing.T) {
+	tests := []struct {
+		name      string
+		live      string
+		side      string
+		orderType string
+		quantity  float64
+		price     float64
+	}{
+		{"live trading disabled", "", "BUY", "MARKET", 1, 0},
+		{"live trading false", "false", "BUY", "MARKET", 1, 0},
+		{"unknown side", "true", "HOLD", "MARKET", 1, 0},
+		{"unknown type", "true", "BUY", "STOP", 1, 0},
+		{"no quantity", "true", "SELL", "MARKET", 0, 0},
+		{"limit without price", "true", "SELL", "LIMIT", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIVE_TRADING", tt.live)
+			requests := 0
+			bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { requests++ }))
+			if _, err := bc.PlaceOrder(context.Background(), "BTCUSDT", tt.side, tt.orderType, tt.quantity, tt.price); err == nil {
+				t.Error("expected an error")
+			}
+			if requests != 0 {
+				t.Errorf("sent %d requests, want none", requests)
+			}
+		})
+	}
+
+	t.Run("rejected by Binance", func(t *testing.T) {
+		t.Setenv("LIVE_TRADING", "true")
+		bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"code":-2010,"msg":"Account has insufficient balance for requested action."}`)
+		}))
+		_, err := bc.PlaceOrder(context.Background(), "BTCUSDT", "BUY", "MARKET", 1, 0)
+		var apiErr *BinanceAPIError
+		if !errors.As(err, &apiErr) || apiErr.Code != -2010 {
+			t.Errorf("got %v, want Binance error -2010", err)
+		}
+	})
+}
+
+func TestEnqueueDeliversThroughRateLimit(t *testing.T) {
+	server := &telegramServer{
+		sendStatuses: []int{http.StatusOK, http.StatusTooManyRequests},
+		delivered:    make(chan map[string]string, 20),
+	}
+	tb := newTestTelegramBot(t, server)
+	logs := captureLog(t)
+
+	for i := 0; i < 10; i++ {
+		tb.Enqueue(fmt.Sprintf("señal %d", i))
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case payload := <-server.delivered:
+			if want := fmt.Sprintf("señal %d", i); payload["text"] != want || payload["chat_id"] != "42" {
+				t.Errorf("delivery %d = %v, want %q to chat 42", i, payload, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("only %d of 10 messages delivered", i)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.sent) != 11 {
+		t.Errorf("made %d sendMessage calls, want 11 with the one retry", len(server.sent))
+	}
+	if !strings.Contains(logs.String(), "Telegram pidió esperar 1s") {
+		t.Errorf("the rate limit wasn't logged: %q", logs.String())
+	}
+}
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	tb := NewTelegramBot("TOKEN", "42")
+	tb.queue = make(chan telegramMessage, 2)
+	tb.startWorker.Do(func() {}) // No worker, so the queue only fills up
+	logs := captureLog(t)
+
+	for i := 0; i < 3; i++ {
+		tb.Enqueue(fmt.Sprintf("señal %d", i))
+	}
+	if len(tb.queue) != 2 {
+		t.Errorf("queue holds %d messages, want 2", len(tb.queue))
+	}
+	if strings.Count(logs.String(), "Cola de Telegram llena") != 1 {
+		t.Errorf("want one dropped-message log, got %q", logs.String())
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		body           string
+		wantRetryAfter time.Duration // Set when a *TelegramRateLimitError is expected
+		wantErr        string
+	}{
+		{"ok", http.StatusOK, `{"ok":true}`, 0, ""},
+		{"rate limited", http.StatusTooManyRequests, `{"ok":false,"parameters":{"retry_after":3}}`, 3 * time.Second, ""},
+		{"rate limited without retry_after", http.StatusTooManyRequests, `{"ok":false}`, time.Second, ""},
+		{"server error", http.StatusInternalServerError, "", 0, "status code: 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+			tb := NewTelegramBot("TOKEN", "42")
+			tb.apiURL = server.URL
+
+			err := tb.sendMessage("hola")
+			var rateLimit *TelegramRateLimitError
+			switch {
+			case tt.wantRetryAfter > 0:
+				if !errors.As(err, &rateLimit) || rateLimit.RetryAfter != tt.wantRetryAfter {
+					t.Errorf("got %v, want a rate limit of %v", err, tt.wantRetryAfter)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v, want an error mentioning %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+
+	if err := NewTelegramBot("", "42").sendMessage("hola"); err == nil {
+		t.Error("expected an error without a bot token")
+	}
+}
+
+func TestParseSymbolChats(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"BTCUSDT:123,ETHUSDT:456", map[string]string{"BTCUSDT": "123", "ETHUSDT": "456"}, false},
+		{" btcusdt : -1001234 , ", map[string]string{"BTCUSDT": "-1001234"}, false},
+		{"", map[string]string{}, false},
+		{"BTCUSDT", nil, true},
+		{"BTCUSDT:", nil, true},
+		{":123", nil, true},
+		{"BTCUSDT:group", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSymbolChats(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSymbolChats(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSymbolChats(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSignalRoutingBySymbol(t *testing.T) {
+	server := &telegramServer{delivered: make(chan map[string]string, 10)}
+	tb := newTestTelegramBot(t, server)
+	routes, err := parseSymbolChats("BTCUSDT:123,ETHUSDT:456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb.SetSymbolChats(routes)
+
+	tests := []struct {
+		symbol   string
+		wantChat string
+	}{
+		{"BTCUSDT", "123"},
+		{"ethusdt", "456"},
+		{"DOGEUSDT", "42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			if got := tb.ChatFor(tt.symbol); got != tt.wantChat {
+				t.Errorf("ChatFor(%s) = %s, want %s", tt.symbol, got, tt.wantChat)
+			}
+			tb.EnqueueFor(tt.symbol, formatSignalMessage(tt.symbol, "BUY", "100"))
+			select {
+			case payload := <-server.delivered:
+				if payload["chat_id"] != tt.wantChat {
+					t.Errorf("%s signal went to chat %s, want %s", tt.symbol, payload["chat_id"], tt.wantChat)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("signal not delivered")
+			}
+		})
+	}
+}
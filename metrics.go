@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SymbolMetrics holds the latest observed state for a trading pair
+type SymbolMetrics struct {
+	LastPrice    string    `json:"last_price"`
+	LastSignal   string    `json:"last_signal"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	SeriesLength int       `json:"series_length"` // Candles in the series the signal was computed on
+}
+
+// fetchMetrics tracks the Binance requests made to one endpoint
+type fetchMetrics struct {
+	requests    int
+	errors      int
+	lastLatency time.Duration
+}
+
+// MetricsSnapshot is the JSON document served by /status
+type MetricsSnapshot struct {
+	StartTime      time.Time                `json:"start_time"`
+	UptimeSeconds  float64                  `json:"uptime_seconds"`
+	LastPoll       time.Time                `json:"last_poll"`
+	Symbols        map[string]SymbolMetrics `json:"symbols"`
+	NotifierErrors int                      `json:"notifier_errors"`
+}
+
+// BotMetrics tracks the live bot's state for monitoring
+type BotMetrics struct {
+	mu             sync.Mutex
+	startTime      time.Time
+	lastPoll       time.Time
+	symbols        map[string]SymbolMetrics
+	notifierErrors int
+	notifierSent   int
+	signals        map[string]map[string]int // symbol -> signal -> count
+	fetches        map[string]*fetchMetrics  // endpoint -> requests
+}
+
+var botMetrics = NewBotMetrics()
+
+// NewBotMetrics creates an empty metrics tracker starting now
+func NewBotMetrics() *BotMetrics {
+	return &BotMetrics{
+		startTime: time.Now(),
+		symbols:   make(map[string]SymbolMetrics),
+		signals:   make(map[string]map[string]int),
+		fetches:   make(map[string]*fetchMetrics),
+	}
+}
+
+// RecordPoll records the time of a price poll
+func (m *BotMetrics) RecordPoll(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastPoll = t
+}
+
+// RecordSignal records the latest price and signal for a symbol, computed on a series of
+// seriesLength candles, and counts the signal
+func (m *BotMetrics) RecordSignal(symbol, price, signal string, seriesLength int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.symbols[symbol] = SymbolMetrics{
+		LastPrice:    price,
+		LastSignal:   signal,
+		UpdatedAt:    time.Now(),
+		SeriesLength: seriesLength,
+	}
+	if m.signals[symbol] == nil {
+		m.signals[symbol] = make(map[string]int)
+	}
+	m.signals[symbol][signal]++
+}
+
+// RecordNotifierError counts a failed notification
+func (m *BotMetrics) RecordNotifierError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifierErrors++
+}
+
+// RecordNotifierSent counts a delivered notification
+func (m *BotMetrics) RecordNotifierSent() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifierSent++
+}
+
+// RecordFetch records one Binance request to endpoint, how long it took and whether it failed
+func (m *BotMetrics) RecordFetch(endpoint string, latency time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fm := m.fetches[endpoint]
+	if fm == nil {
+		fm = &fetchMetrics{}
+		m.fetches[endpoint] = fm
+	}
+	fm.requests++
+	fm.lastLatency = latency
+	if failed {
+		fm.errors++
+	}
+}
+
+// Snapshot returns a copy of the current metrics
+func (m *BotMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	symbols := make(map[string]SymbolMetrics, len(m.symbols))
+	for symbol, sm := range m.symbols {
+		symbols[symbol] = sm
+	}
+
+	return MetricsSnapshot{
+		StartTime:      m.startTime,
+		UptimeSeconds:  time.Since(m.startTime).Seconds(),
+		LastPoll:       m.lastPoll,
+		Symbols:        symbols,
+		NotifierErrors: m.notifierErrors,
+	}
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *BotMetrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP gotrading_uptime_seconds Seconds since the bot started.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_uptime_seconds gauge\n")
+	fmt.Fprintf(w, "gotrading_uptime_seconds %g\n", time.Since(m.startTime).Seconds())
+
+	if !m.lastPoll.IsZero() {
+		fmt.Fprintf(w, "# HELP gotrading_last_poll_timestamp_seconds Unix time of the last price poll.\n")
+		fmt.Fprintf(w, "# TYPE gotrading_last_poll_timestamp_seconds gauge\n")
+		fmt.Fprintf(w, "gotrading_last_poll_timestamp_seconds %d\n", m.lastPoll.Unix())
+	}
+
+	symbols := make([]string, 0, len(m.symbols))
+	for symbol := range m.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	fmt.Fprintf(w, "# HELP gotrading_signals_total Signals computed, by symbol and signal.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_signals_total counter\n")
+	for _, symbol := range symbols {
+		signals := make([]string, 0, len(m.signals[symbol]))
+		for signal := range m.signals[symbol] {
+			signals = append(signals, signal)
+		}
+		sort.Strings(signals)
+		for _, signal := range signals {
+			fmt.Fprintf(w, "gotrading_signals_total{symbol=%q,signal=%q} %d\n", symbol, signal, m.signals[symbol][signal])
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP gotrading_series_candles Candles in the series of the last signal, by symbol.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_series_candles gauge\n")
+	for _, symbol := range symbols {
+		fmt.Fprintf(w, "gotrading_series_candles{symbol=%q} %d\n", symbol, m.symbols[symbol].SeriesLength)
+	}
+
+	endpoints := make([]string, 0, len(m.fetches))
+	for endpoint := range m.fetches {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintf(w, "# HELP gotrading_fetch_requests_total Binance API requests, by endpoint.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_fetch_requests_total counter\n")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "gotrading_fetch_requests_total{endpoint=%q} %d\n", endpoint, m.fetches[endpoint].requests)
+	}
+	fmt.Fprintf(w, "# HELP gotrading_fetch_errors_total Failed Binance API requests (network errors and 4xx/5xx), by endpoint.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_fetch_errors_total counter\n")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "gotrading_fetch_errors_total{endpoint=%q} %d\n", endpoint, m.fetches[endpoint].errors)
+	}
+	fmt.Fprintf(w, "# HELP gotrading_fetch_latency_seconds Duration of the last Binance API request, by endpoint.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_fetch_latency_seconds gauge\n")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "gotrading_fetch_latency_seconds{endpoint=%q} %g\n", endpoint, m.fetches[endpoint].lastLatency.Seconds())
+	}
+
+	fmt.Fprintf(w, "# HELP gotrading_notifications_sent_total Telegram messages delivered.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_notifications_sent_total counter\n")
+	fmt.Fprintf(w, "gotrading_notifications_sent_total %d\n", m.notifierSent)
+	fmt.Fprintf(w, "# HELP gotrading_notifier_errors_total Telegram messages that failed or were dropped.\n")
+	fmt.Fprintf(w, "# TYPE gotrading_notifier_errors_total counter\n")
+	fmt.Fprintf(w, "gotrading_notifier_errors_total %d\n", m.notifierErrors)
+}
+
+// newMetricsHandler builds the /healthz, /status and /metrics routes
+func newMetricsHandler(m *BotMetrics) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.Snapshot()); err != nil {
+			log.Printf("Error encoding status: %v", err)
+		}
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WritePrometheus(w)
+	})
+
+	return mux
+}
+
+// startMetricsServer serves the metrics endpoints on addr in the background
+func startMetricsServer(addr string, m *BotMetrics) {
+	go func() {
+		log.Printf("Servidor de métricas escuchando en %s", addr)
+		if err := http.ListenAndServe(addr, newMetricsHandler(m)); err != nil {
+			log.Printf("Error en servidor de métricas: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestMetricsServer serves m's endpoints for the rest of the test
+func newTestMetricsServer(t *testing.T, m *BotMetrics) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(newMetricsHandler(m))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// getBody fetches url, failing the test unless it answers wantStatus
+func getBody(t *testing.T, url string, wantStatus int) (string, http.Header) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("GET %s: status %d, want %d", url, resp.StatusCode, wantStatus)
+	}
+	return string(body), resp.Header
+}
+
+func TestHealthz(t *testing.T) {
+	server := newTestMetricsServer(t, NewBotMetrics())
+	if body, _ := getBody(t, server.URL+"/healthz", http.StatusOK); body != "ok" {
+		t.Errorf("body = %q, want ok", body)
+	}
+	getBody(t, server.URL+"/nope", http.StatusNotFound)
+}
+
+func TestStatusJSON(t *testing.T) {
+	m := NewBotMetrics()
+	poll := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.RecordPoll(poll)
+	m.RecordSignal("BTCUSDT", "42000.5", "BUY", 100)
+	m.RecordSignal("ETHUSDT", "2500", "HOLD", 99)
+	m.RecordNotifierError()
+	server := newTestMetricsServer(t, m)
+
+	body, header := getBody(t, server.URL+"/status", http.StatusOK)
+	if ct := header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content type = %q, want application/json", ct)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &fields); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+	for _, key := range []string{"start_time", "uptime_seconds", "last_poll", "symbols", "notifier_errors"} {
+		if _, exists := fields[key]; !exists {
+			t.Errorf("missing %q in %s", key, body)
+		}
+	}
+
+	var status MetricsSnapshot
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		symbol     string
+		wantPrice  string
+		wantSignal string
+	}{
+		{"BTCUSDT", "42000.5", "BUY"},
+		{"ETHUSDT", "2500", "HOLD"},
+	}
+	for _, tt := range tests {
+		got := status.Symbols[tt.symbol]
+		if got.LastPrice != tt.wantPrice || got.LastSignal != tt.wantSignal {
+			t.Errorf("%s = %s/%s, want %s/%s", tt.symbol, got.LastPrice, got.LastSignal, tt.wantPrice, tt.wantSignal)
+		}
+	}
+	if !status.LastPoll.Equal(poll) || status.NotifierErrors != 1 || status.UptimeSeconds < 0 {
+		t.Errorf("last poll %v, notifier errors %d, uptime %v", status.LastPoll, status.NotifierErrors, status.UptimeSeconds)
+	}
+}
+
+func TestMetricsWiring(t *testing.T) {
+	setGlobal(t, &botMetrics, NewBotMetrics())
+	setGlobal(t, &liveSpacing, newSignalSpacing(0, 0))
+	telegram := &telegramServer{delivered: make(chan map[string]string, 1)}
+	setGlobal(t, &telegramBot, newTestTelegramBot(t, telegram))
+
+	// One successful klines fetch, then a ticker request that fails every retry
+	bc := newTestBinanceClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/klines") {
+			(&klineServer{klines: testKlines(flatCloses(50, 100))}).ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	klines, err := bc.fetchKlines(context.Background(), "BTCUSDT", "15m", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.fetch24hrTickers(context.Background(), []string{"BTCUSDT"}); err == nil {
+		t.Fatal("expected the ticker request to fail")
+	}
+
+	// The last candle signals a BUY, which is sent to Telegram
+	series := klineSeries(klines)
+	useScript(t, map[int]string{series.LastIndex(): "BUY"})
+	processSignal("BTCUSDT", series, "100.00")
+	processSignal("ETHUSDT", klineSeries(klines[:20]), "2500.00")
+	select {
+	case <-telegram.delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("BUY signal not delivered")
+	}
+	// The sender counts the delivery once it reads the server's answer
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(metricsBody(t), "gotrading_notifications_sent_total 1") {
+		if time.Now().After(deadline) {
+			t.Fatal("delivery not counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server := newTestMetricsServer(t, botMetrics)
+	body, _ := getBody(t, server.URL+"/status", http.StatusOK)
+	var status MetricsSnapshot
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatal(err)
+	}
+	symbols := []struct {
+		symbol     string
+		wantPrice  string
+		wantSignal string
+		wantLength int
+	}{
+		{"BTCUSDT", "100.00", "BUY", 50},
+		{"ETHUSDT", "2500.00", "HOLD", 20},
+	}
+	for _, tt := range symbols {
+		got := status.Symbols[tt.symbol]
+		if got.LastPrice != tt.wantPrice || got.LastSignal != tt.wantSignal || got.SeriesLength != tt.wantLength {
+			t.Errorf("%s = %+v, want %s/%s over %d candles", tt.symbol, got, tt.wantPrice, tt.wantSignal, tt.wantLength)
+		}
+	}
+
+	metrics, header := getBody(t, server.URL+"/metrics", http.StatusOK)
+	if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type = %q, want text/plain", ct)
+	}
+	for _, want := range []string{
+		`gotrading_signals_total{symbol="BTCUSDT",signal="BUY"} 1`,
+		`gotrading_signals_total{symbol="ETHUSDT",signal="HOLD"} 1`,
+		`gotrading_series_candles{symbol="BTCUSDT"} 50`,
+		`gotrading_fetch_requests_total{endpoint="klines"} 1`,
+		`gotrading_fetch_errors_total{endpoint="klines"} 0`,
+		`gotrading_fetch_errors_total{endpoint="ticker/24hr"} 1`,
+		"gotrading_notifications_sent_total 1",
+		"gotrading_notifier_errors_total 0",
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("/metrics is missing %q:\n%s", want, metrics)
+		}
+	}
+}
+
+// metricsBody renders botMetrics in the Prometheus format
+func metricsBody(t *testing.T) string {
+	t.Helper()
+	var out strings.Builder
+	botMetrics.WritePrometheus(&out)
+	return out.String()
+}
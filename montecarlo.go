@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// MonteCarloStats summarizes the distribution of outcomes over resampled trade sequences
+type MonteCarloStats struct {
+	Runs           int
+	Trades         int        // Round trips drawn per run, the same as the original backtest
+	FinalReturnPct [3]float64 // 5th, 50th and 95th percentile of the final return, in percent
+	MaxDrawdownPct [3]float64 // 5th, 50th and 95th percentile of the max drawdown, in percent
+}
+
+// monteCarloPercentiles are the percentiles reported in MonteCarloStats
+var monteCarloPercentiles = [3]float64{5, 50, 95}
+
+// MonteCarloResample resamples the backtest's per-trade returns with replacement into runs
+// new sequences of the same length, compounds each from the initial balance, and reports the
+// spread of final return and max drawdown. Each trade's return is its P&L as a fraction of
+// the account value before it closed, so position sizing carries over. The same seed always
+// produces the same stats.
+func MonteCarloResample(result *BacktestResult, runs int, seed int64) MonteCarloStats {
+	stats := MonteCarloStats{Runs: runs, Trades: len(result.RoundTrips)}
+	if runs < 1 || len(result.RoundTrips) == 0 || result.InitialBalance <= 0 {
+		return stats
+	}
+
+	returns := make([]float64, len(result.RoundTrips))
+	equity := result.InitialBalance
+	for i, rt := range result.RoundTrips {
+		returns[i] = rt.PnL / equity
+		equity += rt.PnL
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	finalReturns := make([]float64, runs)
+	drawdowns := make([]float64, runs)
+	for run := 0; run < runs; run++ {
+		value, peak, maxDrawdown := 1.0, 1.0, 0.0
+		for range returns {
+			value *= 1 + returns[rng.Intn(len(returns))]
+			peak = math.Max(peak, value)
+			maxDrawdown = math.Max(maxDrawdown, (peak-value)/peak)
+		}
+		finalReturns[run] = (value - 1) * 100
+		drawdowns[run] = maxDrawdown * 100
+	}
+
+	sort.Float64s(finalReturns)
+	sort.Float64s(drawdowns)
+	for i, p := range monteCarloPercentiles {
+		stats.FinalReturnPct[i] = percentile(finalReturns, p)
+		stats.MaxDrawdownPct[i] = percentile(drawdowns, p)
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile (0-100) of sorted values, interpolating linearly
+// between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// PrintMonteCarloStats prints the percentile table of a Monte Carlo resample
+func PrintMonteCarloStats(stats MonteCarloStats) {
+	fmt.Printf("\n🎲 MONTE CARLO (%d runs of %d resampled trades)\n", stats.Runs, stats.Trades)
+	if stats.Trades == 0 || stats.Runs < 1 {
+		fmt.Println("   No completed trades to resample")
+		return
+	}
+	fmt.Printf("   %-14s %10s %10s %10s\n", "", "5th", "50th", "95th")
+	fmt.Printf("   %-14s %9.2f%% %9.2f%% %9.2f%%\n", "Final return",
+		stats.FinalReturnPct[0], stats.FinalReturnPct[1], stats.FinalReturnPct[2])
+	fmt.Printf("   %-14s %9.2f%% %9.2f%% %9.2f%%\n", "Max drawdown",
+		stats.MaxDrawdownPct[0], stats.MaxDrawdownPct[1], stats.MaxDrawdownPct[2])
+}
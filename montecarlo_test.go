@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+// monteCarloTestResult is a small backtest result: four round trips from $1000 of +10%,
+// -5%, +20% and -10% of the account value before each closed
+func monteCarloTestResult() *BacktestResult {
+	return &BacktestResult{
+		InitialBalance: 1000,
+		RoundTrips:     []RoundTrip{{PnL: 100}, {PnL: -55}, {PnL: 209}, {PnL: -125.4}},
+	}
+}
+
+func TestMonteCarloResampleSeeded(t *testing.T) {
+	tests := []struct {
+		seed            int64
+		wantFinalReturn [3]float64
+		wantMaxDrawdown [3]float64
+	}{
+		{1, [3]float64{-23.039313, 12.86, 59.72}, [3]float64{5, 10, 26.8975}},
+		{7, [3]float64{-23.039313, 6.92, 59.72}, [3]float64{5, 10, 26.8975}},
+	}
+	for _, tt := range tests {
+		stats := MonteCarloResample(monteCarloTestResult(), 200, tt.seed)
+		if again := MonteCarloResample(monteCarloTestResult(), 200, tt.seed); again != stats {
+			t.Errorf("seed %d: two runs differ: %v and %v", tt.seed, stats, again)
+		}
+		if stats.Runs != 200 || stats.Trades != 4 {
+			t.Errorf("seed %d: runs/trades = %d/%d, want 200/4", tt.seed, stats.Runs, stats.Trades)
+		}
+		for i := range monteCarloPercentiles {
+			if !approxEqual(stats.FinalReturnPct[i], tt.wantFinalReturn[i], 1e-6) {
+				t.Errorf("seed %d: final return p%v = %v, want %v", tt.seed,
+					monteCarloPercentiles[i], stats.FinalReturnPct[i], tt.wantFinalReturn[i])
+			}
+			if !approxEqual(stats.MaxDrawdownPct[i], tt.wantMaxDrawdown[i], 1e-6) {
+				t.Errorf("seed %d: max drawdown p%v = %v, want %v", tt.seed,
+					monteCarloPercentiles[i], stats.MaxDrawdownPct[i], tt.wantMaxDrawdown[i])
+			}
+		}
+	}
+}
+
+func TestMonteCarloResampleNothingToResample(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *BacktestResult
+		runs   int
+	}{
+		{"no trades", &BacktestResult{InitialBalance: 1000}, 100},
+		{"no runs", monteCarloTestResult(), 0},
+		{"no balance", &BacktestResult{RoundTrips: []RoundTrip{{PnL: 10}}}, 100},
+	}
+	for _, tt := range tests {
+		stats := MonteCarloResample(tt.result, tt.runs, 1)
+		if stats.FinalReturnPct != [3]float64{} || stats.MaxDrawdownPct != [3]float64{} {
+			t.Errorf("%s: got %v, want zero percentiles", tt.name, stats)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{50, 3},
+		{100, 5},
+		{10, 1.4},
+		{95, 4.8},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); !approxEqual(got, tt.want, 1e-9) {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of no values = %v, want 0", got)
+	}
+}
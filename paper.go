@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPaperStateFile is where the paper portfolio is saved when PAPER_STATE_FILE is unset
+const defaultPaperStateFile = "paper_portfolio.json"
+
+// defaultPaperReportInterval is how often the paper portfolio is reported when PAPER_REPORT_MINUTES is unset
+const defaultPaperReportInterval = time.Hour
+
+// paperTrader simulates live signals when PAPER_TRADING is enabled; nil otherwise
+var paperTrader *PaperTrader
+
+// PaperTrader executes live signals against an in-memory portfolio, reusing the backtest
+// engine's fills, fees and position sizing, and saves the state after every trade so a
+// restart resumes where it left off.
+type PaperTrader struct {
+	mu             sync.Mutex
+	engine         *BacktestEngine
+	initialBalance float64
+	stateFile      string
+}
+
+// paperState is the JSON file layout of a paper portfolio
+type paperState struct {
+	InitialBalance float64            `json:"initial_balance"`
+	Cash           float64            `json:"cash"`
+	Holdings       map[string]float64 `json:"holdings"`
+	LastPrices     map[string]float64 `json:"last_prices"`
+	AvgEntryPrice  map[string]float64 `json:"avg_entry_price"`
+	Trades         []Trade            `json:"trades"`
+}
+
+// NewPaperTrader creates a paper trader, resuming from stateFile when it exists. An empty
+// stateFile keeps the portfolio in memory only.
+func NewPaperTrader(config BacktestConfig, stateFile string) (*PaperTrader, error) {
+	pt := &PaperTrader{
+		engine:         NewBacktestEngine(config),
+		initialBalance: config.InitialBalance,
+		stateFile:      stateFile,
+	}
+	if stateFile == "" {
+		return pt, nil
+	}
+
+	data, err := os.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return pt, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading paper portfolio: %v", err)
+	}
+
+	var state paperState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error decoding paper portfolio %s: %v", stateFile, err)
+	}
+	pt.restore(state)
+	return pt, nil
+}
+
+// restore replaces the engine's portfolio and trade log with a saved state
+func (pt *PaperTrader) restore(state paperState) {
+	if state.InitialBalance > 0 {
+		pt.initialBalance = state.InitialBalance
+	}
+	pt.engine.portfolio = Portfolio{
+		Cash:          state.Cash,
+		Holdings:      state.Holdings,
+		LastPrices:    state.LastPrices,
+		AvgEntryPrice: state.AvgEntryPrice,
+	}
+	if pt.engine.portfolio.Holdings == nil {
+		pt.engine.portfolio.Holdings = make(map[string]float64)
+	}
+	if pt.engine.portfolio.LastPrices == nil {
+		pt.engine.portfolio.LastPrices = make(map[string]float64)
+	}
+	if pt.engine.portfolio.AvgEntryPrice == nil {
+		pt.engine.portfolio.AvgEntryPrice = make(map[string]float64)
+	}
+	pt.engine.trades = append(pt.engine.trades[:0], state.Trades...)
+}
+
+// OnSignal marks symbol to price and fills a BUY or SELL at it. It reports whether a trade
+// was executed; err is only set when saving the portfolio fails.
+func (pt *PaperTrader) OnSignal(symbol, action string, price float64, timestamp time.Time) (bool, error) {
+	if price <= 0 {
+		return false, nil
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.engine.portfolio.LastPrices[symbol] = price
+	if action != "BUY" && action != "SELL" {
+		return false, nil
+	}
+	if !pt.engine.ExecuteTrade(symbol, action, OrderTypeMarket, price, timestamp) {
+		return false, nil
+	}
+	return true, pt.save()
+}
+
+// save writes the portfolio to stateFile through a temporary file, so a crash mid-write
+// never leaves a truncated state behind
+func (pt *PaperTrader) save() error {
+	if pt.stateFile == "" {
+		return nil
+	}
+
+	portfolio := pt.engine.portfolio
+	data, err := json.MarshalIndent(paperState{
+		InitialBalance: pt.initialBalance,
+		Cash:           portfolio.Cash,
+		Holdings:       portfolio.Holdings,
+		LastPrices:     portfolio.LastPrices,
+		AvgEntryPrice:  portfolio.AvgEntryPrice,
+		Trades:         pt.engine.trades,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding paper portfolio: %v", err)
+	}
+
+	tmp := pt.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing paper portfolio: %v", err)
+	}
+	if err := os.Rename(tmp, pt.stateFile); err != nil {
+		return fmt.Errorf("error writing paper portfolio: %v", err)
+	}
+	return nil
+}
+
+// Summary describes the running balance and open positions at the last seen prices
+func (pt *PaperTrader) Summary() string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	portfolio := pt.engine.portfolio
+	value := pt.engine.GetPortfolioValue()
+	change := 0.0
+	if pt.initialBalance > 0 {
+		change = (value - pt.initialBalance) / pt.initialBalance * 100
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "💼 Valor: $%.2f (%+.2f%% desde $%.2f)\n", value, change, pt.initialBalance)
+	fmt.Fprintf(&b, "💵 Efectivo: $%.2f\n", portfolio.Cash)
+	fmt.Fprintf(&b, "🔁 Operaciones: %d\n", len(pt.engine.trades))
+
+	symbols := make([]string, 0, len(portfolio.Holdings))
+	for symbol := range portfolio.Holdings {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	if len(symbols) == 0 {
+		b.WriteString("📭 Sin posiciones abiertas")
+		return b.String()
+	}
+	b.WriteString("📂 Posiciones:")
+	for _, symbol := range symbols {
+		quantity := portfolio.Holdings[symbol]
+		entry := portfolio.AvgEntryPrice[symbol]
+		price := portfolio.LastPrices[symbol]
+		pnl := 0.0
+		if entry > 0 {
+			pnl = (price - entry) / entry * 100
+		}
+		fmt.Fprintf(&b, "\n• %s: %.6f @ $%.4f → $%.4f (%+.2f%%)", symbol, quantity, entry, price, pnl)
+	}
+	return b.String()
+}
+
+// reportPaperPortfolio logs the paper portfolio and sends it to Telegram when configured
+func reportPaperPortfolio() {
+	summary := paperTrader.Summary()
+	log.Printf("Paper trading:\n%s", summary)
+	if telegramBot != nil {
+		telegramBot.Enqueue("<b>📒 Paper Trading</b>\n\n" + summary)
+	}
+}
+
+// runPaperReports reports the paper portfolio every interval until ctx is cancelled
+func runPaperReports(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reportPaperPortfolio()
+		}
+	}
+}
+
+// newPaperTraderFromEnv builds the paper trader from PAPER_INITIAL_BALANCE, PAPER_POSITION_PCT
+// and PAPER_STATE_FILE, and returns the report interval from PAPER_REPORT_MINUTES
+func newPaperTraderFromEnv() (*PaperTrader, time.Duration, error) {
+	config := BacktestConfig{
+		InitialBalance: 10000,
+		TransactionFee: 0.001,
+		QuietSkips:     true,
+	}
+	if s := os.Getenv("PAPER_INITIAL_BALANCE"); s != "" {
+		balance, err := strconv.ParseFloat(s, 64)
+		if err != nil || balance <= 0 {
+			return nil, 0, fmt.Errorf("invalid PAPER_INITIAL_BALANCE %q", s)
+		}
+		config.InitialBalance = balance
+	}
+	if s := os.Getenv("PAPER_POSITION_PCT"); s != "" {
+		pct, err := strconv.ParseFloat(s, 64)
+		if err != nil || pct <= 0 || pct > 1 {
+			return nil, 0, fmt.Errorf("invalid PAPER_POSITION_PCT %q (expected a fraction in (0,1])", s)
+		}
+		config.PositionSizePct = pct
+	}
+
+	interval := defaultPaperReportInterval
+	if s := os.Getenv("PAPER_REPORT_MINUTES"); s != "" {
+		minutes, err := strconv.Atoi(s)
+		if err != nil || minutes <= 0 {
+			return nil, 0, fmt.Errorf("invalid PAPER_REPORT_MINUTES %q", s)
+		}
+		interval = time.Duration(minutes) * time.Minute
+	}
+
+	stateFile := os.Getenv("PAPER_STATE_FILE")
+	if stateFile == "" {
+		stateFile = defaultPaperStateFile
+	}
+
+	pt, err := NewPaperTrader(config, stateFile)
+	return pt, interval, err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// paperStep is one live signal fed to a paper trader
+type paperStep struct {
+	symbol       string
+	action       string
+	price        float64
+	wantExecuted bool
+}
+
+// runPaperSteps feeds steps to pt, failing the test when a step's outcome is unexpected
+func runPaperSteps(t *testing.T, pt *PaperTrader, steps []paperStep) {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, step := range steps {
+		executed, err := pt.OnSignal(step.symbol, step.action, step.price, start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if executed != step.wantExecuted {
+			t.Errorf("step %d (%s %s @ %.2f): executed = %v, want %v",
+				i, step.action, step.symbol, step.price, executed, step.wantExecuted)
+		}
+	}
+}
+
+// paperSteps buys BTC and ETH with half the cash each time, then sells the BTC at a profit
+var paperSteps = []paperStep{
+	{"BTCUSDT", "BUY", 100, true},
+	{"ETHUSDT", "BUY", 10, true},
+	{"BTCUSDT", "HOLD", 120, false},
+	{"BTCUSDT", "SELL", 110, true},
+	{"BTCUSDT", "SELL", 110, false},
+	{"DOGEUSDT", "SELL", 1, false},
+	{"ETHUSDT", "BUY", 0, false},
+}
+
+func TestPaperTraderOnSignal(t *testing.T) {
+	tests := []struct {
+		name         string
+		fee          float64
+		wantCash     float64
+		wantHoldings map[string]float64
+		wantValue    float64
+	}{
+		// 50 BTC for $5000, 250 ETH for $2500, 50 BTC sold for $5500
+		{"no fees", 0, 8000, map[string]float64{"ETHUSDT": 250}, 10500},
+		// Each fill pays 1% on top, so buys get slightly fewer units
+		{"1% fee", 0.01, 2500.0 + 5000.0/101*110*0.99, map[string]float64{"ETHUSDT": 2500.0 / 10.1},
+			2500.0 + 5000.0/101*110*0.99 + 2500.0/10.1*10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt, err := NewPaperTrader(BacktestConfig{InitialBalance: 10000, TransactionFee: tt.fee, PositionSizePct: 0.5, QuietSkips: true}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			runPaperSteps(t, pt, paperSteps)
+
+			portfolio := pt.engine.portfolio
+			if !approxEqual(portfolio.Cash, tt.wantCash, 1e-6) {
+				t.Errorf("cash = %.4f, want %.4f", portfolio.Cash, tt.wantCash)
+			}
+			if len(portfolio.Holdings) != len(tt.wantHoldings) {
+				t.Errorf("holdings = %v, want %v", portfolio.Holdings, tt.wantHoldings)
+			}
+			for symbol, want := range tt.wantHoldings {
+				if got := portfolio.Holdings[symbol]; !approxEqual(got, want, 1e-9) {
+					t.Errorf("%s holding = %.6f, want %.6f", symbol, got, want)
+				}
+			}
+			if value := pt.engine.GetPortfolioValue(); !approxEqual(value, tt.wantValue, 1e-6) {
+				t.Errorf("portfolio value = %.4f, want %.4f", value, tt.wantValue)
+			}
+			if len(pt.engine.trades) != 3 {
+				t.Errorf("recorded %d trades, want 3", len(pt.engine.trades))
+			}
+		})
+	}
+}
+
+func TestPaperTraderResumesFromFile(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "paper.json")
+	config := BacktestConfig{InitialBalance: 10000, PositionSizePct: 0.5, QuietSkips: true}
+	pt, err := NewPaperTrader(config, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runPaperSteps(t, pt, paperSteps)
+
+	// A restart with a different configured balance keeps the saved one
+	config.InitialBalance = 500
+	resumed, err := NewPaperTrader(config, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.initialBalance != 10000 {
+		t.Errorf("initial balance = %.2f, want the saved 10000", resumed.initialBalance)
+	}
+	// The state is saved on trades, so prices seen after the last one aren't restored
+	got, want := resumed.engine.portfolio, pt.engine.portfolio
+	if got.Cash != want.Cash || !reflect.DeepEqual(got.Holdings, want.Holdings) || !reflect.DeepEqual(got.AvgEntryPrice, want.AvgEntryPrice) {
+		t.Errorf("resumed portfolio %+v, want %+v", got, want)
+	}
+	if len(resumed.engine.trades) != len(pt.engine.trades) {
+		t.Errorf("resumed %d trades, want %d", len(resumed.engine.trades), len(pt.engine.trades))
+	}
+	if _, err := os.Stat(stateFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary state file left behind: %v", err)
+	}
+
+	// The resumed trader keeps trading from the saved state
+	runPaperSteps(t, resumed, []paperStep{{"ETHUSDT", "SELL", 12, true}})
+	if cash := resumed.engine.portfolio.Cash; !approxEqual(cash, 8000+250*12, 1e-9) {
+		t.Errorf("cash after selling the resumed ETH = %.2f, want %.2f", cash, 8000.0+250*12)
+	}
+
+	if err := os.WriteFile(stateFile, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPaperTrader(config, stateFile); err == nil {
+		t.Error("expected an error for a corrupt state file")
+	}
+}
+
+func TestPaperTraderSummary(t *testing.T) {
+	pt, err := NewPaperTrader(BacktestConfig{InitialBalance: 10000, PositionSizePct: 0.5, QuietSkips: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := pt.Summary(); !strings.Contains(summary, "Sin posiciones abiertas") {
+		t.Errorf("empty portfolio summary = %q", summary)
+	}
+
+	runPaperSteps(t, pt, paperSteps[:2])
+	runPaperSteps(t, pt, []paperStep{{"ETHUSDT", "HOLD", 12, false}})
+	summary := pt.Summary()
+	for _, want := range []string{
+		"Valor: $10500.00 (+5.00% desde $10000.00)",
+		"Efectivo: $2500.00",
+		"Operaciones: 2",
+		"• BTCUSDT: 50.000000 @ $100.0000 → $100.0000 (+0.00%)",
+		"• ETHUSDT: 250.000000 @ $10.0000 → $12.0000 (+20.00%)",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q is missing %q", summary, want)
+		}
+	}
+}
+
+func TestNewPaperTraderFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantBalance  float64
+		wantPct      float64
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{"defaults", nil, 10000, 0, time.Hour, false},
+		{"configured", map[string]string{"PAPER_INITIAL_BALANCE": "2500", "PAPER_POSITION_PCT": "0.25", "PAPER_REPORT_MINUTES": "15"},
+			2500, 0.25, 15 * time.Minute, false},
+		{"bad balance", map[string]string{"PAPER_INITIAL_BALANCE": "-1"}, 0, 0, 0, true},
+		{"position over 100%", map[string]string{"PAPER_POSITION_PCT": "1.5"}, 0, 0, 0, true},
+		{"bad report interval", map[string]string{"PAPER_REPORT_MINUTES": "0"}, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PAPER_INITIAL_BALANCE", "PAPER_POSITION_PCT", "PAPER_REPORT_MINUTES"} {
+				t.Setenv(key, tt.env[key])
+			}
+			t.Setenv("PAPER_STATE_FILE", filepath.Join(t.TempDir(), "paper.json"))
+
+			pt, interval, err := newPaperTraderFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if pt.initialBalance != tt.wantBalance || pt.engine.config.PositionSizePct != tt.wantPct || interval != tt.wantInterval {
+				t.Errorf("got balance %.2f, position %.2f, interval %v; want %.2f, %.2f, %v",
+					pt.initialBalance, pt.engine.config.PositionSizePct, interval, tt.wantBalance, tt.wantPct, tt.wantInterval)
+			}
+		})
+	}
+}
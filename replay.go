@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// replayBinance serves market data from Binance REST responses saved in a directory, so
+// backtests run offline and always see the same candles. Klines for a symbol and interval
+// are read from klines_<SYMBOL>_<interval>.json (a /api/v3/klines body) and tickers from
+// ticker_24hr.json (a /api/v3/ticker/24hr body).
+type replayBinance struct {
+	dir string
+}
+
+func newReplayBinance(dir string) *replayBinance {
+	return &replayBinance{dir: dir}
+}
+
+// fetchKlines returns the latest limit recorded klines, failing like the paged API when
+// fewer are recorded
+func (rb *replayBinance) fetchKlines(ctx context.Context, symbol string, interval string, limit int) ([]BinanceKline, error) {
+	path := filepath.Join(rb.dir, fmt.Sprintf("klines_%s_%s.json", strings.ToUpper(symbol), interval))
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading recorded klines: %v", err)
+	}
+	defer file.Close()
+
+	klines, err := decodeKlines(file, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(klines) < limit {
+		return nil, fmt.Errorf("only %d of %d requested klines recorded for %s %s",
+			len(klines), limit, symbol, interval)
+	}
+	return klines[len(klines)-limit:], nil
+}
+
+// fetch24hrTickers returns the recorded tickers of the requested symbols
+func (rb *replayBinance) fetch24hrTickers(ctx context.Context, symbols []string) (map[string]BinanceTicker, error) {
+	data, err := os.ReadFile(filepath.Join(rb.dir, "ticker_24hr.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading recorded tickers: %v", err)
+	}
+
+	var recorded []BinanceTicker
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("error decoding recorded tickers: %v", err)
+	}
+
+	wanted := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		wanted[strings.TrimSpace(symbol)] = true
+	}
+	tickers := make(map[string]BinanceTicker)
+	for _, ticker := range recorded {
+		if wanted[ticker.Symbol] {
+			tickers[ticker.Symbol] = ticker
+		}
+	}
+	return tickers, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// useReplayData points marketData at the fixtures in testdata for the rest of the test
+func useReplayData(t *testing.T) {
+	t.Helper()
+	useMarketData(t, newReplayBinance("testdata"))
+}
+
+// replayConfig is the backtest the replay tests start from: the default strategy on the
+// recorded BTCUSDT 15m candles
+func replayConfig() BacktestConfig {
+	return BacktestConfig{
+		Symbol:         "BTCUSDT",
+		InitialBalance: 10000,
+		TransactionFee: 0.001,
+		Interval:       "15m",
+		DataLimit:      500,
+		QuietSkips:     true,
+	}
+}
+
+// runReplayBacktest runs config against the testdata fixtures
+func runReplayBacktest(t *testing.T, config BacktestConfig) *BacktestResult {
+	t.Helper()
+	useReplayData(t)
+	result, err := NewBacktestEngine(config).RunBacktest(context.Background())
+	if err != nil {
+		t.Fatalf("RunBacktest: %v", err)
+	}
+	return result
+}
+
+func TestRunBacktestOnReplay(t *testing.T) {
+	tests := []struct {
+		name             string
+		positionSizePct  float64
+		wantTrades       int
+		wantFinalBalance float64
+		wantFinalValue   float64
+	}{
+		{"all-in", 0, 3, 0, 10195.764939},
+		{"half of cash per entry", 0.5, 8, 1241.994168, 10120.691648},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := replayConfig()
+			config.PositionSizePct = tt.positionSizePct
+			result := runReplayBacktest(t, config)
+			if result.TotalTrades != tt.wantTrades {
+				t.Errorf("trades = %d, want %d", result.TotalTrades, tt.wantTrades)
+			}
+			if !approxEqual(result.FinalBalance, tt.wantFinalBalance, 1e-4) {
+				t.Errorf("final balance = %.6f, want %.6f", result.FinalBalance, tt.wantFinalBalance)
+			}
+			if !approxEqual(result.FinalValue, tt.wantFinalValue, 1e-4) {
+				t.Errorf("final value = %.6f, want %.6f", result.FinalValue, tt.wantFinalValue)
+			}
+		})
+	}
+}
+
+// approxEqual reports whether a and b differ by at most tolerance
+func approxEqual(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestZeroFeeOnReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		fee      float64
+		slippage float64
+	}{
+		{"fees", 0.001, 0},
+		{"slippage", 0, 0.0005},
+		{"fees and slippage", 0.001, 0.0005},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := replayConfig()
+			config.PositionSizePct = 0.5
+			config.TransactionFee = tt.fee
+			config.SlippagePct = tt.slippage
+			costed := runReplayBacktest(t, config)
+			config.ZeroFee = true
+			ideal := runReplayBacktest(t, config)
+
+			if !ideal.ZeroFee {
+				t.Error("result isn't labeled as zero-fee")
+			}
+			if len(ideal.Trades) != len(costed.Trades) {
+				t.Fatalf("zero-fee run made %d trades, want the same %d", len(ideal.Trades), len(costed.Trades))
+			}
+			for i, trade := range ideal.Trades {
+				if trade.Fee != 0 {
+					t.Errorf("trade %d paid %v in fees", i, trade.Fee)
+				}
+				if trade.Type != costed.Trades[i].Type || !trade.Timestamp.Equal(costed.Trades[i].Timestamp) {
+					t.Errorf("trade %d is %s at %s, want %s at %s", i, trade.Type, trade.Timestamp,
+						costed.Trades[i].Type, costed.Trades[i].Timestamp)
+				}
+			}
+			if ideal.TotalReturnPct <= costed.TotalReturnPct {
+				t.Errorf("zero-fee return %.4f%% isn't above %.4f%% with costs", ideal.TotalReturnPct, costed.TotalReturnPct)
+			}
+		})
+	}
+}
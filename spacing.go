@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sdcoffey/techan"
+)
+
+// liveSpacing applies the minimum hold and cooldown to live signals; set up in main
+var liveSpacing = newSignalSpacing(0, 0)
+
+// signalSpacing suppresses live signals that would reverse the previous one too soon,
+// mirroring BacktestConfig.MinHoldPeriods and CooldownPeriods. A BUY counts as an entry and
+// a SELL as an exit; periods are counted in candles of the series the signal came from.
+type signalSpacing struct {
+	mu       sync.Mutex
+	minHold  int
+	cooldown int
+	last     map[string]spacedSignal // symbol -> last signal let through
+	acted    map[string]spacedSignal // symbol -> last BUY or SELL acted on
+}
+
+// spacedSignal is the last BUY or SELL let through for a symbol and the candle it came on
+type spacedSignal struct {
+	action      string
+	candleStart time.Time
+}
+
+func newSignalSpacing(minHold, cooldown int) *signalSpacing {
+	return &signalSpacing{
+		minHold:  minHold,
+		cooldown: cooldown,
+		last:     make(map[string]spacedSignal),
+		acted:    make(map[string]spacedSignal),
+	}
+}
+
+// Allow reports whether action may be acted on for symbol on candle, recording it when it
+// is a BUY or SELL that changes direction. A suppressed signal also returns how many
+// candles have elapsed and how many are required.
+func (s *signalSpacing) Allow(symbol, action string, candle *techan.Candle) (ok bool, elapsed, required int) {
+	if action != "BUY" && action != "SELL" {
+		return true, 0, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, exists := s.last[symbol]
+	if exists && last.action == action {
+		return true, 0, 0 // Same direction; the entry/exit candle stays the first one
+	}
+	if exists && candle.Period.Length() > 0 {
+		elapsed = int(candle.Period.Start.Sub(last.candleStart) / candle.Period.Length())
+		required = s.cooldown
+		if last.action == "BUY" {
+			required = s.minHold
+		}
+		if elapsed < required {
+			return false, elapsed, required
+		}
+	}
+
+	s.last[symbol] = spacedSignal{action: action, candleStart: candle.Period.Start}
+	return true, 0, 0
+}
+
+// Repeated reports whether action was already acted on for symbol on the same candle,
+// recording it otherwise. The forming candle is re-analyzed on every price update, so
+// without this a BUY or SELL would fire again on each tick until the candle closes.
+func (s *signalSpacing) Repeated(symbol, action string, candle *techan.Candle) bool {
+	if action != "BUY" && action != "SELL" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, exists := s.acted[symbol]
+	if exists && last.action == action && last.candleStart.Equal(candle.Period.Start) {
+		return true
+	}
+	s.acted[symbol] = spacedSignal{action: action, candleStart: candle.Period.Start}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sdcoffey/big"
+	"github.com/sdcoffey/techan"
+)
+
+// spacingTestCandle returns the 15m candle that starts n periods after start
+func spacingTestCandle(start time.Time, n int) *techan.Candle {
+	period := techan.NewTimePeriod(start.Add(time.Duration(n)*15*time.Minute), 15*time.Minute)
+	candle := techan.NewCandle(period)
+	candle.ClosePrice = big.NewDecimal(100)
+	return candle
+}
+
+func TestSignalSpacingAllow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		candle int
+		action string
+		want   bool
+	}
+	tests := []struct {
+		name     string
+		minHold  int
+		cooldown int
+		steps    []step
+	}{
+		{"disabled", 0, 0, []step{{0, "BUY", true}, {1, "SELL", true}, {2, "BUY", true}}},
+		{"min hold", 3, 0, []step{{0, "BUY", true}, {1, "SELL", false}, {2, "BUY", true}, {3, "SELL", true}}},
+		{"cooldown", 0, 2, []step{{0, "SELL", true}, {1, "BUY", false}, {2, "BUY", true}}},
+		{"hold is not spaced", 5, 5, []step{{0, "BUY", true}, {1, "HOLD", true}, {2, "SELL", false}}},
+		{"same direction repeats", 5, 0, []step{{0, "BUY", true}, {1, "BUY", true}, {4, "SELL", false}, {5, "SELL", true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spacing := newSignalSpacing(tt.minHold, tt.cooldown)
+			for _, s := range tt.steps {
+				if ok, _, _ := spacing.Allow("BTCUSDT", s.action, spacingTestCandle(start, s.candle)); ok != s.want {
+					t.Errorf("%s on candle %d: allowed = %v, want %v", s.action, s.candle, ok, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSignalSpacingRepeated(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		symbol string
+		candle int
+		action string
+		want   bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"same signal on the forming candle", []step{{"BTCUSDT", 0, "BUY", false}, {"BTCUSDT", 0, "BUY", true}, {"BTCUSDT", 0, "BUY", true}}},
+		{"next candle fires again", []step{{"BTCUSDT", 0, "BUY", false}, {"BTCUSDT", 1, "BUY", false}}},
+		{"direction change on the same candle", []step{{"BTCUSDT", 0, "BUY", false}, {"BTCUSDT", 0, "SELL", false}, {"BTCUSDT", 0, "BUY", false}}},
+		{"symbols are independent", []step{{"BTCUSDT", 0, "SELL", false}, {"ETHUSDT", 0, "SELL", false}, {"BTCUSDT", 0, "SELL", true}}},
+		{"hold and wait never repeat", []step{{"BTCUSDT", 0, "HOLD", false}, {"BTCUSDT", 0, "HOLD", false}, {"BTCUSDT", 0, "WAIT", false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spacing := newSignalSpacing(0, 0)
+			for i, s := range tt.steps {
+				if got := spacing.Repeated(s.symbol, s.action, spacingTestCandle(start, s.candle)); got != s.want {
+					t.Errorf("step %d, %s %s on candle %d: repeated = %v, want %v", i, s.symbol, s.action, s.candle, got, s.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// streamReconnectDelay is how long StreamKlines waits before reconnecting a dropped stream
+const streamReconnectDelay = 5 * time.Second
+
+// binanceKlineEvent is a kline stream frame. Every key Binance sends is declared: encoding/json
+// matches keys case-insensitively, so an undeclared "L" would otherwise land in "l".
+type binanceKlineEvent struct {
+	EventType string `json:"e"`
+	EventTime int64  `json:"E"`
+	Symbol    string `json:"s"`
+	Kline     struct {
+		OpenTime                 int64  `json:"t"`
+		CloseTime                int64  `json:"T"`
+		Symbol                   string `json:"s"`
+		Interval                 string `json:"i"`
+		FirstTradeID             int64  `json:"f"`
+		LastTradeID              int64  `json:"L"`
+		Open                     string `json:"o"`
+		Close                    string `json:"c"`
+		High                     string `json:"h"`
+		Low                      string `json:"l"`
+		Volume                   string `json:"v"`
+		NumberOfTrades           int    `json:"n"`
+		Closed                   bool   `json:"x"`
+		QuoteAssetVolume         string `json:"q"`
+		TakerBuyBaseAssetVolume  string `json:"V"`
+		TakerBuyQuoteAssetVolume string `json:"Q"`
+		Ignore                   string `json:"B"`
+	} `json:"k"`
+}
+
+// parseKlineEvent decodes a kline stream frame; closed reports whether the candle is final
+func parseKlineEvent(data []byte) (kline BinanceKline, closed bool, err error) {
+	var event binanceKlineEvent
+	if err = json.Unmarshal(data, &event); err != nil {
+		return kline, false, fmt.Errorf("error decoding kline event: %v", err)
+	}
+	if event.EventType != "kline" {
+		return kline, false, fmt.Errorf("unexpected stream event: %q", event.EventType)
+	}
+
+	k := event.Kline
+	kline = BinanceKline{
+		OpenTime:                 k.OpenTime,
+		Open:                     k.Open,
+		High:                     k.High,
+		Low:                      k.Low,
+		Close:                    k.Close,
+		Volume:                   k.Volume,
+		CloseTime:                k.CloseTime,
+		QuoteAssetVolume:         k.QuoteAssetVolume,
+		NumberOfTrades:           k.NumberOfTrades,
+		TakerBuyBaseAssetVolume:  k.TakerBuyBaseAssetVolume,
+		TakerBuyQuoteAssetVolume: k.TakerBuyQuoteAssetVolume,
+		Ignore:                   k.Ignore,
+	}
+	return kline, k.Closed, nil
+}
+
+// StreamKlines subscribes to symbol's kline stream and sends every closed candle to out.
+// Dropped connections are retried until ctx is cancelled, which is the only way it returns.
+func (bc *BinanceClient) StreamKlines(ctx context.Context, symbol, interval string, out chan<- BinanceKline) error {
+	streamURL := fmt.Sprintf("%s/ws/%s@kline_%s", bc.streamURL, strings.ToLower(symbol), interval)
+
+	for {
+		if err := bc.streamKlinesOnce(ctx, streamURL, out); err != nil && ctx.Err() == nil {
+			log.Printf("Stream de klines para %s interrumpido: %v (reconectando en %v)", symbol, err, streamReconnectDelay)
+		}
+		if !sleepContext(ctx, streamReconnectDelay) {
+			return ctx.Err()
+		}
+	}
+}
+
+// streamKlinesOnce reads one stream connection until it fails or ctx is cancelled
+func (bc *BinanceClient) streamKlinesOnce(ctx context.Context, streamURL string, out chan<- BinanceKline) error {
+	ws, err := dialWebSocket(ctx, streamURL)
+	if err != nil {
+		return err
+	}
+
+	// Unblock ReadMessage when ctx is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		ws.Close()
+	}()
+
+	for {
+		data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		kline, closed, err := parseKlineEvent(data)
+		if err != nil {
+			log.Printf("Evento de stream ignorado: %v", err)
+			continue
+		}
+		if !closed {
+			continue
+		}
+
+		select {
+		case out <- kline:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// streamedKline is a closed candle tagged with the symbol it belongs to
+type streamedKline struct {
+	symbol string
+	kline  BinanceKline
+}
+
+// runStreamLoop replaces ticker polling with the kline streams: each closed candle replaces
+// the matching candle in seriesMap (or is appended) and the strategy is evaluated on it.
+// It returns when ctx is cancelled.
+func runStreamLoop(ctx context.Context, symbols []string, interval string, period time.Duration) {
+	merged := make(chan streamedKline)
+
+	for _, symbol := range symbols {
+		symbol = strings.TrimSpace(symbol)
+		klines := make(chan BinanceKline)
+		go binanceClient.StreamKlines(ctx, symbol, interval, klines)
+		go func(symbol string, klines <-chan BinanceKline) {
+			for {
+				select {
+				case kline := <-klines:
+					select {
+					case merged <- streamedKline{symbol: symbol, kline: kline}:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(symbol, klines)
+	}
+
+	log.Printf("Escuchando velas cerradas de %s por WebSocket...", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sk := <-merged:
+			botMetrics.RecordPoll(time.Now())
+
+			ts := seriesMap[sk.symbol]
+			if ts == nil {
+				log.Printf("No hay datos históricos para %s", sk.symbol)
+				continue
+			}
+
+			replaceCandlePeriod(ts, klineToCandle(sk.kline, period))
+			trimSeries(ts, maxSeriesCandles)
+			processSignal(sk.symbol, ts, sk.kline.Close)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestGenerateSyntheticKlines(t *testing.T) {
+	tests := []struct {
+		regime    string
+		wantDrift int // Sign of the last close relative to the first open
+	}{
+		{"trend_up", 1},
+		{"trend_down", -1},
+		{"mean_reverting", 0},
+		{"chop", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.regime, func(t *testing.T) {
+			klines, err := generateSyntheticKlines(tt.regime, 500, 15, 42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(klines) != 500 {
+				t.Fatalf("got %d klines, want 500", len(klines))
+			}
+			again, _ := generateSyntheticKlines(tt.regime, 500, 15, 42)
+			if !reflect.DeepEqual(klines, again) {
+				t.Error("the same seed gave different klines")
+			}
+			for i, kline := range klines {
+				open, _ := strconv.ParseFloat(kline.Open, 64)
+				high, _ := strconv.ParseFloat(kline.High, 64)
+				low, _ := strconv.ParseFloat(kline.Low, 64)
+				close, _ := strconv.ParseFloat(kline.Close, 64)
+				if low > open || low > close || high < open || high < close || low <= 0 {
+					t.Fatalf("kline %d has an inconsistent range: %+v", i, kline)
+				}
+				if i > 0 && kline.OpenTime-klines[i-1].OpenTime != 15*60*1000 {
+					t.Fatalf("kline %d is not 15m after the previous one", i)
+				}
+			}
+
+			first, _ := strconv.ParseFloat(klines[0].Open, 64)
+			last, _ := strconv.ParseFloat(klines[len(klines)-1].Close, 64)
+			if tt.wantDrift > 0 && last <= first*1.5 || tt.wantDrift < 0 && last >= first/1.5 {
+				t.Errorf("%s went from %.2f to %.2f", tt.regime, first, last)
+			}
+		})
+	}
+
+	if _, err := generateSyntheticKlines("sideways", 10, 15, 1); err == nil {
+		t.Error("expected an error for an unknown regime")
+	}
+}
+
+func TestRunStressTest(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	config := replayConfig()
+	var results map[string]*BacktestResult
+	captureStdout(t, func() { results = runStressTest(config, 42) })
+
+	for _, regime := range syntheticRegimes {
+		if results[regime] == nil {
+			t.Fatalf("no result for %s", regime)
+		}
+	}
+
+	// The default EMA crossover follows trends: it should ride trend_up, mostly stay out of
+	// trend_down, and get whipsawed in chop
+	chop := results["chop"].TotalReturnPct
+	for _, regime := range []string{"trend_up", "trend_down"} {
+		if got := results[regime].TotalReturnPct; got <= chop {
+			t.Errorf("%s returned %.2f%%, want more than chop's %.2f%%", regime, got, chop)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramPollTimeout is how long each getUpdates long poll waits for new messages
+const telegramPollTimeout = 30 * time.Second
+
+// telegramUpdate is the subset of a getUpdates result the command listener reads
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// ListenCommands long-polls getUpdates and answers commands such as "/price BTCUSDT": the
+// handler registered for the command (keys include the slash) is called with the remaining
+// words and its reply is sent back. Messages from other chats than the configured one are
+// ignored. It runs until ctx is cancelled.
+func (tb *TelegramBot) ListenCommands(ctx context.Context, handlers map[string]func(args []string) string) error {
+	client := &http.Client{Timeout: telegramPollTimeout + 10*time.Second}
+	var offset int64
+
+	for {
+		updates, err := tb.getUpdates(ctx, client, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Error leyendo comandos de Telegram: %v", err)
+			if !sleepContext(ctx, 5*time.Second) {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil || strconv.FormatInt(update.Message.Chat.ID, 10) != tb.chatID {
+				continue
+			}
+
+			reply := dispatchCommand(update.Message.Text, handlers)
+			if reply == "" {
+				continue
+			}
+			if err := tb.sendMessage(reply); err != nil {
+				botMetrics.RecordNotifierError()
+				log.Printf("Error respondiendo comando de Telegram: %v", err)
+			}
+		}
+	}
+}
+
+// getUpdates fetches the updates after offset, waiting up to telegramPollTimeout for one
+func (tb *TelegramBot) getUpdates(ctx context.Context, client *http.Client, offset int64) ([]telegramUpdate, error) {
+	params := url.Values{}
+	params.Set("offset", strconv.FormatInt(offset, 10))
+	params.Set("timeout", strconv.Itoa(int(telegramPollTimeout.Seconds())))
+	params.Set("allowed_updates", `["message"]`)
+	apiURL := fmt.Sprintf("%s/bot%s/getUpdates?%s", tb.apiURL, tb.botToken, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching telegram updates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("telegram API returned status code: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		OK     bool             `json:"ok"`
+		Result []telegramUpdate `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error decoding telegram updates: %v", err)
+	}
+	if !body.OK {
+		return nil, fmt.Errorf("telegram getUpdates returned ok=false")
+	}
+	return body.Result, nil
+}
+
+// dispatchCommand runs the handler for a "/command arg..." message. Plain text gets no
+// reply; unknown commands get the list of known ones.
+func dispatchCommand(text string, handlers map[string]func(args []string) string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return ""
+	}
+
+	// In groups commands may be addressed as /command@BotName
+	command := strings.ToLower(strings.SplitN(fields[0], "@", 2)[0])
+	if handler, ok := handlers[command]; ok {
+		return handler(fields[1:])
+	}
+
+	commands := make([]string, 0, len(handlers))
+	for name := range handlers {
+		commands = append(commands, name)
+	}
+	sort.Strings(commands)
+	return "Comando desconocido. Disponibles: " + strings.Join(commands, ", ")
+}
+
+// telegramCommandHandlers returns the live bot's /status, /price and /backtest commands
+func telegramCommandHandlers(ctx context.Context) map[string]func(args []string) string {
+	return map[string]func(args []string) string{
+		"/status": func(args []string) string {
+			snapshot := botMetrics.Snapshot()
+			symbols := make([]string, 0, len(snapshot.Symbols))
+			for symbol := range snapshot.Symbols {
+				symbols = append(symbols, symbol)
+			}
+			sort.Strings(symbols)
+
+			msg := fmt.Sprintf("🤖 <b>Estado del bot</b>\n⏱️ Activo hace %s\n", time.Duration(snapshot.UptimeSeconds*float64(time.Second)).Round(time.Second))
+			if !snapshot.LastPoll.IsZero() {
+				msg += fmt.Sprintf("🔄 Última consulta: %s\n", snapshot.LastPoll.Format("15:04:05"))
+			}
+			for _, symbol := range symbols {
+				s := snapshot.Symbols[symbol]
+				msg += fmt.Sprintf("• %s: $%s → %s\n", symbol, s.LastPrice, s.LastSignal)
+			}
+			return msg
+		},
+		"/price": func(args []string) string {
+			if len(args) != 1 {
+				return "Uso: /price SYMBOL"
+			}
+			symbol := strings.ToUpper(args[0])
+			tickers, err := marketData.fetch24hrTickers(ctx, []string{symbol})
+			if err != nil {
+				return fmt.Sprintf("Error obteniendo precio de %s: %v", symbol, err)
+			}
+			if _, ok := tickers[symbol]; !ok {
+				return fmt.Sprintf("No se encontró precio para %s", symbol)
+			}
+			return formatPriceUpdate([]string{symbol}, tickers)
+		},
+		"/backtest": func(args []string) string {
+			if len(args) != 1 {
+				return "Uso: /backtest SYMBOL"
+			}
+			symbol := strings.ToUpper(args[0])
+			engine := NewBacktestEngine(BacktestConfig{
+				Symbol:          symbol,
+				InitialBalance:  10000,
+				TransactionFee:  0.001,
+				Interval:        liveInterval,
+				DataLimit:       500,
+				QuietSkips:      true,
+				MinHoldPeriods:  liveSpacing.minHold,
+				CooldownPeriods: liveSpacing.cooldown,
+			})
+			result, err := engine.RunBacktest(ctx)
+			if err != nil {
+				return fmt.Sprintf("Backtest de %s falló: %v", symbol, err)
+			}
+			return fmt.Sprintf("📈 <b>Backtest %s</b> (500 velas %s)\nRetorno: %.2f%% (buy & hold %.2f%%)\nSharpe: %.2f | Max DD: %.2f%%\nTrades: %d | Win rate: %.1f%%",
+				symbol, liveInterval, result.TotalReturnPct, result.BuyAndHoldReturnPct, result.SharpeRatio,
+				result.MaxDrawdownPct, result.TotalTrades, result.WinRate)
+		},
+	}
+}
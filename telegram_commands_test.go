@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// telegramServer is a fake Bot API. getUpdates serves each entry of updates (a JSON result
+// array) once, then long-polls until the request is cancelled. sendMessage answers the next
+// scripted status in sendStatuses (200 once they run out) and records every payload.
+type telegramServer struct {
+	mu           sync.Mutex
+	updates      []string
+	sendStatuses []int
+	polls        []url.Values
+	sent         []map[string]string
+	delivered    chan map[string]string // Receives every payload answered with 200
+}
+
+func (ts *telegramServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/botTOKEN/getUpdates":
+		ts.mu.Lock()
+		ts.polls = append(ts.polls, r.URL.Query())
+		var result string
+		if len(ts.updates) > 0 {
+			result, ts.updates = ts.updates[0], ts.updates[1:]
+		}
+		ts.mu.Unlock()
+		if result == "" {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+
+	case "/botTOKEN/sendMessage":
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		ts.mu.Lock()
+		ts.sent = append(ts.sent, payload)
+		status := http.StatusOK
+		if len(ts.sendStatuses) > 0 {
+			status, ts.sendStatuses = ts.sendStatuses[0], ts.sendStatuses[1:]
+		}
+		ts.mu.Unlock()
+		w.WriteHeader(status)
+		if status == http.StatusTooManyRequests {
+			io.WriteString(w, `{"ok":false,"error_code":429,"parameters":{"retry_after":1}}`)
+			return
+		}
+		io.WriteString(w, `{"ok":true}`)
+		if status == http.StatusOK && ts.delivered != nil {
+			ts.delivered <- payload
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestTelegramBot returns a bot for chat 42 pointed at server, with no gap between
+// queued messages
+func newTestTelegramBot(t *testing.T, server *telegramServer) *TelegramBot {
+	t.Helper()
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	tb := NewTelegramBot("TOKEN", "42")
+	tb.apiURL = httpServer.URL
+	tb.sendInterval = 0
+	return tb
+}
+
+func TestListenCommands(t *testing.T) {
+	server := &telegramServer{
+		updates: []string{`[
+			{"update_id":7,"message":{"text":"/price BTCUSDT","chat":{"id":42}}},
+			{"update_id":8,"message":{"text":"/status","chat":{"id":99}}},
+			{"update_id":9,"message":{"text":"hola","chat":{"id":42}}},
+			{"update_id":10}
+		]`},
+		delivered: make(chan map[string]string, 10),
+	}
+	tb := newTestTelegramBot(t, server)
+
+	var mu sync.Mutex
+	calls := make(map[string][][]string)
+	handler := func(name string) func(args []string) string {
+		return func(args []string) string {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[name] = append(calls[name], args)
+			return name + " reply"
+		}
+	}
+	handlers := map[string]func(args []string) string{
+		"/price":  handler("/price"),
+		"/status": handler("/status"),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan error, 1)
+	go func() { returned <- tb.ListenCommands(ctx, handlers) }()
+
+	select {
+	case reply := <-server.delivered:
+		if reply["chat_id"] != "42" || reply["text"] != "/price reply" {
+			t.Errorf("sent %v, want the /price reply to chat 42", reply)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply sent")
+	}
+
+	// Wait for the next poll, which must acknowledge every update seen so far
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		server.mu.Lock()
+		polls := len(server.polls)
+		server.mu.Unlock()
+		if polls >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-returned:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ListenCommands returned %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenCommands didn't return after cancel")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := map[string][][]string{"/price": {{"BTCUSDT"}}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("handlers called with %v, want %v", calls, want)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.sent) != 1 {
+		t.Errorf("sent %d messages, want only the /price reply", len(server.sent))
+	}
+	if len(server.polls) < 2 {
+		t.Fatalf("polled %d times, want a second poll after the updates", len(server.polls))
+	}
+	if offset := server.polls[1].Get("offset"); offset != "11" {
+		t.Errorf("second poll offset = %s, want 11", offset)
+	}
+	if timeout := server.polls[0].Get("timeout"); timeout != "30" {
+		t.Errorf("poll timeout = %s, want 30", timeout)
+	}
+}
+
+func TestDispatchCommand(t *testing.T) {
+	handlers := map[string]func(args []string) string{
+		"/price": func(args []string) string { return "price " + strings.Join(args, "|") },
+		"/status": func(args []string) string {
+			return fmt.Sprintf("status %d", len(args))
+		},
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"/price BTCUSDT", "price BTCUSDT"},
+		{"  /price   ethusdt  extra ", "price ethusdt|extra"},
+		{"/PRICE BTCUSDT", "price BTCUSDT"},
+		{"/status@goTradingBot", "status 0"},
+		{"/unknown", "Comando desconocido. Disponibles: /price, /status"},
+		{"price BTCUSDT", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := dispatchCommand(tt.text, handlers); got != tt.want {
+			t.Errorf("dispatchCommand(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPriceCommand(t *testing.T) {
+	useMarketData(t, &fakeMarketData{tickers: map[string]BinanceTicker{
+		"BTCUSDT": {Symbol: "BTCUSDT", LastPrice: "42000.00", PriceChange: "630.00"},
+	}})
+	price := telegramCommandHandlers(context.Background())["/price"]
+
+	tests := []struct {
+		args []string
+		want string // Substring of the reply
+	}{
+		{[]string{"btcusdt"}, "🟢 <b>BTCUSDT</b>: $42000.00"},
+		{[]string{"ETHUSDT"}, "No se encontró precio para ETHUSDT"},
+		{nil, "Uso: /price SYMBOL"},
+		{[]string{"BTCUSDT", "ETHUSDT"}, "Uso: /price SYMBOL"},
+	}
+	for _, tt := range tests {
+		if got := price(tt.args); !strings.Contains(got, tt.want) {
+			t.Errorf("/price %v = %q, want it to contain %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestBacktestCommand(t *testing.T) {
+	klines, err := generateSyntheticKlines("trend_up", 500, 15, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useMarketData(t, &fakeMarketData{klines: map[string][]BinanceKline{liveInterval: klines}})
+	withStrategy(t, DefaultStrategyConfig())
+	backtest := telegramCommandHandlers(context.Background())["/backtest"]
+
+	tests := []struct {
+		args []string
+		want string // Substring of the reply
+	}{
+		{[]string{"btcusdt"}, "📈 <b>Backtest BTCUSDT</b> (500 velas 15m)"},
+		{nil, "Uso: /backtest SYMBOL"},
+	}
+	for _, tt := range tests {
+		if got := backtest(tt.args); !strings.Contains(got, tt.want) {
+			t.Errorf("/backtest %v = %q, want it to contain %q", tt.args, got, tt.want)
+		}
+	}
+
+	// A symbol without data reports the failure instead of a result
+	useMarketData(t, &fakeMarketData{})
+	if got := backtest([]string{"NOPEUSDT"}); !strings.HasPrefix(got, "Backtest de NOPEUSDT falló:") {
+		t.Errorf("/backtest without data = %q, want the failure", got)
+	}
+}
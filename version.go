@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Build metadata, injected at build time:
+//
+//	go build -ldflags "-X main.Version=v1.2.0 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// versionString describes the running build on one line
+func versionString() string {
+	return fmt.Sprintf("goTrading %s (commit %s, built %s)", Version, Commit, BuildDate)
+}
+
+// printVersion writes the build metadata for -version
+func printVersion(w io.Writer) {
+	fmt.Fprintln(w, versionString())
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintVersion(t *testing.T) {
+	setGlobal(t, &Version, "v1.2.0")
+	setGlobal(t, &Commit, "abc1234")
+	setGlobal(t, &BuildDate, "2024-01-01T00:00:00Z")
+
+	var out bytes.Buffer
+	printVersion(&out)
+	if want := "goTrading v1.2.0 (commit abc1234, built 2024-01-01T00:00:00Z)\n"; out.String() != want {
+		t.Errorf("printVersion wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"live bot", []string{"-version"}},
+		// -version wins over the options that would start a backtest
+		{"backtest CLI", []string{"-backtest", "-version", "-replay=testdata"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Only the version line is printed; the bot would log its start and the
+			// backtest its header before doing any work
+			stdout, stderr, code := runMain(t, tt.args...)
+			if code != 0 || stdout != versionString()+"\n" {
+				t.Errorf("exit code %d, stdout %q; want 0 and only %q\nstderr:\n%s", code, stdout, versionString()+"\n", stderr)
+			}
+			if strings.Contains(stderr, "Iniciando bot") {
+				t.Errorf("the trading loop started:\n%s", stderr)
+			}
+		})
+	}
+}
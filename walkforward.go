@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// walkForwardGrid holds the EMA short/long periods tried on each training segment
+var walkForwardGrid = [][2]int{{5, 13}, {9, 21}, {12, 26}, {20, 50}}
+
+// walkForwardObjective is the default rank metric maximized on each training segment
+const walkForwardObjective = "sharpe"
+
+// RunWalkForward fetches the configured candle history and walk-forward tests the strategy:
+// the data is split into windows rolling train/test segments, the EMA periods are optimized
+// on each train segment and then evaluated out-of-sample on the test segment that follows.
+// objective is the rank metric (see rankScores) the optimizer maximizes. It returns one
+// out-of-sample result per window, in chronological order.
+func RunWalkForward(ctx context.Context, config BacktestConfig, windows int, trainRatio float64, objective string) ([]BacktestResult, error) {
+	klines, err := marketData.fetchKlines(ctx, config.Symbol, config.Interval, config.DataLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching historical data: %v", err)
+	}
+	return runWalkForwardOnKlines(config, klines, windows, trainRatio, objective)
+}
+
+// runWalkForwardOnKlines runs the walk-forward analysis over already-loaded klines. Test
+// segments are contiguous and non-overlapping; each train segment ends where its test
+// segment begins.
+func runWalkForwardOnKlines(config BacktestConfig, klines []BinanceKline, windows int, trainRatio float64, objective string) ([]BacktestResult, error) {
+	if windows < 1 {
+		return nil, fmt.Errorf("walk-forward needs at least one window, got %d", windows)
+	}
+	if trainRatio <= 0 || trainRatio >= 1 {
+		return nil, fmt.Errorf("train ratio must be between 0 and 1, got %v", trainRatio)
+	}
+
+	// trainLen + windows*testLen covers the data with trainLen/(trainLen+testLen) ≈ trainRatio
+	testLen := int(float64(len(klines)) / (float64(windows) + trainRatio/(1-trainRatio)))
+	trainLen := len(klines) - windows*testLen
+	if maxWarmup := walkForwardMaxWarmup(); testLen < 1 || trainLen <= maxWarmup {
+		return nil, fmt.Errorf("not enough candles for %d walk-forward windows: got %d, each train segment needs more than %d",
+			windows, len(klines), maxWarmup)
+	}
+
+	// The optimizer swaps the global strategy; restore it when done
+	base := Strategy
+	defer func() { Strategy = base }()
+
+	results := make([]BacktestResult, 0, windows)
+	for w := 0; w < windows; w++ {
+		trainStart := w * testLen
+		trainEnd := trainStart + trainLen
+		testEnd := trainEnd + testLen
+
+		best, err := optimizeStrategy(config, base, klines[trainStart:trainEnd], objective)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %v", w+1, err)
+		}
+
+		// Start the test run one warmup early so trading begins exactly at the test segment
+		Strategy = best
+		engine := NewBacktestEngine(config)
+		result, err := engine.RunBacktestOnKlines(klines[trainEnd-best.Warmup() : testEnd])
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %v", w+1, err)
+		}
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+// optimizeStrategy returns the grid candidate with the best objective over klines
+func optimizeStrategy(config BacktestConfig, base StrategyConfig, klines []BinanceKline, objective string) (StrategyConfig, error) {
+	var candidates []StrategyConfig
+	var results []*BacktestResult
+
+	for _, periods := range walkForwardGrid {
+		candidate := base
+		candidate.EMAShort, candidate.EMALong = periods[0], periods[1]
+		if candidate.Validate() != nil {
+			continue
+		}
+
+		Strategy = candidate
+		engine := NewBacktestEngine(config)
+		result, err := engine.RunBacktestOnKlines(klines)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate)
+		results = append(results, result)
+	}
+
+	if len(candidates) == 0 {
+		return StrategyConfig{}, fmt.Errorf("no parameter set could be evaluated on the training segment")
+	}
+
+	// Scores are computed together so the composite metric can normalize across candidates
+	scores, err := rankScores(results, objective)
+	if err != nil {
+		return StrategyConfig{}, err
+	}
+	best := 0
+	for i, score := range scores {
+		if score > scores[best] {
+			best = i
+		}
+	}
+	return candidates[best], nil
+}
+
+// walkForwardMaxWarmup returns the largest warmup any grid candidate needs
+func walkForwardMaxWarmup() int {
+	maxWarmup := 0
+	for _, periods := range walkForwardGrid {
+		candidate := Strategy
+		candidate.EMAShort, candidate.EMALong = periods[0], periods[1]
+		if warmup := candidate.Warmup(); warmup > maxWarmup {
+			maxWarmup = warmup
+		}
+	}
+	return maxWarmup
+}
+
+// printWalkForwardSummary prints each out-of-sample window, the compounded equity across
+// windows and how stable the chosen parameters were
+func printWalkForwardSummary(results []BacktestResult) {
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Println("                       WALK-FORWARD ANALYSIS (out-of-sample)")
+	fmt.Println(strings.Repeat("=", 80))
+
+	fmt.Printf("%-8s %-10s %-12s %-12s %-10s %-8s\n",
+		"Window", "EMA", "Return %", "Buy&Hold %", "Sharpe", "Trades")
+	fmt.Println(strings.Repeat("-", 80))
+
+	combined := 1.0
+	chosen := make(map[string]int)
+	for i, result := range results {
+		params := fmt.Sprintf("%d/%d", result.Strategy.EMAShort, result.Strategy.EMALong)
+		chosen[params]++
+		combined *= 1 + result.TotalReturnPct/100
+
+		fmt.Printf("%-8d %-10s %11.2f%% %11.2f%% %10.3f %8d\n",
+			i+1, params, result.TotalReturnPct, result.BuyAndHoldReturnPct,
+			result.SharpeRatio, result.TotalTrades)
+	}
+
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("Combined out-of-sample return: %.2f%%\n", (combined-1)*100)
+
+	mostChosen, count := "", 0
+	for params, n := range chosen {
+		if n > count || (n == count && params < mostChosen) {
+			mostChosen, count = params, n
+		}
+	}
+	if len(results) > 0 {
+		fmt.Printf("Parameter stability: EMA %s chosen in %d of %d windows (%d distinct sets)\n",
+			mostChosen, count, len(results), len(chosen))
+	}
+	fmt.Println(strings.Repeat("=", 80))
+}
+
+// combinedEquityCurve chains the windows' equity curves, compounding each window from the
+// previous window's ending value
+func combinedEquityCurve(results []BacktestResult, initialBalance float64) []float64 {
+	curve := make([]float64, 0)
+	scale := 1.0
+	for _, result := range results {
+		if result.InitialBalance <= 0 {
+			continue
+		}
+		for _, value := range result.EquityCurve {
+			curve = append(curve, value/result.InitialBalance*initialBalance*scale)
+		}
+		scale *= result.FinalValue / result.InitialBalance
+	}
+	return curve
+}
+
+// runWalkForwardCLI runs the walk-forward analysis and prints its summary
+func runWalkForwardCLI(ctx context.Context, config BacktestConfig, windows int, trainRatio float64, objective string) {
+	fmt.Printf("🚶 Walk-forward analysis for %s: %d windows, %.0f%% train, optimizing %s\n",
+		config.Symbol, windows, trainRatio*100, objective)
+
+	results, err := RunWalkForward(ctx, config, windows, trainRatio, objective)
+	if err != nil {
+		log.Fatalf("Walk-forward failed: %v", err)
+	}
+
+	printWalkForwardSummary(results)
+
+	curve := combinedEquityCurve(results, config.InitialBalance)
+	if len(curve) > 0 {
+		fmt.Printf("Combined equity: $%.2f → $%.2f over %d candles\n",
+			config.InitialBalance, curve[len(curve)-1], len(curve))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestRunWalkForwardOnKlines(t *testing.T) {
+	withStrategy(t, DefaultStrategyConfig())
+	klines, err := generateSyntheticKlines("mean_reverting", 400, 15, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := BacktestConfig{Symbol: "TESTUSDT", InitialBalance: 10000, TransactionFee: 0.001, Interval: "15m", QuietSkips: true}
+
+	tests := []struct {
+		name       string
+		windows    int
+		trainRatio float64
+		wantErr    bool
+	}{
+		{"one window", 1, 0.7, false},
+		{"four windows", 4, 0.6, false},
+		{"no windows", 0, 0.7, true},
+		{"train ratio of one", 2, 1, true},
+		{"too many windows for the data", 50, 0.7, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := runWalkForwardOnKlines(config, klines, tt.windows, tt.trainRatio, "sharpe")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %d results", len(results))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != tt.windows {
+				t.Fatalf("got %d out-of-sample results, want %d", len(results), tt.windows)
+			}
+
+			// Test segments are the same length and end exactly at the last candle
+			testLen := len(results[0].EquityCurve)
+			for i, result := range results {
+				if len(result.EquityCurve) != testLen {
+					t.Errorf("window %d covers %d candles, want %d", i+1, len(result.EquityCurve), testLen)
+				}
+				found := false
+				for _, periods := range walkForwardGrid {
+					found = found || (result.Strategy.EMAShort == periods[0] && result.Strategy.EMALong == periods[1])
+				}
+				if !found {
+					t.Errorf("window %d used EMA %d/%d, which isn't in the grid", i+1, result.Strategy.EMAShort, result.Strategy.EMALong)
+				}
+			}
+			trainLen := len(klines) - tt.windows*testLen
+			if ratio := float64(trainLen) / float64(trainLen+testLen); ratio < tt.trainRatio-0.05 || ratio > tt.trainRatio+0.05 {
+				t.Errorf("train share = %.2f, want about %.2f", ratio, tt.trainRatio)
+			}
+			if Strategy != DefaultStrategyConfig() {
+				t.Errorf("Strategy left as %+v after the analysis", Strategy)
+			}
+		})
+	}
+}
+
+func TestCombinedEquityCurve(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []BacktestResult
+		want    []float64
+	}{
+		{"none", nil, []float64{}},
+		{
+			"compounds each window from the previous ending value",
+			[]BacktestResult{
+				{InitialBalance: 100, FinalValue: 110, EquityCurve: []float64{100, 105, 110}},
+				{InitialBalance: 100, FinalValue: 90, EquityCurve: []float64{100, 90}},
+			},
+			[]float64{1000, 1050, 1100, 1100, 990},
+		},
+		{
+			"skips windows without a balance",
+			[]BacktestResult{{EquityCurve: []float64{5}}, {InitialBalance: 50, FinalValue: 100, EquityCurve: []float64{50, 100}}},
+			[]float64{1000, 2000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := combinedEquityCurve(tt.results, 1000)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !approxEqual(got[i], tt.want[i], 1e-9) {
+					t.Errorf("got %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
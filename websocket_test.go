@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestServer accepts one WebSocket upgrade per connection and hands the raw connection to
+// script. accept overrides the Sec-WebSocket-Accept value, and status the 101 response.
+type wsTestServer struct {
+	script func(t *testing.T, server *wsConn)
+	accept string
+	status int
+
+	requests chan *http.Request // Every upgrade request received
+}
+
+// newWSTestServer starts a mock WebSocket server and returns it with its ws:// base URL
+func newWSTestServer(t *testing.T, ws *wsTestServer) string {
+	t.Helper()
+	ws.requests = make(chan *http.Request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.requests <- r
+		status := ws.status
+		if status == 0 {
+			status = http.StatusSwitchingProtocols
+		}
+		if status != http.StatusSwitchingProtocols {
+			w.WriteHeader(status)
+			return
+		}
+
+		accept := ws.accept
+		if accept == "" {
+			sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsAcceptGUID))
+			accept = base64.StdEncoding.EncodeToString(sum[:])
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+		rw.Flush()
+		if ws.script != nil {
+			ws.script(t, &wsConn{conn: conn, reader: rw.Reader})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws://" + strings.TrimPrefix(server.URL, "http://")
+}
+
+// serverFrame encodes an unmasked frame, as servers send them
+func serverFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	return append(frame, payload...)
+}
+
+// readClientFrame reads a frame from the client, failing the test unless it was masked
+func readClientFrame(t *testing.T, server *wsConn) (opcode byte, payload []byte) {
+	t.Helper()
+	server.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	peek, err := server.reader.Peek(2)
+	if err != nil {
+		t.Errorf("reading client frame: %v", err)
+		return 0, nil
+	}
+	if peek[1]&0x80 == 0 {
+		t.Error("client frame isn't masked")
+	}
+	_, opcode, payload, err = server.readFrame()
+	if err != nil {
+		t.Errorf("reading client frame: %v", err)
+	}
+	return opcode, payload
+}
+
+func TestDialWebSocketHandshake(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  wsTestServer
+		wantErr string
+	}{
+		{"valid upgrade", wsTestServer{}, ""},
+		{"wrong accept key", wsTestServer{accept: "bm90IHRoZSByaWdodCBrZXk="}, "invalid Sec-WebSocket-Accept"},
+		{"upgrade refused", wsTestServer{status: http.StatusBadRequest}, "400 Bad Request"},
+	}
+	for _, tt := range tests {
+		tt := tt // The hijacked connection can outlive the subtest
+		t.Run(tt.name, func(t *testing.T) {
+			base := newWSTestServer(t, &tt.server)
+			ws, err := dialWebSocket(context.Background(), base+"/ws/btcusdt@kline_15m")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ws.Close()
+
+			r := <-tt.server.requests
+			if r.URL.Path != "/ws/btcusdt@kline_15m" {
+				t.Errorf("path = %s, want /ws/btcusdt@kline_15m", r.URL.Path)
+			}
+			for header, want := range map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Sec-WebSocket-Version": "13"} {
+				if got := r.Header.Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key")); err != nil || len(key) != 16 {
+				t.Errorf("Sec-WebSocket-Key = %q, want 16 random bytes in base64", r.Header.Get("Sec-WebSocket-Key"))
+			}
+		})
+	}
+
+	if _, err := dialWebSocket(context.Background(), "http://example.com/ws"); err == nil {
+		t.Error("expected an error for a non-websocket scheme")
+	}
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte("k"), 300) // Needs the 16-bit extended length
+	tests := []struct {
+		name      string
+		frames    [][]byte
+		want      []string // Messages read before the final error
+		wantErr   error    // Expected error after the messages; nil for any error
+		wantPong  string   // Payload the client must answer a ping with
+		wantClose bool     // The client must echo a close frame
+	}{
+		{
+			name:   "single text frame",
+			frames: [][]byte{serverFrame(true, wsOpText, []byte(`{"e":"kline"}`))},
+			want:   []string{`{"e":"kline"}`},
+		},
+		{
+			name: "fragmented message",
+			frames: [][]byte{
+				serverFrame(false, wsOpText, []byte(`{"e":`)),
+				serverFrame(false, wsOpContinuation, []byte(`"kl`)),
+				serverFrame(true, wsOpContinuation, []byte(`ine"}`)),
+			},
+			want: []string{`{"e":"kline"}`},
+		},
+		{
+			name: "ping between fragments",
+			frames: [][]byte{
+				serverFrame(false, wsOpText, []byte("hel")),
+				serverFrame(true, wsOpPing, []byte("keepalive")),
+				serverFrame(true, wsOpContinuation, []byte("lo")),
+			},
+			want:     []string{"hello"},
+			wantPong: "keepalive",
+		},
+		{
+			name:   "unsolicited pong is ignored",
+			frames: [][]byte{serverFrame(true, wsOpPong, nil), serverFrame(true, wsOpBinary, []byte{1, 2})},
+			want:   []string{"\x01\x02"},
+		},
+		{
+			name:   "extended length",
+			frames: [][]byte{serverFrame(true, wsOpText, long)},
+			want:   []string{string(long)},
+		},
+		{
+			name:      "close",
+			frames:    [][]byte{serverFrame(true, wsOpText, []byte("last")), serverFrame(true, wsOpClose, []byte{0x03, 0xE8})},
+			want:      []string{"last"},
+			wantErr:   io.EOF,
+			wantClose: true,
+		},
+		{
+			name:   "continuation without a message",
+			frames: [][]byte{serverFrame(true, wsOpContinuation, []byte("x"))},
+		},
+		{
+			name:   "new message inside a fragmented one",
+			frames: [][]byte{serverFrame(false, wsOpText, []byte("a")), serverFrame(true, wsOpText, []byte("b"))},
+		},
+		{
+			name:   "unknown opcode",
+			frames: [][]byte{serverFrame(true, 0x3, []byte("x"))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverDone := make(chan struct{})
+			base := newWSTestServer(t, &wsTestServer{script: func(t *testing.T, server *wsConn) {
+				defer close(serverDone)
+				for _, frame := range tt.frames {
+					if _, err := server.conn.Write(frame); err != nil {
+						t.Errorf("writing frame: %v", err)
+						return
+					}
+				}
+				if tt.wantPong != "" {
+					if opcode, payload := readClientFrame(t, server); opcode != wsOpPong || string(payload) != tt.wantPong {
+						t.Errorf("client answered opcode %d %q, want a pong with %q", opcode, payload, tt.wantPong)
+					}
+				}
+				if tt.wantClose {
+					if opcode, _ := readClientFrame(t, server); opcode != wsOpClose {
+						t.Errorf("client answered opcode %d, want a close", opcode)
+					}
+				}
+			}})
+			ws, err := dialWebSocket(context.Background(), base+"/ws")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ws.Close()
+
+			for _, want := range tt.want {
+				got, err := ws.ReadMessage()
+				if err != nil {
+					t.Fatalf("ReadMessage: %v", err)
+				}
+				if string(got) != want {
+					t.Errorf("message = %q, want %q", got, want)
+				}
+			}
+			if len(tt.want) == 0 || tt.wantErr != nil {
+				_, err = ws.ReadMessage()
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Errorf("final ReadMessage error = %v, want %v", err, tt.wantErr)
+				}
+			}
+			<-serverDone
+		})
+	}
+}
+
+func TestStreamKlinesDeliversClosedCandles(t *testing.T) {
+	const forming = `{"e":"kline","E":1704067260000,"s":"BTCUSDT","k":{"t":1704067200000,"T":1704068099999,"s":"BTCUSDT","i":"15m",` +
+		`"f":1,"L":50,"o":"42000.00","c":"42010.00","h":"42020.00","l":"41990.00","v":"1.5","n":50,"x":false,"q":"63000","V":"0.7","Q":"29400","B":"0"}}`
+	const closed = `{"e":"kline","E":1704068100000,"s":"BTCUSDT","k":{"t":1704067200000,"T":1704068099999,"s":"BTCUSDT","i":"15m",` +
+		`"f":1,"L":120,"o":"42000.00","c":"42050.00","h":"42100.00","l":"41950.00","v":"12.5","n":120,"x":true,"q":"525000","V":"6.1","Q":"256000","B":"0"}}`
+
+	server := &wsTestServer{script: func(t *testing.T, server *wsConn) {
+		for _, message := range []string{forming, `{"e":"trade"}`, closed} {
+			server.conn.Write(serverFrame(true, wsOpText, []byte(message)))
+		}
+		// Hold the connection open until the client closes it
+		for {
+			if _, _, _, err := server.readFrame(); err != nil {
+				return
+			}
+		}
+	}}
+	bc := NewBinanceClient("", "")
+	bc.streamURL = newWSTestServer(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan BinanceKline)
+	returned := make(chan error, 1)
+	go func() { returned <- bc.StreamKlines(ctx, "BTCUSDT", "15m", out) }()
+
+	select {
+	case kline := <-out:
+		want := BinanceKline{
+			OpenTime: 1704067200000, CloseTime: 1704068099999,
+			Open: "42000.00", High: "42100.00", Low: "41950.00", Close: "42050.00", Volume: "12.5",
+			QuoteAssetVolume: "525000", NumberOfTrades: 120, TakerBuyBaseAssetVolume: "6.1",
+			TakerBuyQuoteAssetVolume: "256000", Ignore: "0",
+		}
+		if kline != want {
+			t.Errorf("got %+v, want %+v", kline, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no closed kline delivered")
+	}
+	if r := <-server.requests; r.URL.Path != "/ws/btcusdt@kline_15m" {
+		t.Errorf("subscribed to %s, want /ws/btcusdt@kline_15m", r.URL.Path)
+	}
+
+	select {
+	case kline := <-out:
+		t.Errorf("got a second kline %+v; the forming one should be skipped", kline)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-returned:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("StreamKlines returned %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StreamKlines didn't return after cancel")
+	}
+}
+
+func TestParseKlineEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantClose  string
+		wantClosed bool
+		wantErr    bool
+	}{
+		{"closed", `{"e":"kline","k":{"t":1,"T":2,"c":"10.5","l":"9","L":77,"x":true}}`, "10.5", true, false},
+		{"forming", `{"e":"kline","k":{"c":"11","x":false}}`, "11", false, false},
+		{"other event", `{"e":"trade"}`, "", false, true},
+		{"not JSON", `kline`, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kline, closed, err := parseKlineEvent([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if kline.Close != tt.wantClose || closed != tt.wantClosed {
+				t.Errorf("close %q closed %v, want %q %v", kline.Close, closed, tt.wantClose, tt.wantClosed)
+			}
+			// "L" (last trade ID) must not overwrite "l" (low)
+			if tt.name == "closed" && kline.Low != "9" {
+				t.Errorf("low = %q, want 9", kline.Low)
+			}
+		})
+	}
+}